@@ -0,0 +1,261 @@
+package dependencies
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	sftpbase "gitlab.services.mts.ru/salsa/go-base/application/infrastructure/sftp"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/config"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/exportfile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/aggregate"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/cdbmessage"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/export"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/reconcile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/replica"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/transition"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var (
+	_ jobs.WatermarkProber = (*portin.Job)(nil)
+	_ jobs.WatermarkProber = (*cdbmessage.Job)(nil)
+	_ reconcile.Checker    = (*portin.Job)(nil)
+	_ reconcile.Checker    = (*cdbmessage.Job)(nil)
+
+	_ export.Sink              = (*exportfile.SFTPSink)(nil)
+	_ exportfile.SFTPConnector = (*sftpbase.Client)(nil)
+)
+
+// PortInSource - включенный источник portin с подключениями к его БД заказов, отмен и сообщений БДПН.
+type PortInSource struct {
+	Config         config.PortInSourceConfig
+	OrdersDB       *sql.DB
+	CancelDB       *sql.DB
+	CDBMessagingDB *sql.DB
+}
+
+type Databases struct {
+	Target *sql.DB
+	PortIn []PortInSource
+	// Replica - nil, если загрузка Replica выключена.
+	Replica *sql.DB
+}
+
+// MustInitJobRegistry создает и регистрирует все ETL-джобы. Новая джоба добавляется только здесь.
+// portin-джобы источников возвращаются отдельно для обновления заявок по событиям.
+func MustInitJobRegistry(
+	cfg *config.Config,
+	dbs Databases,
+	store *target.Store,
+	loc *time.Location,
+	logger *zap.Logger,
+) (*jobs.Registry, []*portin.Job) {
+	registry := jobs.NewRegistry(store, logger.Named("jobs"))
+
+	var (
+		portInJobs     []*portin.Job
+		portInNames    []string
+		cdbMessageJobs []*cdbmessage.Job
+		checkers       []reconcile.Checker
+		all            []jobs.Job
+	)
+	for _, src := range dbs.PortIn {
+		if src.Config.CancelCDC.Enabled && !src.Config.CDC.Enabled {
+			panic(fmt.Errorf("portin source %q: cancel CDC requires orders CDC", src.Config.Name))
+		}
+		kind, err := portin.ParseOrderIDKind(src.Config.OrderIDKind)
+		if err != nil {
+			panic(fmt.Errorf("portin source %q: %w", src.Config.Name, err))
+		}
+		job := portin.NewJob(portin.Config{
+			Source:      src.Config.Name,
+			Lookback:    cfg.LookbackDuration,
+			BatchSize:   cfg.BatchSize,
+			Prefix:      src.Config.Prefix,
+			OrderIDKind: kind,
+			CancelTable: cfg.PortInCancelTable,
+			Location:    loc,
+			Workers:     cfg.PortInWorkers,
+			OrdersCDC:   mustCDCConfig(&src.Config.CDC, &src.Config.OrdersDB),
+			CancelCDC:   mustCDCConfig(&src.Config.CancelCDC, &src.Config.CancelDB),
+			Schedule:    mustScheduleSpec(&src.Config.Job, loc),
+		}, src.OrdersDB, src.CancelDB, dbs.Target, store, logger)
+		portInJobs = append(portInJobs, job)
+		portInNames = append(portInNames, job.Name())
+		checkers = append(checkers, job)
+		all = append(all, job)
+
+		// Сообщения установки связываются с ее заявками по Prefix, поэтому cdb-message-db у каждой своя.
+		cdbMessageJob := cdbmessage.NewJob(cdbmessage.Config{
+			Source:    src.Config.Name,
+			Lookback:  cfg.LookbackDuration,
+			BatchSize: cfg.BatchSize,
+			Prefix:    src.Config.Prefix,
+			Location:  loc,
+			CDC:       mustCDCConfig(&src.Config.CDBMessageCDC, &src.Config.CDBMessagingDB),
+			Schedule:  mustScheduleSpec(&src.Config.CDBMessageJob, loc),
+			DependsOn: []string{job.Name()},
+		}, src.CDBMessagingDB, dbs.Target, store, logger)
+		cdbMessageJobs = append(cdbMessageJobs, cdbMessageJob)
+		checkers = append(checkers, cdbMessageJob)
+		all = append(all, cdbMessageJob)
+	}
+
+	all = append(all,
+		reconcile.NewJob(reconcile.Config{
+			Window:       cfg.Reconcile.Window,
+			Settle:       cfg.Reconcile.Settle,
+			SampleSize:   cfg.Reconcile.SampleSize,
+			AutoBackfill: cfg.Reconcile.AutoBackfill,
+			Schedule:     mustScheduleSpec(&cfg.Reconcile.Job, loc),
+		}, checkers, dbs.Target, store, logger),
+		aggregate.NewJob(aggregate.Config{
+			Settle:    cfg.Aggregate.Settle,
+			Schedule:  mustScheduleSpec(&cfg.Aggregate.Job, loc),
+			DependsOn: portInNames,
+		}, dbs.Target, store, logger),
+		transition.NewJob(transition.Config{
+			BatchSize: cfg.Transition.BatchSize,
+			Settle:    cfg.Transition.Settle,
+			Schedule:  mustScheduleSpec(&cfg.Transition.Job, loc),
+			DependsOn: portInNames,
+		}, dbs.Target, store, logger),
+	)
+	if cfg.Replica.Enabled {
+		all = append(all, replica.NewJob(replica.Config{
+			BatchSize: cfg.Replica.BatchSize,
+			Lookback:  cfg.Replica.Lookback,
+			Location:  loc,
+			Schedule:  mustScheduleSpec(&cfg.Replica.Job, loc),
+		}, dbs.Replica, dbs.Target, store, logger))
+	}
+	if cfg.Export.Enabled {
+		all = append(all, export.NewJob(mustExportConfig(cfg, loc, logger), store, logger))
+	}
+
+	err := registry.Register(all...)
+	for i, job := range portInJobs {
+		if err != nil {
+			break
+		}
+		err = registry.SetFreshnessPolicy(job.Name(), jobs.FreshnessPolicy{
+			MaxStaleness: dbs.PortIn[i].Config.Job.MaxStaleness,
+			MaxLag:       dbs.PortIn[i].Config.Job.MaxLag,
+		})
+		if err == nil {
+			err = registry.SetFreshnessPolicy(cdbMessageJobs[i].Name(), jobs.FreshnessPolicy{
+				MaxStaleness: dbs.PortIn[i].Config.CDBMessageJob.MaxStaleness,
+				MaxLag:       dbs.PortIn[i].Config.CDBMessageJob.MaxLag,
+			})
+		}
+	}
+	if err == nil {
+		err = registry.Validate()
+	}
+	if err != nil {
+		panic(fmt.Errorf("failed to init job registry: %w", err))
+	}
+
+	return registry, portInJobs
+}
+
+func mustScheduleSpec(cfg *config.JobScheduleConfig, loc *time.Location) scheduler.Spec {
+	spec, err := cfg.ScheduleSpec(loc)
+	if err != nil {
+		panic(fmt.Errorf("invalid job schedule: %w", err))
+	}
+
+	return spec
+}
+
+// mustCDCConfig возвращает слот источника db или nil, если CDC выключен и джоба работает опросом.
+func mustCDCConfig(cfg *config.CDCConfig, db *config.PostgresConfig) *cdc.Config {
+	if !cfg.Enabled {
+		return nil
+	}
+	res := &cdc.Config{
+		ConnString:  db.GetAppConnectionString(),
+		Slot:        cfg.Slot,
+		Publication: cfg.Publication,
+		MaxChanges:  cfg.MaxChanges,
+		IdleTimeout: cfg.IdleTimeout,
+	}
+	if err := res.Validate(); err != nil {
+		panic(fmt.Errorf("invalid cdc config: %w", err))
+	}
+
+	return res
+}
+
+func mustExportConfig(appCfg *config.Config, loc *time.Location, logger *zap.Logger) export.Config {
+	cfg := &appCfg.Export
+	res := export.Config{
+		Dir:       cfg.Dir,
+		Settle:    cfg.Settle,
+		Retention: cfg.Retention,
+		Schedule:  mustScheduleSpec(&cfg.Job, loc),
+	}
+
+	names := make([]string, 0, len(cfg.Consumers))
+	for name := range cfg.Consumers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		switch mode := cfg.Consumers[name]; mode {
+		case export.ModeFull, export.ModeIncremental:
+			res.Consumers = append(res.Consumers, export.Consumer{Name: name, Full: mode == export.ModeFull})
+		default:
+			panic(fmt.Errorf("invalid export mode %q for consumer %s", mode, name))
+		}
+	}
+	if len(res.Consumers) == 0 {
+		panic(fmt.Errorf("EXPORT_CONSUMERS is required when export is enabled"))
+	}
+
+	if len(cfg.SFTP.Consumers) > 0 {
+		client, err := sftpbase.NewClient(&appCfg.ExportSFTP)
+		if err != nil {
+			panic(fmt.Errorf("failed to init export sftp client: %w", err))
+		}
+		sink := exportfile.NewSFTPSink(client, exportfile.SFTPConfig{
+			Root:       cfg.SFTP.DropDir,
+			Retries:    cfg.SFTP.Retries,
+			RetryDelay: cfg.SFTP.RetryDelay,
+			Retention:  cfg.SFTP.Retention,
+		}, logger)
+		for _, name := range cfg.SFTP.Consumers {
+			i := slices.IndexFunc(res.Consumers, func(c export.Consumer) bool { return c.Name == name })
+			if i < 0 {
+				panic(fmt.Errorf("EXPORT_SFTP_CONSUMERS: unknown export consumer %q", name))
+			}
+			res.Consumers[i].Sink = sink
+		}
+	}
+
+	for _, name := range cfg.Tables {
+		t, ok := target.ExportTableByName(name)
+		if !ok {
+			panic(fmt.Errorf("unknown export table %q", name))
+		}
+		res.Tables = append(res.Tables, t)
+	}
+	for _, name := range cfg.Formats {
+		f, err := exportfile.ParseFormat(name)
+		if err != nil {
+			panic(err)
+		}
+		res.Formats = append(res.Formats, f)
+	}
+
+	return res
+}
@@ -0,0 +1,69 @@
+package dependencies
+
+import (
+	"context"
+	"fmt"
+
+	appConfig "gitlab.services.mts.ru/salsa/go-base/application/config"
+	"gitlab.services.mts.ru/salsa/go-base/application/diagnostics"
+	"gitlab.services.mts.ru/salsa/go-base/application/infrastructure/kafka"
+	kafkaconsumer "gitlab.services.mts.ru/salsa/go-base/application/infrastructure/kafka/consumer"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/config"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/mnpevent"
+)
+
+const mnpEventConsumerCfgName = "mnp-event-portin"
+
+func MustInitKafkaClient(cfg *appConfig.KafkaConfig) *kafka.Kafka {
+	kafkaClient, err := kafka.InitKafka(cfg)
+	if err != nil {
+		panic(fmt.Errorf("failed to init kafka client: %w", err))
+	}
+
+	return kafkaClient
+}
+
+// MnpEventRefresh читает события portin-service из топика MNP event и обновляет затронутые заявки
+// с задержкой mnpevent.Debouncer.
+type MnpEventRefresh struct {
+	consumer  kafka.Consumer[mnpevent.PortIn]
+	debouncer *mnpevent.Debouncer
+}
+
+func MustInitMnpEventRefresh(
+	ctx context.Context,
+	kafkaClient *kafka.Kafka,
+	cfg *config.MnpEventRefreshConfig,
+	refresh mnpevent.RefreshFunc,
+	logger *zap.Logger,
+) *MnpEventRefresh {
+	consumer, err := kafkaconsumer.NewConsumer(
+		kafkaClient,
+		kafkaconsumer.WithNamedConfig[mnpevent.PortIn](mnpEventConsumerCfgName),
+		kafkaconsumer.WithErrorLogger[mnpevent.PortIn](diagnostics.LoggerFromContext(ctx)),
+	)
+	if err != nil {
+		panic(fmt.Errorf("failed to init mnp-event consumer: %w", err))
+	}
+
+	return &MnpEventRefresh{
+		consumer: consumer,
+		debouncer: mnpevent.NewDebouncer(mnpevent.Config{
+			Delay:    cfg.Delay,
+			MaxDelay: cfg.MaxDelay,
+			MaxBatch: cfg.MaxBatch,
+		}, refresh, logger),
+	}
+}
+
+func (r *MnpEventRefresh) Start(ctx context.Context) error {
+	r.consumer.Start(ctx, func(ctx context.Context, msg *kafka.MessageResult[mnpevent.PortIn]) error {
+		return r.debouncer.Handle(ctx, msg.Body)
+	})
+	err := r.debouncer.Run(ctx)
+	_ = r.consumer.Close()
+
+	return err
+}
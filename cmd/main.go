@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -19,8 +23,10 @@ import (
 
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/cmd/dependencies"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/config"
-	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/cdbmessage"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/openapi/datamart"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
 )
 
@@ -58,46 +64,105 @@ func runApplication(ctx context.Context, stop context.CancelFunc) {
 	targetDB := dependencies.MustInitDB(ctx, &a.Config.TargetDB)
 	defer targetDB.Close()
 
-	portInDB := dependencies.MustInitDB(ctx, &a.Config.PortInOrdersDB)
-	defer portInDB.Close()
+	portInSources, err := a.Config.PortInSourceConfigs(ctx)
+	if err != nil {
+		panic(err)
+	}
+	portIn := make([]dependencies.PortInSource, 0, len(portInSources))
+	for _, src := range portInSources {
+		ordersDB := dependencies.MustInitDB(ctx, &src.OrdersDB)
+		defer ordersDB.Close()
+
+		cancelDB := dependencies.MustInitDB(ctx, &src.CancelDB)
+		defer cancelDB.Close()
 
-	cancelDB := dependencies.MustInitDB(ctx, &a.Config.PortInCancelDB)
-	defer cancelDB.Close()
+		cdbDB := dependencies.MustInitDB(ctx, &src.CDBMessagingDB)
+		defer cdbDB.Close()
 
-	cdbDB := dependencies.MustInitDB(ctx, &a.Config.CDBMessagingDB)
-	defer cdbDB.Close()
+		portIn = append(portIn, dependencies.PortInSource{Config: src, OrdersDB: ordersDB, CancelDB: cancelDB, CDBMessagingDB: cdbDB})
+	}
 
-	store := target.NewStore(targetDB)
-	portInJob := portin.NewJob(portin.Config{
-		Lookback:    a.Config.LookbackDuration,
-		BatchSize:   a.Config.BatchSize,
-		Prefix:      a.Config.PortInPrefix,
-		CancelTable: a.Config.PortInCancelTable,
-	}, portInDB, cancelDB, targetDB, store, a.Logger)
-	cdbJob := cdbmessage.NewJob(cdbmessage.Config{
-		Lookback:  a.Config.LookbackDuration,
-		BatchSize: a.Config.BatchSize,
-		Prefix:    a.Config.PortInPrefix,
-	}, cdbDB, targetDB, store, a.Logger)
+	var replicaDB *sql.DB
+	if a.Config.Replica.Enabled {
+		replicaDBConfig, err := config.ReplicaDBConfig(ctx)
+		if err != nil {
+			panic(err)
+		}
+		replicaDB = dependencies.MustInitDB(ctx, replicaDBConfig)
+		defer replicaDB.Close()
+	}
+
+	location, err := a.Config.BusinessLocation()
+	if err != nil {
+		panic(err)
+	}
+
+	store := target.NewStore(targetDB, location)
+	registry, portInJobs := dependencies.MustInitJobRegistry(&a.Config, dependencies.Databases{
+		Target:  targetDB,
+		PortIn:  portIn,
+		Replica: replicaDB,
+	}, store, location, a.Logger)
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("POST /jobs/portin/run", runJobHandler(a.Logger.Named("http.portin-run"), portInJob.Run))
-	mux.HandleFunc("POST /jobs/cdb-message/run", runJobHandler(a.Logger.Named("http.cdb-message-run"), cdbJob.Run))
+	mux.Handle("GET /metrics", metrics.Handler())
+	registry.RegisterRoutes(mux)
+	mux.HandleFunc("GET /quarantine", listQuarantineHandler(a.Logger.Named("http.quarantine-list"), store))
+	mux.HandleFunc("POST /quarantine/{id}/retry", quarantineActionHandler(a.Logger.Named("http.quarantine-retry"),
+		func(ctx context.Context, id int64) error {
+			rec, err := store.GetQuarantine(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			return registry.RetryQuarantined(ctx, rec.Job, id)
+		}))
+	mux.HandleFunc("POST /quarantine/{id}/dismiss", quarantineActionHandler(a.Logger.Named("http.quarantine-dismiss"), store.DismissQuarantine))
+	mux.HandleFunc("GET /reconciliation", listReconciliationHandler(a.Logger.Named("http.reconciliation-list"), store))
+
+	// API чтения витрины (internal/openapi/datamart), обращается только к mnp-datamart-db.
+	datamartAPI := datamart.NewServer(store, a.Logger).NewStrictHandler()
+	mux.Handle("GET /requests", datamartAPI)
+	mux.Handle("GET /requests/", datamartAPI)
+	mux.HandleFunc("GET /openapi.json", datamart.SpecHandler)
+
+	// Kafka не входит в /health/ready: без событий витрина обновляется плановым запуском.
+	// События содержат order_number, заявка обновляется в источнике, которому принадлежит его префикс.
+	if a.Config.MnpEventRefresh.Enabled {
+		mnpEventKafkaClient := dependencies.MustInitKafkaClient(&a.Config.MnpEventKafka)
+		mnpEventRefresh := dependencies.MustInitMnpEventRefresh(ctx, mnpEventKafkaClient, &a.Config.MnpEventRefresh,
+			func(ctx context.Context, keys []string) error {
+				routed, unrouted := portin.RouteOrders(portInJobs, keys)
+				if len(unrouted) > 0 {
+					a.Logger.Warn("mnp-event orders without portin source", zap.Strings("order_ids", unrouted))
+				}
+
+				var errs []error
+				for _, job := range portInJobs {
+					if ids := routed[job]; len(ids) > 0 {
+						errs = append(errs, job.RefreshOrders(ctx, ids))
+					}
+				}
+
+				return errors.Join(errs...)
+			}, a.Logger)
+
+		a.AddStarter(mnpEventKafkaClient)
+		a.AddStarter(mnpEventRefresh)
+	}
+
+	healthDBs := []*sql.DB{targetDB}
+	for _, src := range portIn {
+		healthDBs = append(healthDBs, src.OrdersDB, src.CancelDB, src.CDBMessagingDB)
+	}
 
 	httpServer := httphandler.CreateBuilder(mux).
-		WithHealthCheck(
-			httphandler.WithPerCheckTimeout(3*time.Second),
-			httphandler.WithDB(targetDB),
-			httphandler.WithDB(portInDB),
-			httphandler.WithDB(cancelDB),
-			httphandler.WithDB(cdbDB),
-		).
+		WithHealthCheck(healthChecks(httphandler.WithPerCheckTimeout(3*time.Second), httphandler.WithDB, healthDBs...)...).
 		WithRecoveryMessage("panic occurred. Check logs for details", a.Logger).
 		WithLoggingAndTracing(a.Logger.Named("http-server")).
 		Build(":" + a.Config.HTTP.Port)
 
-	go runTicker(ctx, a.Config.PortInJobInterval, "portin", a.Logger.Named("scheduler.portin"), portInJob.Run)
-	go runTicker(ctx, a.Config.CDBMessageJobInterval, "cdb-message", a.Logger.Named("scheduler.cdb-message"), cdbJob.Run)
+	go registry.Start(ctx)
 
 	a.AddStarter(httpServer)
 
@@ -108,34 +173,87 @@ func runApplication(ctx context.Context, stop context.CancelFunc) {
 	a.Logger.Info("Shutdown complete")
 }
 
-func runTicker(ctx context.Context, interval time.Duration, name string, logger *zap.Logger, run func(context.Context) error) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// healthChecks возвращает опции /health/ready: таймаут проверки и проверку каждой БД.
+func healthChecks[O any](timeout O, withDB func(*sql.DB) O, dbs ...*sql.DB) []O {
+	res := []O{timeout}
+	for _, db := range dbs {
+		res = append(res, withDB(db))
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			runCtx, cancel := context.WithTimeout(ctx, interval)
-			err := run(runCtx)
-			cancel()
+	return res
+}
+
+func listQuarantineHandler(logger *zap.Logger, store *target.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := target.QuarantineFilter{
+			Job:              query.Get("job"),
+			IncludeDismissed: query.Get("dismissed") == "true",
+		}
+		if v := query.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
 			if err != nil {
-				logger.Error("job execution failed", zap.String("job", name), zap.Error(err))
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
 			}
+			filter.Limit = limit
+		}
+
+		records, err := store.ListQuarantine(r.Context(), filter)
+		if err != nil {
+			logger.Error("quarantine list failed", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			logger.Error("quarantine list encode failed", zap.Error(err))
+		}
+	}
+}
+
+func listReconciliationHandler(logger *zap.Logger, store *target.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		results, err := store.LatestReconciliation(r.Context(), target.ReconciliationFilter{
+			Check:          query.Get("check"),
+			MismatchedOnly: query.Get("mismatched") == "true",
+		})
+		if err != nil {
+			logger.Error("reconciliation list failed", zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			logger.Error("reconciliation list encode failed", zap.Error(err))
 		}
 	}
 }
 
-func runJobHandler(logger *zap.Logger, run func(context.Context) error) http.HandlerFunc {
+func quarantineActionHandler(logger *zap.Logger, action func(context.Context, int64) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if err := run(r.Context()); err != nil {
-			logger.Error("job run failed", zap.Error(err))
-			w.WriteHeader(http.StatusInternalServerError)
-			_, _ = w.Write([]byte(err.Error()))
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid quarantine id", http.StatusBadRequest)
 			return
 		}
 
-		w.WriteHeader(http.StatusAccepted)
+		err = action(r.Context(), id)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, target.ErrQuarantineNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, portin.ErrJobRunning):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, jobs.ErrRetryNotSupported), errors.Is(err, portin.ErrNotPortInRecord):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			logger.Error("quarantine action failed", zap.Int64("quarantine_id", id), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	}
 }
@@ -1,22 +1,36 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/go-playground/validator/v10"
+	"github.com/sethvargo/go-envconfig"
 	appConfig "gitlab.services.mts.ru/salsa/go-base/application/config"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
 )
 
 type Config struct {
 	appConfig.AppConfig
 	HTTP                      HTTPConfig            `env:",prefix=HTTP_"`
 	MnpEventKafka             appConfig.KafkaConfig `env:",prefix=MNP_EVENT_"`
-	PortInOrdersDB            PostgresConfig        `env:",prefix=MNPPORTIN_ORDERS_PG_" validate:"required"`
-	PortInCancelDB            PostgresConfig        `env:",prefix=MNPPORTIN_CANCEL_PG_" validate:"required"`
-	CDBMessagingDB            PostgresConfig        `env:",prefix=CDB_MESSAGING_PG_" validate:"required"`
+	MnpEventRefresh           MnpEventRefreshConfig `env:",prefix=MNP_EVENT_REFRESH_"`
 	TargetDB                  PostgresConfig        `env:",prefix=MNP_DATAMART_PG_" validate:"required"`
-	PortInJobInterval         time.Duration         `env:"PORTIN_JOB_INTERVAL,default=1h"`
-	CDBMessageJobInterval     time.Duration         `env:"CDB_MESSAGE_JOB_INTERVAL,default=1h"`
+	PortInJob                 JobScheduleConfig     `env:",prefix=PORTIN_JOB_"`
+	CDBMessageJob             JobScheduleConfig     `env:",prefix=CDB_MESSAGE_JOB_"`
+	Reconcile                 ReconcileConfig       `env:",prefix=RECONCILE_"`
+	Aggregate                 AggregateConfig       `env:",prefix=AGGREGATE_"`
+	Transition                TransitionConfig      `env:",prefix=TRANSITION_"`
+	Replica                   ReplicaConfig         `env:",prefix=REPLICA_"`
+	PortInCDC                 CDCConfig             `env:",prefix=PORTIN_CDC_"`
+	PortInCancelCDC           CDCConfig             `env:",prefix=PORTIN_CANCEL_CDC_"`
+	CDBMessageCDC             CDCConfig             `env:",prefix=CDB_MESSAGE_CDC_"`
+	Export                    ExportConfig          `env:",prefix=EXPORT_"`
+	ExportSFTP                appConfig.FTPConfig   `env:",prefix=EXPORT_SFTP_"`
 	LookbackDuration          time.Duration         `env:"LOOKBACK_DURATION,default=5m"`
 	BatchSize                 int                   `env:"BATCH_SIZE,default=5000"`
 	MnpRPSMax                 int                   `env:"MNP_RPS_MAX,default=10"`
@@ -30,11 +44,274 @@ type Config struct {
 	KafkaClientID             string                `env:"KAFKA_CLIENT_ID"`
 	KafkaClientSecret         string                `env:"KAFKA_CLIENT_SECRET"`
 	PortInPrefix              string                `env:"PORTIN_PREFIX,default=pin"`
+	PortInOrderIDKind         string                `env:"PORTIN_ORDER_ID_KIND,default=int" validate:"oneof=int guid"`
+	PortInSources             []string              `env:"PORTIN_SOURCES"`
 	PortInCancelTable         string                `env:"PORTIN_CANCEL_TABLE,default=orders"`
+	PortInWorkers             int                   `env:"PORTIN_WORKERS,default=1"`
+	BusinessTimezone          string                `env:"BUSINESS_TIMEZONE,default=Europe/Moscow"`
 	MigrationsPath            string                `env:"MIGRATIONS_PATH,default=/app/db/migrations"`
 	MigrationsVersionTable    string                `env:"MIGRATIONS_VERSION_TABLE" validate:"required"`
 }
 
+// BusinessLocation возвращает зону, в которой хранятся колонки TIMESTAMP витрины
+// и интерпретируются даты источников без смещения.
+func (c *Config) BusinessLocation() (*time.Location, error) {
+	loc, err := time.LoadLocation(c.BusinessTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid business timezone %q: %w", c.BusinessTimezone, err)
+	}
+
+	return loc, nil
+}
+
+// PortInSourceConfig - установка MNPHUB, из которой загружает заявки отдельный экземпляр джобы portin.
+type PortInSourceConfig struct {
+	// Name - имя из PORTIN_SOURCES, значение колонки source витрины. Пусто - единственная установка без PORTIN_SOURCES.
+	Name    string
+	Enabled bool   `env:"ENABLED,default=true"`
+	Prefix  string `env:"PREFIX" validate:"required"`
+	// OrderIDKind - тип order_id в БД источника: int (BIGINT) или guid (uuid).
+	OrderIDKind string            `env:"ORDER_ID_KIND,default=int" validate:"oneof=int guid"`
+	OrdersDB    PostgresConfig    `env:",prefix=ORDERS_PG_"`
+	CancelDB    PostgresConfig    `env:",prefix=CANCEL_PG_"`
+	Job         JobScheduleConfig `env:",prefix=JOB_"`
+	CDC         CDCConfig         `env:",prefix=CDC_"`
+	CancelCDC   CDCConfig         `env:",prefix=CANCEL_CDC_"`
+	// CDBMessagingDB - cdb-messaging-db установки, из нее отдельный экземпляр cdb-message загружает сообщения с Prefix.
+	CDBMessagingDB PostgresConfig    `env:",prefix=CDB_MESSAGING_PG_"`
+	CDBMessageJob  JobScheduleConfig `env:",prefix=CDB_MESSAGE_JOB_"`
+	CDBMessageCDC  CDCConfig         `env:",prefix=CDB_MESSAGE_CDC_"`
+}
+
+var portInSourceName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// maxPortInPrefix - префикс вместе с GUID (36 символов) должен помещаться в order_number VARCHAR(64).
+const maxPortInPrefix = 28
+
+// PortInSourceConfigs возвращает включенные источники portin. Источник NAME из PORTIN_SOURCES читается из PORTIN_SOURCE_<NAME>_*.
+// Без PORTIN_SOURCES источник один и задается прежними MNPPORTIN_ORDERS_PG_*, MNPPORTIN_CANCEL_PG_*, PORTIN_PREFIX,
+// PORTIN_JOB_*, PORTIN_CDC_*, PORTIN_CANCEL_CDC_*, CDB_MESSAGING_PG_*, CDB_MESSAGE_JOB_* и CDB_MESSAGE_CDC_*.
+func (c *Config) PortInSourceConfigs(ctx context.Context) ([]PortInSourceConfig, error) {
+	if len(c.PortInSources) == 0 {
+		src := PortInSourceConfig{
+			Enabled:       true,
+			Prefix:        c.PortInPrefix,
+			OrderIDKind:   c.PortInOrderIDKind,
+			Job:           c.PortInJob,
+			CDC:           c.PortInCDC,
+			CancelCDC:     c.PortInCancelCDC,
+			CDBMessageJob: c.CDBMessageJob,
+			CDBMessageCDC: c.CDBMessageCDC,
+		}
+		if err := processPrefixed(ctx, "MNPPORTIN_ORDERS_PG_", &src.OrdersDB); err != nil {
+			return nil, err
+		}
+		if err := processPrefixed(ctx, "MNPPORTIN_CANCEL_PG_", &src.CancelDB); err != nil {
+			return nil, err
+		}
+		if err := processPrefixed(ctx, "CDB_MESSAGING_PG_", &src.CDBMessagingDB); err != nil {
+			return nil, err
+		}
+		if len(src.Prefix) > maxPortInPrefix {
+			return nil, fmt.Errorf("PORTIN_PREFIX is longer than %d characters", maxPortInPrefix)
+		}
+
+		return []PortInSourceConfig{src}, nil
+	}
+
+	res := make([]PortInSourceConfig, 0, len(c.PortInSources))
+	names := make(map[string]bool, len(c.PortInSources))
+	prefixes := make(map[string]string, len(c.PortInSources))
+	for _, name := range c.PortInSources {
+		if !portInSourceName.MatchString(name) {
+			return nil, fmt.Errorf("invalid portin source name %q", name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate portin source %q", name)
+		}
+		names[name] = true
+
+		prefix := "PORTIN_SOURCE_" + strings.ToUpper(name) + "_"
+		src := PortInSourceConfig{Name: name}
+		if err := readPrefixed(ctx, prefix, &src); err != nil {
+			return nil, err
+		}
+		if !src.Enabled {
+			continue
+		}
+		if err := validatePrefixed(prefix, &src); err != nil {
+			return nil, err
+		}
+		if len(src.Prefix) > maxPortInPrefix {
+			return nil, fmt.Errorf("%sPREFIX is longer than %d characters", prefix, maxPortInPrefix)
+		}
+		// order_number = префикс + order_id уникален в витрине, только если ни один префикс не начинает другой.
+		for p, other := range prefixes {
+			if strings.HasPrefix(src.Prefix, p) || strings.HasPrefix(p, src.Prefix) {
+				return nil, fmt.Errorf("portin sources %s and %s have overlapping prefixes %q and %q", other, name, p, src.Prefix)
+			}
+		}
+		prefixes[src.Prefix] = name
+		res = append(res, src)
+	}
+
+	return res, nil
+}
+
+// ReplicaDBConfig читает подключение REPLICA_PG_* к выгрузке Replica. Переменные обязательны только
+// при REPLICA_ENABLED, поэтому не входят в Config и проверяются при чтении.
+func ReplicaDBConfig(ctx context.Context) (*PostgresConfig, error) {
+	var res PostgresConfig
+	if err := processPrefixed(ctx, "REPLICA_PG_", &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// processPrefixed заполняет target из переменных окружения с префиксом prefix и проверяет теги validate.
+func processPrefixed(ctx context.Context, prefix string, target any) error {
+	if err := readPrefixed(ctx, prefix, target); err != nil {
+		return err
+	}
+
+	return validatePrefixed(prefix, target)
+}
+
+func readPrefixed(ctx context.Context, prefix string, target any) error {
+	err := envconfig.ProcessWith(ctx, &envconfig.Config{
+		Target:   target,
+		Lookuper: envconfig.PrefixLookuper(prefix, envconfig.OsLookuper()),
+	})
+	if err != nil {
+		return fmt.Errorf("invalid %s* config: %w", prefix, err)
+	}
+
+	return nil
+}
+
+func validatePrefixed(prefix string, target any) error {
+	if err := validator.New().Struct(target); err != nil {
+		return fmt.Errorf("invalid %s* config: %w", prefix, err)
+	}
+
+	return nil
+}
+
+// JobScheduleConfig - расписание джобы. Cron имеет приоритет над Interval
+// и вычисляется в зоне BUSINESS_TIMEZONE. MaxStaleness и MaxLag задают пороги /health/data, 0 отключает проверку.
+type JobScheduleConfig struct {
+	Interval        time.Duration `env:"INTERVAL,default=1h"`
+	Cron            string        `env:"CRON"`
+	RunOnStart      bool          `env:"RUN_ON_START,default=false"`
+	Jitter          time.Duration `env:"JITTER,default=0s"`
+	MaxRunDuration  time.Duration `env:"MAX_RUN_DURATION,default=2h"`
+	MissedRunPolicy string        `env:"MISSED_RUN_POLICY,default=skip" validate:"oneof=skip run-once"`
+	MaxStaleness    time.Duration `env:"MAX_STALENESS,default=3h"`
+	MaxLag          time.Duration `env:"MAX_LAG,default=2h"`
+}
+
+func (c *JobScheduleConfig) ScheduleSpec(loc *time.Location) (scheduler.Spec, error) {
+	policy, err := scheduler.ParseMissedRunPolicy(c.MissedRunPolicy)
+	if err != nil {
+		return scheduler.Spec{}, err
+	}
+
+	var schedule scheduler.Schedule
+	switch {
+	case c.Cron != "":
+		cron, err := scheduler.ParseCron(c.Cron, loc)
+		if err != nil {
+			return scheduler.Spec{}, err
+		}
+		schedule = cron
+	case c.Interval > 0:
+		schedule = scheduler.Every(c.Interval)
+	default:
+		return scheduler.Spec{}, fmt.Errorf("job schedule requires cron or positive interval")
+	}
+
+	return scheduler.Spec{
+		Schedule:        schedule,
+		RunOnStart:      c.RunOnStart,
+		Jitter:          c.Jitter,
+		MaxRunDuration:  c.MaxRunDuration,
+		MissedRunPolicy: policy,
+	}, nil
+}
+
+// ReconcileConfig - сверка источников с витриной за окно Window, заканчивающееся за Settle до запуска.
+type ReconcileConfig struct {
+	Job          JobScheduleConfig `env:",prefix=JOB_"`
+	Window       time.Duration     `env:"WINDOW,default=24h"`
+	Settle       time.Duration     `env:"SETTLE,default=1h"`
+	SampleSize   int               `env:"SAMPLE_SIZE,default=100" validate:"gte=0"`
+	AutoBackfill bool              `env:"AUTO_BACKFILL,default=false"`
+}
+
+// AggregateConfig - дневные агрегаты agg_mnp_request*, пересчитываются после portin и по собственному расписанию.
+type AggregateConfig struct {
+	Job    JobScheduleConfig `env:",prefix=JOB_"`
+	Settle time.Duration     `env:"SETTLE,default=5m"`
+}
+
+// TransitionConfig - переходы статусов mnp_request_transition, пересчитываются после portin и по собственному расписанию.
+type TransitionConfig struct {
+	Job       JobScheduleConfig `env:",prefix=JOB_"`
+	Settle    time.Duration     `env:"SETTLE,default=5m"`
+	BatchSize int               `env:"BATCH_SIZE,default=1000" validate:"gt=0"`
+}
+
+// ReplicaConfig - загрузка выгрузки Replica ESB из REPLICA_PG_* в таблицы replica_*.
+type ReplicaConfig struct {
+	Enabled   bool              `env:"ENABLED,default=false"`
+	Job       JobScheduleConfig `env:",prefix=JOB_"`
+	BatchSize int               `env:"BATCH_SIZE,default=1000" validate:"gt=0"`
+	Lookback  time.Duration     `env:"LOOKBACK,default=5m"`
+}
+
+// MnpEventRefreshConfig - обновление заявок по событиям portin-service из топика MNP event.
+// Плановый запуск portin остается страховкой на случай потерянных событий.
+type MnpEventRefreshConfig struct {
+	Enabled  bool          `env:"ENABLED,default=false"`
+	Delay    time.Duration `env:"DELAY,default=30s"`
+	MaxDelay time.Duration `env:"MAX_DELAY,default=2m"`
+	MaxBatch int           `env:"MAX_BATCH,default=500" validate:"gte=0"`
+}
+
+// CDCConfig - чтение источника из слота логической репликации вместо опроса.
+// Выключенный CDC оставляет джобу в режиме опроса по watermark.
+type CDCConfig struct {
+	Enabled     bool          `env:"ENABLED,default=false"`
+	Slot        string        `env:"SLOT"`
+	Publication string        `env:"PUBLICATION"`
+	MaxChanges  int           `env:"MAX_CHANGES,default=10000" validate:"gte=0"`
+	IdleTimeout time.Duration `env:"IDLE_TIMEOUT,default=5s"`
+}
+
+// ExportConfig - выгрузка таблиц витрины в файлы для потребителей без JDBC.
+// Consumers - имена потребителей с режимом выгрузки: "sas:incremental,adhoc:full".
+type ExportConfig struct {
+	Enabled   bool              `env:"ENABLED,default=false"`
+	Job       JobScheduleConfig `env:",prefix=JOB_"`
+	Dir       string            `env:"DIR,default=/var/lib/mnp-datamart/export"`
+	Consumers map[string]string `env:"CONSUMERS"`
+	Tables    []string          `env:"TABLES,default=mnp_request,mnp_request_h,req_number,mnp_raw_request"`
+	Formats   []string          `env:"FORMATS,default=csv,parquet"`
+	Settle    time.Duration     `env:"SETTLE,default=1m"`
+	Retention time.Duration     `env:"RETENTION,default=168h"`
+	SFTP      ExportSFTPConfig  `env:",prefix=SFTP_"`
+}
+
+// ExportSFTPConfig - доставка выгрузок потребителей Consumers на SFTP, подключение - EXPORT_SFTP_*.
+type ExportSFTPConfig struct {
+	Consumers  []string      `env:"CONSUMERS"`
+	DropDir    string        `env:"DROP_DIR,default=/"`
+	Retries    int           `env:"RETRIES,default=3" validate:"gte=0"`
+	RetryDelay time.Duration `env:"RETRY_DELAY,default=30s"`
+	Retention  time.Duration `env:"RETENTION,default=168h"`
+}
+
 type PostgresConfig struct {
 	Host                 string `env:"HOST" validate:"required"`
 	Port                 string `env:"PORT,default=5432" validate:"required"`
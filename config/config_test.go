@@ -37,3 +37,83 @@ func TestGetConnectionString(t *testing.T) {
 		require.Equal(t, expectedWithSchema, actualWithSchema)
 	})
 }
+
+func TestBusinessLocation(t *testing.T) {
+	cfg := config.Config{BusinessTimezone: "Europe/Moscow"}
+	loc, err := cfg.BusinessLocation()
+	require.NoError(t, err)
+	require.Equal(t, "Europe/Moscow", loc.String())
+
+	cfg.BusinessTimezone = "Mars/Olympus"
+	_, err = cfg.BusinessLocation()
+	require.Error(t, err)
+}
+
+func TestReplicaDBConfig(t *testing.T) {
+	_, err := config.ReplicaDBConfig(t.Context())
+	require.Error(t, err)
+
+	t.Setenv("REPLICA_PG_HOST", "replica")
+	t.Setenv("REPLICA_PG_DB_NAME", "esb")
+	t.Setenv("REPLICA_PG_MIGRATION_USERNAME", "migration")
+	t.Setenv("REPLICA_PG_MIGRATION_PASSWORD", "migration")
+	t.Setenv("REPLICA_PG_USERNAME", "app")
+	t.Setenv("REPLICA_PG_PASSWORD", "app")
+
+	cfg, err := config.ReplicaDBConfig(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "replica", cfg.Host)
+	require.Equal(t, "5432", cfg.Port)
+}
+
+func TestPortInSourceConfigs(t *testing.T) {
+	setDB := func(prefix string) {
+		for _, name := range []string{"HOST", "DB_NAME", "MIGRATION_USERNAME", "MIGRATION_PASSWORD", "USERNAME", "PASSWORD"} {
+			t.Setenv(prefix+name, "x")
+		}
+	}
+	setDB("MNPPORTIN_ORDERS_PG_")
+	setDB("MNPPORTIN_CANCEL_PG_")
+	setDB("CDB_MESSAGING_PG_")
+
+	cfg := config.Config{PortInPrefix: "pin"}
+	sources, err := cfg.PortInSourceConfigs(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	require.Empty(t, sources[0].Name)
+	require.Equal(t, "pin", sources[0].Prefix)
+	require.Equal(t, "x", sources[0].CDBMessagingDB.Host)
+
+	cfg.PortInSources = []string{"s7", "s3", "old"}
+	for _, name := range []string{"S7", "S3"} {
+		setDB("PORTIN_SOURCE_" + name + "_ORDERS_PG_")
+		setDB("PORTIN_SOURCE_" + name + "_CANCEL_PG_")
+		setDB("PORTIN_SOURCE_" + name + "_CDB_MESSAGING_PG_")
+	}
+	t.Setenv("PORTIN_SOURCE_S7_PREFIX", "pin")
+	t.Setenv("PORTIN_SOURCE_S3_PREFIX", "pin")
+	t.Setenv("PORTIN_SOURCE_OLD_ENABLED", "false")
+	_, err = cfg.PortInSourceConfigs(t.Context())
+	require.ErrorContains(t, err, "overlapping prefixes")
+
+	t.Setenv("PORTIN_SOURCE_S3_PREFIX", "pin3")
+	_, err = cfg.PortInSourceConfigs(t.Context())
+	require.ErrorContains(t, err, "overlapping prefixes")
+
+	t.Setenv("PORTIN_SOURCE_S3_PREFIX", "p03")
+	sources, err = cfg.PortInSourceConfigs(t.Context())
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	require.Equal(t, "s3", sources[1].Name)
+	require.Equal(t, "p03", sources[1].Prefix)
+	require.Equal(t, "int", sources[1].OrderIDKind)
+
+	t.Setenv("PORTIN_SOURCE_S3_ORDER_ID_KIND", "guid")
+	sources, err = cfg.PortInSourceConfigs(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "guid", sources[1].OrderIDKind)
+
+	t.Setenv("PORTIN_SOURCE_S3_ORDER_ID_KIND", "uuid")
+	_, err = cfg.PortInSourceConfigs(t.Context())
+	require.Error(t, err)
+}
@@ -0,0 +1,27 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS etl_quarantine (
+  id           BIGSERIAL PRIMARY KEY,
+  job          VARCHAR(64)  NOT NULL,
+  source_table VARCHAR(64)  NOT NULL,
+  source_key   VARCHAR(128) NOT NULL,
+  version_date TIMESTAMPTZ  NOT NULL,
+  error        TEXT         NOT NULL,
+  payload      TEXT,
+  first_seen   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  last_seen    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  attempts     INTEGER      NOT NULL DEFAULT 1,
+  dismissed_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_etl_quarantine_source ON etl_quarantine(job, source_table, source_key, version_date);
+CREATE INDEX IF NOT EXISTS etl_quarantine_last_seen_idx ON etl_quarantine(last_seen);
+
+COMMENT ON TABLE etl_quarantine IS 'Строки источников, которые не удалось разобрать. Пропускаются при загрузке до повторной обработки.';
+COMMENT ON COLUMN etl_quarantine.source_key IS 'Ключ строки в источнике (order_id).';
+COMMENT ON COLUMN etl_quarantine.version_date IS 'Версия строки в источнике (changing_date для orders, version_date для orders_log).';
+COMMENT ON COLUMN etl_quarantine.dismissed_at IS 'Дата ручного закрытия записи без повторной обработки.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS etl_quarantine;
@@ -0,0 +1,23 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS payload_schema_profile (
+  job          VARCHAR(64)  NOT NULL,
+  source_table VARCHAR(64)  NOT NULL,
+  path         VARCHAR(256) NOT NULL,
+  json_type    VARCHAR(16)  NOT NULL,
+  first_seen   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  last_seen    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  seen_count   BIGINT       NOT NULL DEFAULT 0,
+  PRIMARY KEY (job, source_table, path, json_type)
+);
+
+CREATE INDEX IF NOT EXISTS payload_schema_profile_last_seen_idx ON payload_schema_profile(last_seen);
+
+COMMENT ON TABLE payload_schema_profile IS 'Профиль путей JSON-полей источников (order_data, order_data_log) для обнаружения изменений схемы.';
+COMMENT ON COLUMN payload_schema_profile.path IS 'Путь поля через точку, элементы массивов обозначаются суффиксом [] (portationNumbers[].msisdn).';
+COMMENT ON COLUMN payload_schema_profile.json_type IS 'Тип значения JSON: object, array, string, number, boolean, null.';
+COMMENT ON COLUMN payload_schema_profile.seen_count IS 'Количество строк источника, в которых встречался путь.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS payload_schema_profile;
@@ -0,0 +1,48 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS reconciliation_result (
+  id                  BIGSERIAL PRIMARY KEY,
+  run_at              TIMESTAMPTZ  NOT NULL,
+  job                 VARCHAR(64)  NOT NULL,
+  check_name          VARCHAR(64)  NOT NULL,
+  business_day        DATE         NOT NULL,
+  status              VARCHAR(32)  NOT NULL DEFAULT '',
+  source_count        INTEGER      NOT NULL DEFAULT 0,
+  target_count        INTEGER      NOT NULL DEFAULT 0,
+  missing_count       INTEGER      NOT NULL DEFAULT 0,
+  extra_count         INTEGER      NOT NULL DEFAULT 0,
+  checksum_sampled    INTEGER      NOT NULL DEFAULT 0,
+  checksum_mismatched INTEGER      NOT NULL DEFAULT 0,
+  mismatched_keys     TEXT[]       NOT NULL DEFAULT '{}',
+  backfill_enqueued   BOOLEAN      NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS reconciliation_result_run_at_idx ON reconciliation_result(run_at);
+CREATE INDEX IF NOT EXISTS reconciliation_result_check_day_idx ON reconciliation_result(check_name, business_day);
+
+COMMENT ON TABLE reconciliation_result IS 'Результаты сверки источников с витриной по дням и статусам.';
+COMMENT ON COLUMN reconciliation_result.job IS 'Ключ блокировки джобы, которая загружает сверяемые данные и выполняет backfill.';
+COMMENT ON COLUMN reconciliation_result.business_day IS 'День в зоне BUSINESS_TIMEZONE.';
+COMMENT ON COLUMN reconciliation_result.status IS 'request_status_id витрины, пусто для сверок без статуса.';
+COMMENT ON COLUMN reconciliation_result.missing_count IS 'Ключи источника, которых нет в витрине или у которых отличается статус.';
+COMMENT ON COLUMN reconciliation_result.extra_count IS 'Ключи витрины, которых нет в источнике за период.';
+COMMENT ON COLUMN reconciliation_result.mismatched_keys IS 'Первые расхождения (ключи источника), не более 100.';
+
+CREATE TABLE IF NOT EXISTS etl_backfill (
+  job          VARCHAR(64)  NOT NULL,
+  source_key   VARCHAR(128) NOT NULL,
+  reason       VARCHAR(64)  NOT NULL,
+  enqueued_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+  processed_at TIMESTAMPTZ,
+  PRIMARY KEY (job, source_key)
+);
+
+CREATE INDEX IF NOT EXISTS etl_backfill_pending_idx ON etl_backfill(job, enqueued_at) WHERE processed_at IS NULL;
+
+COMMENT ON TABLE etl_backfill IS 'Ключи источника для повторной загрузки, обрабатываются джобой job при следующем запуске.';
+COMMENT ON COLUMN etl_backfill.reason IS 'Источник постановки в очередь (например, имя проверки сверки).';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS etl_backfill;
+DROP TABLE IF EXISTS reconciliation_result;
@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE etl_state ADD COLUMN IF NOT EXISTS snapshot_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN etl_state.snapshot_at IS 'Время снимка источника (REPEATABLE READ), на котором выполнен последний успешный запуск.';
+
+-- +goose Down
+
+ALTER TABLE etl_state DROP COLUMN IF EXISTS snapshot_at;
@@ -0,0 +1,17 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS cdc_position (
+  slot_name  VARCHAR(64) PRIMARY KEY,
+  job        VARCHAR(64) NOT NULL,
+  lsn        PG_LSN      NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE cdc_position IS 'Позиции чтения слотов логической репликации источников.';
+COMMENT ON COLUMN cdc_position.slot_name IS 'Имя слота в БД источника.';
+COMMENT ON COLUMN cdc_position.job IS 'Ключ блокировки джобы, которая читает слот.';
+COMMENT ON COLUMN cdc_position.lsn IS 'End LSN последней транзакции источника, примененной к витрине.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS cdc_position;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS export_offset (
+  consumer    VARCHAR(64) NOT NULL,
+  table_name  VARCHAR(64) NOT NULL,
+  change_date TIMESTAMPTZ NOT NULL,
+  id          BIGINT      NOT NULL,
+  export_id   VARCHAR(64) NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (consumer, table_name)
+);
+
+COMMENT ON TABLE export_offset IS 'Позиции файловой выгрузки таблиц витрины по потребителям.';
+COMMENT ON COLUMN export_offset.consumer IS 'Имя потребителя выгрузки.';
+COMMENT ON COLUMN export_offset.table_name IS 'Выгружаемая таблица витрины.';
+COMMENT ON COLUMN export_offset.change_date IS 'change_date последней выгруженной строки.';
+COMMENT ON COLUMN export_offset.id IS 'id последней выгруженной строки.';
+COMMENT ON COLUMN export_offset.export_id IS 'Каталог выгрузки, в которую попала строка.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS export_offset;
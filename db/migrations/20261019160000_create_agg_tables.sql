@@ -0,0 +1,58 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS agg_mnp_request (
+  business_day      DATE        NOT NULL,
+  request_status_id INTEGER,
+  process_type      VARCHAR(20),
+  subscriber_type   VARCHAR(20),
+  recipient_id      VARCHAR(50),
+  port_type         VARCHAR(20) NOT NULL,
+  request_cnt       INTEGER     NOT NULL,
+  phone_num_cnt     INTEGER     NOT NULL,
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS agg_mnp_request_business_day_idx ON agg_mnp_request(business_day);
+
+COMMENT ON TABLE agg_mnp_request IS 'Заявки по дням, статусам, типу процесса, типу абонента и реципиенту. Замена agg__sdwh_amnp_request.';
+COMMENT ON COLUMN agg_mnp_request.business_day IS 'День request_date (from_date, если request_date пуст) в зоне BUSINESS_TIMEZONE.';
+COMMENT ON COLUMN agg_mnp_request.recipient_id IS 'Реципиент заявки: минимальный recipient_id ее номеров req_number.';
+COMMENT ON COLUMN agg_mnp_request.request_cnt IS 'Число заявок mnp_request.';
+COMMENT ON COLUMN agg_mnp_request.phone_num_cnt IS 'Число номеров req_number этих заявок.';
+
+CREATE TABLE IF NOT EXISTS agg_mnp_request_error (
+  business_day      DATE        NOT NULL,
+  reject_reason     INTEGER     NOT NULL,
+  request_status_id INTEGER,
+  message_code      VARCHAR(50),
+  process_type      VARCHAR(20),
+  request_cnt       INTEGER     NOT NULL,
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS agg_mnp_request_error_business_day_idx ON agg_mnp_request_error(business_day);
+
+COMMENT ON TABLE agg_mnp_request_error IS 'Заявки с кодом отказа по дням и reject_reason. Замена agg__sdwh_amnp_request_error.';
+COMMENT ON COLUMN agg_mnp_request_error.business_day IS 'День request_date (from_date, если request_date пуст) в зоне BUSINESS_TIMEZONE.';
+COMMENT ON COLUMN agg_mnp_request_error.request_cnt IS 'Число заявок mnp_request с этим reject_reason.';
+
+CREATE TABLE IF NOT EXISTS agg_mnp_request_phone_num (
+  business_day      DATE        NOT NULL,
+  request_status_id INTEGER,
+  phone_num_cnt     INTEGER     NOT NULL,
+  request_cnt       INTEGER     NOT NULL,
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS agg_mnp_request_phone_num_business_day_idx ON agg_mnp_request_phone_num(business_day);
+
+COMMENT ON TABLE agg_mnp_request_phone_num IS 'Распределение заявок по числу номеров. Замена agg__sdwh_amnp_request_phone_num.';
+COMMENT ON COLUMN agg_mnp_request_phone_num.business_day IS 'День request_date (from_date, если request_date пуст) в зоне BUSINESS_TIMEZONE.';
+COMMENT ON COLUMN agg_mnp_request_phone_num.phone_num_cnt IS 'Число номеров req_number в заявке.';
+COMMENT ON COLUMN agg_mnp_request_phone_num.request_cnt IS 'Число заявок с phone_num_cnt номерами.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS agg_mnp_request_phone_num;
+DROP TABLE IF EXISTS agg_mnp_request_error;
+DROP TABLE IF EXISTS agg_mnp_request;
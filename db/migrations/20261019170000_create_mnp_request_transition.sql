@@ -0,0 +1,29 @@
+-- +goose Up
+
+CREATE TABLE IF NOT EXISTS mnp_request_transition (
+  id               BIGSERIAL   PRIMARY KEY,
+  order_number     VARCHAR(64) NOT NULL,
+  from_status      INTEGER,
+  to_status        INTEGER,
+  entered_at       TIMESTAMP   NOT NULL,
+  left_at          TIMESTAMP,
+  duration_seconds BIGINT,
+  change_date      TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_mnp_request_transition_order_entered ON mnp_request_transition(order_number, entered_at);
+CREATE INDEX IF NOT EXISTS mnp_request_transition_to_status_idx ON mnp_request_transition(to_status);
+CREATE INDEX IF NOT EXISTS mnp_request_transition_entered_at_idx ON mnp_request_transition(entered_at);
+CREATE INDEX IF NOT EXISTS mnp_request_transition_change_date_idx ON mnp_request_transition(change_date);
+
+COMMENT ON TABLE mnp_request_transition IS 'Смены request_status_id заявки по версиям mnp_request_h: одна строка на переход.';
+COMMENT ON COLUMN mnp_request_transition.from_status IS 'Статус до перехода, NULL для первой версии заявки.';
+COMMENT ON COLUMN mnp_request_transition.to_status IS 'Статус после перехода.';
+COMMENT ON COLUMN mnp_request_transition.entered_at IS 'from_date версии, с которой заявка в статусе to_status.';
+COMMENT ON COLUMN mnp_request_transition.left_at IS 'Начало следующего перехода или to_date последней версии. NULL - заявка в статусе сейчас.';
+COMMENT ON COLUMN mnp_request_transition.duration_seconds IS 'Время в статусе to_status, секунды. NULL, пока статус не сменился.';
+COMMENT ON COLUMN mnp_request_transition.change_date IS 'Время последнего изменения строки в зоне BUSINESS_TIMEZONE.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS mnp_request_transition;
@@ -0,0 +1,152 @@
+-- +goose Up
+
+ALTER TABLE mnp_request ADD COLUMN IF NOT EXISTS source_system VARCHAR(16) NOT NULL DEFAULT 'MNPHUB';
+ALTER TABLE mnp_request_h ADD COLUMN IF NOT EXISTS source_system VARCHAR(16) NOT NULL DEFAULT 'MNPHUB';
+ALTER TABLE req_number ADD COLUMN IF NOT EXISTS source_system VARCHAR(16) NOT NULL DEFAULT 'MNPHUB';
+ALTER TABLE mnp_raw_request ADD COLUMN IF NOT EXISTS source_system VARCHAR(16) NOT NULL DEFAULT 'MNPHUB';
+
+CREATE INDEX IF NOT EXISTS mnp_request_cdb_id_idx ON mnp_request(cdb_id);
+
+CREATE TABLE IF NOT EXISTS replica_mnp_request (
+  id                BIGSERIAL PRIMARY KEY,
+  order_number      VARCHAR(64) NOT NULL,
+  request_status_id INTEGER,
+  request_date      TIMESTAMP,
+  contract_date     TIMESTAMP,
+  port_date         TIMESTAMP,
+  from_date         TIMESTAMP,
+  to_date           TIMESTAMP,
+  change_date       TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted           INTEGER   NOT NULL DEFAULT 0,
+  cdb_id            VARCHAR(20),
+  process_type      VARCHAR(20),
+  port_type         VARCHAR(20) NOT NULL DEFAULT 'portin',
+  subscriber_type   VARCHAR(20),
+  message_code      VARCHAR(50),
+  reject_reason     INTEGER,
+  order_id          BIGINT NOT NULL,
+  source_system     VARCHAR(16) NOT NULL DEFAULT 'REPLICA'
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS replica_mnp_request_order_id_uk ON replica_mnp_request(order_id);
+CREATE INDEX IF NOT EXISTS replica_mnp_request_order_number_idx ON replica_mnp_request(order_number);
+CREATE INDEX IF NOT EXISTS replica_mnp_request_cdb_id_idx ON replica_mnp_request(cdb_id);
+
+CREATE TABLE IF NOT EXISTS replica_mnp_request_h (
+  id                BIGSERIAL PRIMARY KEY,
+  order_number      VARCHAR(64) NOT NULL,
+  request_status_id INTEGER,
+  request_date      TIMESTAMP,
+  contract_date     TIMESTAMP,
+  port_date         TIMESTAMP,
+  from_date         TIMESTAMP,
+  to_date           TIMESTAMP,
+  change_date       TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted           INTEGER   NOT NULL DEFAULT 0,
+  cdb_id            VARCHAR(20),
+  process_type      VARCHAR(20),
+  port_type         VARCHAR(20) NOT NULL DEFAULT 'portin',
+  subscriber_type   VARCHAR(20),
+  message_code      VARCHAR(50),
+  reject_reason     INTEGER,
+  order_id          BIGINT NOT NULL,
+  source_system     VARCHAR(16) NOT NULL DEFAULT 'REPLICA'
+);
+
+CREATE INDEX IF NOT EXISTS replica_mnp_request_h_order_id_idx ON replica_mnp_request_h(order_id);
+
+CREATE TABLE IF NOT EXISTS replica_req_number (
+  id            BIGSERIAL PRIMARY KEY,
+  req_id        VARCHAR(64) NOT NULL,
+  recipient_id  VARCHAR(50),
+  msisdn        VARCHAR(20) NOT NULL,
+  rn            CHAR(5),
+  change_date   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  source_system VARCHAR(16) NOT NULL DEFAULT 'REPLICA'
+);
+
+CREATE INDEX IF NOT EXISTS replica_req_number_req_id_idx ON replica_req_number(req_id);
+
+CREATE TABLE IF NOT EXISTS replica_mnp_raw_request (
+  id             BIGINT PRIMARY KEY,
+  req_id         VARCHAR(64),
+  request_time   TIMESTAMP,
+  xml_message    TEXT,
+  operation_info VARCHAR(50),
+  system_source  VARCHAR(50),
+  system_dest    VARCHAR(50),
+  change_date    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  source_system  VARCHAR(16) NOT NULL DEFAULT 'REPLICA'
+);
+
+CREATE INDEX IF NOT EXISTS replica_mnp_raw_request_req_id_idx ON replica_mnp_raw_request(req_id);
+
+COMMENT ON TABLE replica_mnp_request IS 'Заявки Replica ESB в формате mnp_request. order_id - ID заявки в Replica.';
+COMMENT ON TABLE replica_mnp_request_h IS 'История заявок Replica ESB в формате mnp_request_h.';
+COMMENT ON TABLE replica_req_number IS 'Номера заявок Replica ESB в формате req_number, req_id - order_number заявки.';
+COMMENT ON TABLE replica_mnp_raw_request IS 'Сообщения БДПН Replica ESB в формате mnp_raw_request.';
+
+-- Заявки Replica, которых нет в MNPHUB: совпадение по order_number или cdb_id означает ту же заявку,
+-- и в объединенных представлениях остается только версия MNPHUB.
+CREATE OR REPLACE VIEW replica_mnp_request_unique AS
+SELECT r.* FROM replica_mnp_request r
+WHERE NOT EXISTS (SELECT 1 FROM mnp_request m WHERE m.order_number = r.order_number)
+  AND (COALESCE(r.cdb_id, '') = '' OR NOT EXISTS (SELECT 1 FROM mnp_request m WHERE m.cdb_id = r.cdb_id));
+
+CREATE OR REPLACE VIEW v_mnp_request AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request
+UNION ALL
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM replica_mnp_request_unique;
+
+CREATE OR REPLACE VIEW v_mnp_request_h AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request_h
+UNION ALL
+SELECT h.id, h.order_number, h.request_status_id, h.request_date, h.contract_date, h.port_date, h.from_date, h.to_date,
+  h.change_date, h.deleted, h.cdb_id, h.process_type, h.port_type, h.subscriber_type, h.message_code, h.reject_reason,
+  h.order_id, h.source_system
+FROM replica_mnp_request_h h
+WHERE h.order_id IN (SELECT order_id FROM replica_mnp_request_unique);
+
+CREATE OR REPLACE VIEW v_req_number AS
+SELECT id, req_id, recipient_id, msisdn, rn, change_date, source_system FROM req_number
+UNION ALL
+SELECT n.id, n.req_id, n.recipient_id, n.msisdn, n.rn, n.change_date, n.source_system
+FROM replica_req_number n
+WHERE n.req_id IN (SELECT order_number FROM replica_mnp_request_unique);
+
+CREATE OR REPLACE VIEW v_mnp_raw_request AS
+SELECT id, req_id, request_time, xml_message, operation_info, system_source, system_dest, change_date, source_system
+FROM mnp_raw_request
+UNION ALL
+SELECT m.id, m.req_id, m.request_time, m.xml_message, m.operation_info, m.system_source, m.system_dest, m.change_date,
+  m.source_system
+FROM replica_mnp_raw_request m
+WHERE m.req_id IN (SELECT order_number FROM replica_mnp_request_unique);
+
+COMMENT ON VIEW v_mnp_request IS 'Заявки MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+COMMENT ON VIEW v_mnp_request_h IS 'История заявок MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+COMMENT ON VIEW v_req_number IS 'Номера заявок MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+COMMENT ON VIEW v_mnp_raw_request IS 'Сообщения БДПН MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+
+-- +goose Down
+
+DROP VIEW IF EXISTS v_mnp_raw_request;
+DROP VIEW IF EXISTS v_req_number;
+DROP VIEW IF EXISTS v_mnp_request_h;
+DROP VIEW IF EXISTS v_mnp_request;
+DROP VIEW IF EXISTS replica_mnp_request_unique;
+DROP TABLE IF EXISTS replica_mnp_raw_request;
+DROP TABLE IF EXISTS replica_req_number;
+DROP TABLE IF EXISTS replica_mnp_request_h;
+DROP TABLE IF EXISTS replica_mnp_request;
+DROP INDEX IF EXISTS mnp_request_cdb_id_idx;
+ALTER TABLE mnp_raw_request DROP COLUMN IF EXISTS source_system;
+ALTER TABLE req_number DROP COLUMN IF EXISTS source_system;
+ALTER TABLE mnp_request_h DROP COLUMN IF EXISTS source_system;
+ALTER TABLE mnp_request DROP COLUMN IF EXISTS source_system;
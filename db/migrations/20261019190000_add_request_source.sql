@@ -0,0 +1,26 @@
+-- +goose Up
+
+ALTER TABLE mnp_request ADD COLUMN IF NOT EXISTS source VARCHAR(32) NOT NULL DEFAULT 'default';
+ALTER TABLE mnp_request_h ADD COLUMN IF NOT EXISTS source VARCHAR(32) NOT NULL DEFAULT 'default';
+
+COMMENT ON COLUMN mnp_request.source IS 'Установка MNPHUB, из которой загружена заявка. order_id уникален в пределах источника.';
+COMMENT ON COLUMN mnp_request_h.source IS 'Установка MNPHUB, из которой загружена версия заявки.';
+
+DROP INDEX IF EXISTS mnp_request_port_type_order_id_uk;
+CREATE UNIQUE INDEX IF NOT EXISTS mnp_request_source_port_type_order_id_uk ON mnp_request(source, port_type, order_id);
+CREATE INDEX IF NOT EXISTS mnp_request_source_from_date_idx ON mnp_request(source, from_date);
+
+DROP INDEX IF EXISTS ux_mnp_request_h_order_ver;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_mnp_request_h_source_order_ver ON mnp_request_h(source, order_id, from_date);
+
+-- +goose Down
+
+DROP INDEX IF EXISTS ux_mnp_request_h_source_order_ver;
+CREATE UNIQUE INDEX IF NOT EXISTS ux_mnp_request_h_order_ver ON mnp_request_h(order_id, from_date);
+
+DROP INDEX IF EXISTS mnp_request_source_from_date_idx;
+DROP INDEX IF EXISTS mnp_request_source_port_type_order_id_uk;
+CREATE UNIQUE INDEX IF NOT EXISTS mnp_request_port_type_order_id_uk ON mnp_request(port_type, order_id);
+
+ALTER TABLE mnp_request_h DROP COLUMN IF EXISTS source;
+ALTER TABLE mnp_request DROP COLUMN IF EXISTS source;
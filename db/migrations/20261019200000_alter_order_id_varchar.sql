@@ -0,0 +1,69 @@
+-- +goose Up
+
+-- Представления ссылаются на order_id и пересоздаются после смены типа.
+DROP VIEW IF EXISTS v_mnp_request_h;
+DROP VIEW IF EXISTS v_mnp_request;
+
+ALTER TABLE mnp_request ALTER COLUMN order_id TYPE VARCHAR(64) USING order_id::text;
+ALTER TABLE mnp_request_h ALTER COLUMN order_id TYPE VARCHAR(64) USING order_id::text;
+
+COMMENT ON COLUMN mnp_request.order_id IS 'Идентификатор заявки в источнике: целое число или GUID в текстовом виде.';
+COMMENT ON COLUMN mnp_request_h.order_id IS 'Идентификатор заявки в источнике: целое число или GUID в текстовом виде.';
+
+CREATE OR REPLACE VIEW v_mnp_request AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request
+UNION ALL
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id::varchar(64), source_system
+FROM replica_mnp_request_unique;
+
+CREATE OR REPLACE VIEW v_mnp_request_h AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request_h
+UNION ALL
+SELECT h.id, h.order_number, h.request_status_id, h.request_date, h.contract_date, h.port_date, h.from_date, h.to_date,
+  h.change_date, h.deleted, h.cdb_id, h.process_type, h.port_type, h.subscriber_type, h.message_code, h.reject_reason,
+  h.order_id::varchar(64), h.source_system
+FROM replica_mnp_request_h h
+WHERE h.order_id IN (SELECT order_id FROM replica_mnp_request_unique);
+
+COMMENT ON VIEW v_mnp_request IS 'Заявки MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+COMMENT ON VIEW v_mnp_request_h IS 'История заявок MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+
+-- +goose Down
+
+-- Откат возможен, только пока в витрине нет заявок с GUID.
+DROP VIEW IF EXISTS v_mnp_request_h;
+DROP VIEW IF EXISTS v_mnp_request;
+
+ALTER TABLE mnp_request_h ALTER COLUMN order_id TYPE BIGINT USING order_id::bigint;
+ALTER TABLE mnp_request ALTER COLUMN order_id TYPE BIGINT USING order_id::bigint;
+
+COMMENT ON COLUMN mnp_request_h.order_id IS NULL;
+COMMENT ON COLUMN mnp_request.order_id IS NULL;
+
+CREATE OR REPLACE VIEW v_mnp_request AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request
+UNION ALL
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM replica_mnp_request_unique;
+
+CREATE OR REPLACE VIEW v_mnp_request_h AS
+SELECT id, order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date, change_date,
+  deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source_system
+FROM mnp_request_h
+UNION ALL
+SELECT h.id, h.order_number, h.request_status_id, h.request_date, h.contract_date, h.port_date, h.from_date, h.to_date,
+  h.change_date, h.deleted, h.cdb_id, h.process_type, h.port_type, h.subscriber_type, h.message_code, h.reject_reason,
+  h.order_id, h.source_system
+FROM replica_mnp_request_h h
+WHERE h.order_id IN (SELECT order_id FROM replica_mnp_request_unique);
+
+COMMENT ON VIEW v_mnp_request IS 'Заявки MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+COMMENT ON VIEW v_mnp_request_h IS 'История заявок MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
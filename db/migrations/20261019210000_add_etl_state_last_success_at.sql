@@ -0,0 +1,9 @@
+-- +goose Up
+
+ALTER TABLE etl_state ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN etl_state.last_success_at IS 'Время последнего успешного запуска джобы job_name на любом поде. Используется /health/data.';
+
+-- +goose Down
+
+ALTER TABLE etl_state DROP COLUMN IF EXISTS last_success_at;
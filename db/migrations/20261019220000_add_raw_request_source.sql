@@ -0,0 +1,42 @@
+-- +goose Up
+
+ALTER TABLE mnp_raw_request ADD COLUMN IF NOT EXISTS source VARCHAR(32) NOT NULL DEFAULT 'default';
+
+COMMENT ON COLUMN mnp_raw_request.source IS 'Установка MNPHUB, из cdb-messaging-db которой загружено сообщение. id уникален в пределах источника.';
+
+-- message_id разных установок пересекаются.
+ALTER TABLE mnp_raw_request DROP CONSTRAINT IF EXISTS mnp_raw_request_pkey;
+ALTER TABLE mnp_raw_request ADD CONSTRAINT mnp_raw_request_pkey PRIMARY KEY (source, id);
+CREATE INDEX IF NOT EXISTS mnp_raw_request_source_request_time_idx ON mnp_raw_request(source, request_time);
+
+CREATE OR REPLACE VIEW v_mnp_raw_request AS
+SELECT id, req_id, request_time, xml_message, operation_info, system_source, system_dest, change_date, source_system,
+  source
+FROM mnp_raw_request
+UNION ALL
+SELECT m.id, m.req_id, m.request_time, m.xml_message, m.operation_info, m.system_source, m.system_dest, m.change_date,
+  m.source_system, NULL::varchar(32)
+FROM replica_mnp_raw_request m
+WHERE m.req_id IN (SELECT order_number FROM replica_mnp_request_unique);
+
+COMMENT ON VIEW v_mnp_raw_request IS 'Сообщения БДПН MNPHUB и Replica без повторов. Ключ строки - (source_system, source, id).';
+
+-- +goose Down
+
+-- Откат возможен, только пока id сообщений разных источников не пересекаются.
+DROP VIEW IF EXISTS v_mnp_raw_request;
+CREATE VIEW v_mnp_raw_request AS
+SELECT id, req_id, request_time, xml_message, operation_info, system_source, system_dest, change_date, source_system
+FROM mnp_raw_request
+UNION ALL
+SELECT m.id, m.req_id, m.request_time, m.xml_message, m.operation_info, m.system_source, m.system_dest, m.change_date,
+  m.source_system
+FROM replica_mnp_raw_request m
+WHERE m.req_id IN (SELECT order_number FROM replica_mnp_request_unique);
+
+COMMENT ON VIEW v_mnp_raw_request IS 'Сообщения БДПН MNPHUB и Replica без повторов. Ключ строки - (source_system, id).';
+
+DROP INDEX IF EXISTS mnp_raw_request_source_request_time_idx;
+ALTER TABLE mnp_raw_request DROP CONSTRAINT IF EXISTS mnp_raw_request_pkey;
+ALTER TABLE mnp_raw_request ADD CONSTRAINT mnp_raw_request_pkey PRIMARY KEY (id);
+ALTER TABLE mnp_raw_request DROP COLUMN IF EXISTS source;
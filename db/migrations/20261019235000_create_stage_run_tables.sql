@@ -0,0 +1,53 @@
+-- +goose Up
+
+CREATE UNLOGGED TABLE IF NOT EXISTS stage_run (
+  id         BIGSERIAL   PRIMARY KEY,
+  job        VARCHAR(64) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stage_run_job_idx ON stage_run(job);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS stage_run_request (
+  id                BIGSERIAL   PRIMARY KEY,
+  run_id            BIGINT      NOT NULL REFERENCES stage_run(id) ON DELETE CASCADE,
+  order_number      VARCHAR(64) NOT NULL,
+  request_status_id INTEGER,
+  request_date      TIMESTAMP,
+  contract_date     TIMESTAMP,
+  port_date         TIMESTAMP,
+  from_date         TIMESTAMP,
+  to_date           TIMESTAMP,
+  cdb_id            VARCHAR(20),
+  process_type      VARCHAR(20),
+  port_type         VARCHAR(20) NOT NULL,
+  subscriber_type   VARCHAR(20),
+  message_code      VARCHAR(50),
+  reject_reason     INTEGER,
+  order_id          VARCHAR(64) NOT NULL,
+  source            VARCHAR(32) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS stage_run_request_run_id_idx ON stage_run_request(run_id);
+
+CREATE UNLOGGED TABLE IF NOT EXISTS stage_run_req_number (
+  id           BIGSERIAL   PRIMARY KEY,
+  run_id       BIGINT      NOT NULL REFERENCES stage_run(id) ON DELETE CASCADE,
+  req_id       VARCHAR(64) NOT NULL,
+  recipient_id VARCHAR(50),
+  msisdn       VARCHAR(20) NOT NULL,
+  rn           CHAR(5)
+);
+
+CREATE INDEX IF NOT EXISTS stage_run_req_number_run_id_idx ON stage_run_req_number(run_id);
+
+COMMENT ON TABLE stage_run IS 'Запуски, которые готовят пачку параллельно. Строки staging удаляются вместе с запуском.';
+COMMENT ON COLUMN stage_run.job IS 'Ключ блокировки джобы. Незавершенные запуски джобы удаляются при следующем запуске.';
+COMMENT ON TABLE stage_run_request IS 'Заявки, подготовленные воркерами запуска run_id до слияния в mnp_request и mnp_request_h.';
+COMMENT ON TABLE stage_run_req_number IS 'Номера заявок, подготовленные воркерами запуска run_id до слияния в req_number.';
+
+-- +goose Down
+
+DROP TABLE IF EXISTS stage_run_req_number;
+DROP TABLE IF EXISTS stage_run_request;
+DROP TABLE IF EXISTS stage_run;
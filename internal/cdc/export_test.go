@@ -0,0 +1,9 @@
+package cdc
+
+// Доступ к разбору pgoutput для тестов cdc_test.
+
+type Decoder = decoder
+
+func NewDecoder() *Decoder { return newDecoder() }
+
+func (d *decoder) Decode(msg []byte) ([]Change, LSN, bool, error) { return d.decode(msg) }
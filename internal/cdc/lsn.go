@@ -0,0 +1,35 @@
+package cdc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LSN - позиция в WAL PostgreSQL.
+type LSN uint64
+
+// ParseLSN разбирает LSN в текстовом виде pg_lsn (X/Y). Пустая строка - нулевая позиция.
+func ParseLSN(s string) (LSN, error) {
+	if s == "" {
+		return 0, nil
+	}
+	hi, lo, ok := strings.Cut(s, "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid lsn %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", s, err)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %q: %w", s, err)
+	}
+
+	return LSN(h<<32 | l), nil
+}
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
@@ -0,0 +1,221 @@
+package cdc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Op - тип изменения строки.
+type Op string
+
+const (
+	OpInsert Op = "insert"
+	OpUpdate Op = "update"
+	OpDelete Op = "delete"
+)
+
+var errShortMessage = errors.New("pgoutput message is too short")
+
+// Change - изменение строки таблицы публикации.
+// Values - новые значения колонок в текстовом виде (для delete - ключ или старая строка).
+// NULL и неизмененные TOAST-значения в Values отсутствуют.
+type Change struct {
+	Schema string
+	Table  string
+	Op     Op
+	Values map[string]string
+}
+
+// Int64 возвращает целочисленное значение колонки.
+func (c Change) Int64(column string) (int64, bool) {
+	v, ok := c.Values[column]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+
+	return n, err == nil
+}
+
+type relation struct {
+	schema  string
+	table   string
+	columns []string
+}
+
+// decoder разбирает сообщения pgoutput версии 1 и собирает изменения одной транзакции.
+type decoder struct {
+	relations map[uint32]relation
+	inTx      bool
+	changes   []Change
+}
+
+func newDecoder() *decoder {
+	return &decoder{relations: make(map[uint32]relation)}
+}
+
+// decode обрабатывает сообщение pgoutput. На commit возвращает изменения транзакции и ее end LSN.
+func (d *decoder) decode(msg []byte) ([]Change, LSN, bool, error) {
+	if len(msg) == 0 {
+		return nil, 0, false, errShortMessage
+	}
+	r := &reader{buf: msg[1:]}
+
+	switch msg[0] {
+	case 'B':
+		d.inTx = true
+		d.changes = nil
+	case 'C':
+		r.byte()   // flags
+		r.uint64() // commit LSN
+		end := LSN(r.uint64())
+		r.uint64() // commit timestamp
+		if r.err != nil {
+			return nil, 0, false, r.err
+		}
+		changes := d.changes
+		d.inTx = false
+		d.changes = nil
+
+		return changes, end, true, nil
+	case 'R':
+		id := r.uint32()
+		namespace := r.string()
+		name := r.string()
+		r.byte() // replica identity
+		n := int(r.uint16())
+		cols := make([]string, 0, n)
+		for range n {
+			r.byte() // flags
+			cols = append(cols, r.string())
+			r.uint32() // type oid
+			r.uint32() // type modifier
+		}
+		if r.err != nil {
+			return nil, 0, false, r.err
+		}
+		d.relations[id] = relation{schema: namespace, table: name, columns: cols}
+	case 'I':
+		return nil, 0, false, d.row(r, OpInsert)
+	case 'U':
+		return nil, 0, false, d.row(r, OpUpdate)
+	case 'D':
+		return nil, 0, false, d.row(r, OpDelete)
+	}
+
+	return nil, 0, false, nil
+}
+
+func (d *decoder) row(r *reader, op Op) error {
+	id := r.uint32()
+	rel, ok := d.relations[id]
+	if r.err == nil && !ok {
+		return fmt.Errorf("pgoutput relation %d is unknown", id)
+	}
+
+	var values map[string]string
+	for r.err == nil {
+		kind := r.byte()
+		values = r.tuple(rel.columns)
+		// В update перед новой строкой может идти ключ ('K') или старая строка ('O').
+		if kind == 'N' || op == OpDelete {
+			break
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	d.changes = append(d.changes, Change{Schema: rel.schema, Table: rel.table, Op: op, Values: values})
+
+	return nil
+}
+
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+
+	return b
+}
+
+func (r *reader) byte() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+
+	return 0
+}
+
+func (r *reader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+
+	return 0
+}
+
+func (r *reader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.buf, 0)
+	if i < 0 {
+		r.err = errShortMessage
+		return ""
+	}
+	s := string(r.buf[:i])
+	r.buf = r.buf[i+1:]
+
+	return s
+}
+
+func (r *reader) tuple(columns []string) map[string]string {
+	n := int(r.uint16())
+	values := make(map[string]string, n)
+	for i := range n {
+		switch r.byte() {
+		case 't':
+			v := r.next(int(r.uint32()))
+			if r.err == nil && i < len(columns) {
+				values[columns[i]] = string(v)
+			}
+		case 'n', 'u':
+		default:
+			if r.err == nil {
+				r.err = errors.New("pgoutput tuple has unsupported column kind")
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,171 @@
+package cdc_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+)
+
+// unchanged - значение неизмененной TOAST-колонки в tuple.
+type unchanged struct{}
+
+// message собирает сообщение pgoutput версии 1.
+type message []byte
+
+func newMessage(kind byte) message { return message{kind} }
+
+func (m message) byte(b byte) message { return append(m, b) }
+
+func (m message) uint16(v uint16) message { return binary.BigEndian.AppendUint16(m, v) }
+
+func (m message) uint32(v uint32) message { return binary.BigEndian.AppendUint32(m, v) }
+
+func (m message) uint64(v uint64) message { return binary.BigEndian.AppendUint64(m, v) }
+
+func (m message) string(s string) message { return append(append(m, s...), 0) }
+
+// tuple добавляет TupleData: string - текстовое значение, nil - NULL, unchanged{} - неизмененный TOAST.
+func (m message) tuple(values ...any) message {
+	m = m.uint16(uint16(len(values)))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			m = m.byte('t').uint32(uint32(len(v)))
+			m = append(m, v...)
+		case unchanged:
+			m = m.byte('u')
+		default:
+			m = m.byte('n')
+		}
+	}
+
+	return m
+}
+
+func relationMessage(id uint32, schema, table string, columns ...string) message {
+	m := newMessage('R').uint32(id).string(schema).string(table).byte('d').uint16(uint16(len(columns)))
+	for _, c := range columns {
+		m = m.byte(1).string(c).uint32(25).uint32(0xFFFFFFFF)
+	}
+
+	return m
+}
+
+func beginMessage() message {
+	return newMessage('B').uint64(0x10).uint64(0).uint32(1)
+}
+
+func commitMessage(end uint64) message {
+	return newMessage('C').byte(0).uint64(end - 8).uint64(end).uint64(0)
+}
+
+func TestDecoder(t *testing.T) {
+	const rel = 16385
+
+	relation := relationMessage(rel, "public", "orders", "order_id", "state", "order_data")
+	insert := newMessage('I').uint32(rel).byte('N').tuple("1", "0", "{}")
+	updateKey := newMessage('U').uint32(rel).byte('K').tuple("1", nil, nil).byte('N').tuple("1", "2", unchanged{})
+	updateOld := newMessage('U').uint32(rel).byte('O').tuple("1", "0", "{}").byte('N').tuple("1", nil, "{\"a\":1}")
+	updateNew := newMessage('U').uint32(rel).byte('N').tuple("2", "1", "{}")
+	deleteKey := newMessage('D').uint32(rel).byte('K').tuple("1", nil, nil)
+	deleteOld := newMessage('D').uint32(rel).byte('O').tuple("2", "1", "{}")
+
+	decodeAll := func(t *testing.T, d *cdc.Decoder, msgs ...message) ([]cdc.Change, cdc.LSN, bool) {
+		t.Helper()
+		var (
+			changes []cdc.Change
+			end     cdc.LSN
+			commit  bool
+		)
+		for _, m := range msgs {
+			var err error
+			changes, end, commit, err = d.Decode(m)
+			require.NoError(t, err)
+		}
+
+		return changes, end, commit
+	}
+
+	t.Run("transaction", func(t *testing.T) {
+		changes, end, commit := decodeAll(t, cdc.NewDecoder(),
+			relation, beginMessage(), insert, updateKey, updateOld, updateNew, deleteKey, deleteOld, commitMessage(0x100))
+		require.True(t, commit)
+		require.Equal(t, cdc.LSN(0x100), end)
+
+		change := func(op cdc.Op, values map[string]string) cdc.Change {
+			return cdc.Change{Schema: "public", Table: "orders", Op: op, Values: values}
+		}
+		require.Equal(t, []cdc.Change{
+			change(cdc.OpInsert, map[string]string{"order_id": "1", "state": "0", "order_data": "{}"}),
+			change(cdc.OpUpdate, map[string]string{"order_id": "1", "state": "2"}),
+			change(cdc.OpUpdate, map[string]string{"order_id": "1", "order_data": "{\"a\":1}"}),
+			change(cdc.OpUpdate, map[string]string{"order_id": "2", "state": "1", "order_data": "{}"}),
+			change(cdc.OpDelete, map[string]string{"order_id": "1"}),
+			change(cdc.OpDelete, map[string]string{"order_id": "2", "state": "1", "order_data": "{}"}),
+		}, changes)
+
+		n, ok := changes[0].Int64("order_id")
+		require.True(t, ok)
+		require.Equal(t, int64(1), n)
+		_, ok = changes[4].Int64("state")
+		require.False(t, ok)
+	})
+
+	t.Run("changes are returned only on commit", func(t *testing.T) {
+		d := cdc.NewDecoder()
+		changes, _, commit := decodeAll(t, d, relation, beginMessage(), insert)
+		require.False(t, commit)
+		require.Empty(t, changes)
+
+		changes, _, commit = decodeAll(t, d, commitMessage(0x200))
+		require.True(t, commit)
+		require.Len(t, changes, 1)
+
+		changes, _, commit = decodeAll(t, d, beginMessage(), commitMessage(0x300))
+		require.True(t, commit)
+		require.Empty(t, changes)
+	})
+
+	t.Run("unsupported message is skipped", func(t *testing.T) {
+		changes, _, commit := decodeAll(t, cdc.NewDecoder(), newMessage('Y').uint32(1).string("pg_catalog").string("text"))
+		require.False(t, commit)
+		require.Empty(t, changes)
+	})
+
+	t.Run("unknown relation", func(t *testing.T) {
+		d := cdc.NewDecoder()
+		decodeAll(t, d, relation, beginMessage())
+		_, _, _, err := d.Decode(newMessage('I').uint32(rel + 1).byte('N').tuple("1"))
+		require.ErrorContains(t, err, "relation 16386 is unknown")
+	})
+
+	t.Run("unsupported column kind", func(t *testing.T) {
+		d := cdc.NewDecoder()
+		decodeAll(t, d, relation, beginMessage())
+		_, _, _, err := d.Decode(newMessage('I').uint32(rel).byte('N').uint16(1).byte('b'))
+		require.ErrorContains(t, err, "unsupported column kind")
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for name, m := range map[string]message{
+			"relation":        relation,
+			"insert":          insert,
+			"update with key": updateKey,
+			"delete":          deleteOld,
+			"commit":          commitMessage(0x100),
+		} {
+			for n := range len(m) - 1 {
+				d := cdc.NewDecoder()
+				decodeAll(t, d, relation, beginMessage())
+				_, _, _, err := d.Decode(m[:n+1])
+				require.ErrorContains(t, err, "too short", "%s cut to %d bytes", name, n+1)
+			}
+		}
+
+		_, _, _, err := cdc.NewDecoder().Decode(nil)
+		require.ErrorContains(t, err, "too short")
+	})
+}
@@ -0,0 +1,247 @@
+package cdc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+)
+
+// Config - слот логической репликации (pgoutput) источника.
+type Config struct {
+	// ConnString - строка подключения к источнику, режим репликации добавляется автоматически.
+	// Пользователю нужна роль REPLICATION.
+	ConnString  string
+	Slot        string
+	Publication string
+	// MaxChanges - после скольких изменений пачка применяется и подтверждается.
+	MaxChanges int
+	// IdleTimeout - сколько ждать новых сообщений, прежде чем завершить чтение.
+	IdleTimeout time.Duration
+}
+
+// Batch - изменения подтвержденных транзакций источника до EndLSN.
+type Batch struct {
+	Changes []Change
+	EndLSN  LSN
+}
+
+// ApplyFunc применяет пачку. EndLSN сохраняется в витрине в той же транзакции,
+// поэтому после сбоя чтение продолжается с последней примененной позиции.
+type ApplyFunc func(ctx context.Context, b Batch) error
+
+var identRe = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Validate проверяет имена слота и публикации: они подставляются в команды репликации.
+func (c Config) Validate() error {
+	if !identRe.MatchString(c.Slot) {
+		return fmt.Errorf("invalid replication slot name %q", c.Slot)
+	}
+	if !identRe.MatchString(c.Publication) {
+		return fmt.Errorf("invalid publication name %q", c.Publication)
+	}
+
+	return nil
+}
+
+// CreateSlot создает слот, если его еще нет, и возвращает позицию, с которой слот хранит изменения.
+// Изменения, сделанные до этой позиции, слот не содержит: их нужно загрузить опросом источника.
+func CreateSlot(ctx context.Context, cfg Config) (LSN, error) {
+	if err := cfg.Validate(); err != nil {
+		return 0, err
+	}
+
+	conn, err := pgconn.Connect(ctx, cfg.ConnString+" replication=database")
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(context.Background()) //nolint:errcheck
+
+	res, err := conn.Exec(ctx, fmt.Sprintf(`CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT`, cfg.Slot)).ReadAll()
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42710" {
+		res, err = conn.Exec(ctx, fmt.Sprintf(
+			`SELECT slot_name, confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = '%s'`, cfg.Slot)).ReadAll()
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 || len(res[0].Rows) == 0 || len(res[0].Rows[0]) < 2 {
+		return 0, fmt.Errorf("unexpected result for replication slot %s", cfg.Slot)
+	}
+
+	return ParseLSN(string(res[0].Rows[0][1]))
+}
+
+// Drain читает слот с позиции from до конца WAL на момент подключения и передает изменения
+// пачками в apply. После успешного apply позиция подтверждается серверу, и слот освобождает WAL.
+// Слот должен быть создан CreateSlot. Возвращает последнюю примененную позицию.
+func Drain(ctx context.Context, cfg Config, from LSN, apply ApplyFunc) (LSN, error) {
+	if err := cfg.Validate(); err != nil {
+		return from, err
+	}
+	if cfg.MaxChanges <= 0 {
+		cfg.MaxChanges = 10000
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Second
+	}
+
+	conn, err := pgconn.Connect(ctx, cfg.ConnString+" replication=database")
+	if err != nil {
+		return from, err
+	}
+	defer conn.Close(context.Background()) //nolint:errcheck
+
+	walEnd, err := identifySystem(ctx, conn)
+	if err != nil {
+		return from, err
+	}
+	if err := startReplication(ctx, conn, cfg, from); err != nil {
+		return from, err
+	}
+
+	s := &stream{conn: conn, cfg: cfg, apply: apply, confirmed: from, dec: newDecoder()}
+	for done := false; !done; {
+		done, err = s.receive(ctx, walEnd)
+		if err != nil {
+			return s.confirmed, err
+		}
+	}
+
+	return s.confirmed, s.flush(ctx)
+}
+
+type stream struct {
+	conn      *pgconn.PgConn
+	cfg       Config
+	apply     ApplyFunc
+	dec       *decoder
+	batch     Batch
+	confirmed LSN
+}
+
+// receive обрабатывает одно сообщение. Чтение заканчивается по таймауту простоя
+// или на commit транзакции, которая заканчивается не раньше walEnd.
+func (s *stream) receive(ctx context.Context, walEnd LSN) (bool, error) {
+	rctx, cancel := context.WithTimeout(ctx, s.cfg.IdleTimeout)
+	msg, err := s.conn.ReceiveMessage(rctx)
+	cancel()
+	if err != nil {
+		if pgconn.Timeout(err) && ctx.Err() == nil {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	switch m := msg.(type) {
+	case *pgproto3.ErrorResponse:
+		return false, pgconn.ErrorResponseToPgError(m)
+	case *pgproto3.CopyData:
+		if len(m.Data) == 0 {
+			return false, errShortMessage
+		}
+		switch m.Data[0] {
+		case 'k':
+			if len(m.Data) < 18 {
+				return false, errShortMessage
+			}
+			if m.Data[17] != 0 {
+				return false, s.sendStatus(s.confirmed)
+			}
+		case 'w':
+			if len(m.Data) < 25 {
+				return false, errShortMessage
+			}
+			changes, end, committed, err := s.dec.decode(m.Data[25:])
+			if err != nil || !committed {
+				return false, err
+			}
+			s.batch.Changes = append(s.batch.Changes, changes...)
+			s.batch.EndLSN = end
+			if len(s.batch.Changes) >= s.cfg.MaxChanges {
+				if err := s.flush(ctx); err != nil {
+					return false, err
+				}
+			}
+
+			return end >= walEnd, nil
+		}
+	}
+
+	return false, nil
+}
+
+// flush применяет накопленную пачку и подтверждает ее позицию серверу.
+func (s *stream) flush(ctx context.Context) error {
+	if s.batch.EndLSN <= s.confirmed {
+		return nil
+	}
+	if err := s.apply(ctx, s.batch); err != nil {
+		return err
+	}
+	s.confirmed = s.batch.EndLSN
+	s.batch = Batch{}
+
+	return s.sendStatus(s.confirmed)
+}
+
+// postgresEpoch - начало отсчета времени в протоколе репликации.
+var postgresEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (s *stream) sendStatus(lsn LSN) error {
+	buf := make([]byte, 0, 34)
+	buf = append(buf, 'r')
+	buf = binary.BigEndian.AppendUint64(buf, uint64(lsn))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(lsn))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(lsn))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(time.Since(postgresEpoch).Microseconds()))
+	buf = append(buf, 0)
+
+	s.conn.Frontend().Send(&pgproto3.CopyData{Data: buf})
+
+	return s.conn.Frontend().Flush()
+}
+
+// identifySystem возвращает текущую позицию WAL источника.
+func identifySystem(ctx context.Context, conn *pgconn.PgConn) (LSN, error) {
+	res, err := conn.Exec(ctx, `IDENTIFY_SYSTEM`).ReadAll()
+	if err != nil {
+		return 0, err
+	}
+	if len(res) == 0 || len(res[0].Rows) == 0 || len(res[0].Rows[0]) < 3 {
+		return 0, errors.New("unexpected IDENTIFY_SYSTEM result")
+	}
+
+	return ParseLSN(string(res[0].Rows[0][2]))
+}
+
+func startReplication(ctx context.Context, conn *pgconn.PgConn, cfg Config, from LSN) error {
+	query := fmt.Sprintf(`START_REPLICATION SLOT %s LOGICAL %s ("proto_version" '1', "publication_names" '%s')`,
+		cfg.Slot, from, strings.ReplaceAll(cfg.Publication, "'", "''"))
+	conn.Frontend().Send(&pgproto3.Query{String: query})
+	if err := conn.Frontend().Flush(); err != nil {
+		return err
+	}
+
+	for {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+		switch m := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(m)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package cdc_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+)
+
+func TestParseLSN(t *testing.T) {
+	for _, s := range []string{"0/0", "16/B374D848", "FFFFFFFF/FFFFFFFF"} {
+		lsn, err := cdc.ParseLSN(s)
+		require.NoError(t, err)
+		require.Equal(t, s, lsn.String())
+	}
+
+	lsn, err := cdc.ParseLSN("")
+	require.NoError(t, err)
+	require.Zero(t, lsn)
+
+	_, err = cdc.ParseLSN("16B374D848")
+	require.Error(t, err)
+}
+
+// TestDrain требует локальный PostgreSQL с wal_level=logical, например:
+// CDC_TEST_PG_DSN="host=localhost user=postgres password=postgres dbname=postgres sslmode=disable".
+func TestDrain(t *testing.T) {
+	dsn := os.Getenv("CDC_TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("CDC_TEST_PG_DSN is not set")
+	}
+	ctx := context.Background()
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	cfg := cdc.Config{ConnString: dsn, Slot: "cdc_test_slot", Publication: "cdc_test_pub", IdleTimeout: time.Second}
+	cleanup := func() {
+		db.Exec(`SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = 'cdc_test_slot'`) //nolint:errcheck
+		db.Exec(`DROP PUBLICATION IF EXISTS cdc_test_pub`)                                                                //nolint:errcheck
+		db.Exec(`DROP TABLE IF EXISTS cdc_test_orders`)                                                                   //nolint:errcheck
+	}
+	cleanup()
+	t.Cleanup(cleanup)
+
+	_, err = db.Exec(`CREATE TABLE cdc_test_orders (order_id BIGINT PRIMARY KEY, state INT)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE PUBLICATION cdc_test_pub FOR TABLE cdc_test_orders`)
+	require.NoError(t, err)
+
+	// Без слота чтение падает, а не создает слот молча.
+	_, err = cdc.Drain(ctx, cfg, 0, func(context.Context, cdc.Batch) error { return nil })
+	require.Error(t, err)
+
+	start, err := cdc.CreateSlot(ctx, cfg)
+	require.NoError(t, err)
+	require.NotZero(t, start)
+	existing, err := cdc.CreateSlot(ctx, cfg)
+	require.NoError(t, err)
+	require.Equal(t, start, existing)
+
+	// Изменений после создания слота еще нет.
+	pos, err := cdc.Drain(ctx, cfg, start, func(context.Context, cdc.Batch) error {
+		t.Fatal("unexpected batch")
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = db.Exec(`INSERT INTO cdc_test_orders VALUES (1, 1), (2, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE cdc_test_orders SET state = 2 WHERE order_id = 1`)
+	require.NoError(t, err)
+	_, err = db.Exec(`DELETE FROM cdc_test_orders WHERE order_id = 2`)
+	require.NoError(t, err)
+
+	var changes []cdc.Change
+	end, err := cdc.Drain(ctx, cfg, pos, func(_ context.Context, b cdc.Batch) error {
+		changes = append(changes, b.Changes...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Greater(t, end, pos)
+	require.Len(t, changes, 4)
+	require.Equal(t, cdc.OpInsert, changes[0].Op)
+	require.Equal(t, "cdc_test_orders", changes[0].Table)
+	require.Equal(t, cdc.OpUpdate, changes[2].Op)
+	require.Equal(t, map[string]string{"order_id": "1", "state": "2"}, changes[2].Values)
+	require.Equal(t, cdc.OpDelete, changes[3].Op)
+	id, ok := changes[3].Int64("order_id")
+	require.True(t, ok)
+	require.EqualValues(t, 2, id)
+
+	// Подтвержденные изменения повторно не приходят.
+	again, err := cdc.Drain(ctx, cfg, end, func(context.Context, cdc.Batch) error {
+		t.Fatal("unexpected batch")
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, end, again)
+}
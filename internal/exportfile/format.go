@@ -0,0 +1,194 @@
+package exportfile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+// timestampLayout - TIMESTAMP бизнес-зоны без смещения, как его видит потребитель по JDBC.
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatParquet:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q", s)
+	}
+}
+
+// TableWriter пишет строки выгружаемой таблицы в файл одного формата.
+// Значения строки идут в порядке колонок таблицы, nil - NULL.
+type TableWriter interface {
+	Write(row []any) error
+	Close() error
+}
+
+func NewTableWriter(f Format, w io.Writer, cols []target.ExportColumn) (TableWriter, error) {
+	switch f {
+	case FormatCSV:
+		return newCSVWriter(w, cols)
+	case FormatParquet:
+		return newParquetWriter(w, cols), nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", f)
+	}
+}
+
+// csvWriter пишет CSV с заголовком из имен колонок. NULL выгружается пустым полем.
+type csvWriter struct {
+	w    *csv.Writer
+	cols []target.ExportColumn
+	rec  []string
+}
+
+func newCSVWriter(w io.Writer, cols []target.ExportColumn) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), cols: cols, rec: make([]string, len(cols))}
+	for i, c := range cols {
+		cw.rec[i] = c.Name
+	}
+	if err := cw.w.Write(cw.rec); err != nil {
+		return nil, err
+	}
+
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(row []any) error {
+	for i, v := range row {
+		cw.rec[i] = csvValue(cw.cols[i].Kind, v)
+	}
+
+	return cw.w.Write(cw.rec)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+
+	return cw.w.Error()
+}
+
+func csvValue(kind target.ColumnKind, v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case string:
+		return v
+	case time.Time:
+		if kind == target.ColumnTimestampTZ {
+			return v.UTC().Format(time.RFC3339Nano)
+		}
+
+		return v.Format(timestampLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parquetWriter пишет все колонки как optional. TIMESTAMP бизнес-зоны
+// выгружается с isAdjustedToUTC=false, TIMESTAMPTZ - с isAdjustedToUTC=true.
+type parquetWriter struct {
+	w    *parquet.Writer
+	cols []target.ExportColumn
+	// leaf - индекс колонки в схеме parquet: поля группы упорядочены по имени.
+	leaf []int
+	buf  []parquet.Row
+}
+
+const parquetBatch = 1024
+
+func newParquetWriter(w io.Writer, cols []target.ExportColumn) *parquetWriter {
+	group := make(parquet.Group, len(cols))
+	for _, c := range cols {
+		var node parquet.Node
+		switch c.Kind {
+		case target.ColumnInt64:
+			node = parquet.Int(64)
+		case target.ColumnInt32:
+			node = parquet.Int(32)
+		case target.ColumnString:
+			node = parquet.String()
+		case target.ColumnTimestamp:
+			node = parquet.TimestampAdjusted(parquet.Microsecond, false)
+		case target.ColumnTimestampTZ:
+			node = parquet.Timestamp(parquet.Microsecond)
+		}
+		group[c.Name] = parquet.Compressed(parquet.Optional(node), &parquet.Zstd)
+	}
+	schema := parquet.NewSchema("row", group)
+
+	pw := &parquetWriter{w: parquet.NewWriter(w, schema), cols: cols, leaf: make([]int, len(cols))}
+	for i, c := range cols {
+		leaf, _ := schema.Lookup(c.Name)
+		pw.leaf[i] = leaf.ColumnIndex
+	}
+
+	return pw
+}
+
+func (pw *parquetWriter) Write(row []any) error {
+	out := make(parquet.Row, len(row))
+	for i, v := range row {
+		idx := pw.leaf[i]
+		if v == nil {
+			out[idx] = parquet.NullValue().Level(0, 0, idx)
+			continue
+		}
+		out[idx] = parquetValue(v).Level(0, 1, idx)
+	}
+	pw.buf = append(pw.buf, out)
+	if len(pw.buf) < parquetBatch {
+		return nil
+	}
+
+	return pw.flush()
+}
+
+func (pw *parquetWriter) flush() error {
+	_, err := pw.w.WriteRows(pw.buf)
+	pw.buf = pw.buf[:0]
+
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+
+	return pw.w.Close()
+}
+
+func parquetValue(v any) parquet.Value {
+	switch v := v.(type) {
+	case int64:
+		return parquet.Int64Value(v)
+	case int32:
+		return parquet.Int32Value(v)
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	case time.Time:
+		// Для TIMESTAMP бизнес-зоны время уже прочитано с цифрами стенных часов в UTC.
+		return parquet.Int64Value(v.UnixMicro())
+	default:
+		return parquet.ValueOf(v)
+	}
+}
@@ -0,0 +1,94 @@
+package exportfile_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/exportfile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var testColumns = []target.ExportColumn{
+	{Name: "id", Kind: target.ColumnInt64},
+	{Name: "order_number", Kind: target.ColumnString},
+	{Name: "reject_reason", Kind: target.ColumnInt32},
+	{Name: "from_date", Kind: target.ColumnTimestamp},
+	{Name: "change_date", Kind: target.ColumnTimestampTZ},
+}
+
+var testRows = [][]any{
+	{int64(1), "pin1", int32(7009), time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 30, 0, 500000, time.UTC)},
+	{int64(2), "pin,2", nil, nil, time.Date(2026, 10, 19, 9, 31, 0, 0, time.UTC)},
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := exportfile.NewTableWriter(exportfile.FormatCSV, &buf, testColumns)
+	require.NoError(t, err)
+	for _, row := range testRows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+
+	require.Equal(t, "id,order_number,reject_reason,from_date,change_date\n"+
+		"1,pin1,7009,2026-10-19 12:30:00,2026-10-19T09:30:00.0005Z\n"+
+		"2,\"pin,2\",,,2026-10-19T09:31:00Z\n", buf.String())
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := exportfile.NewTableWriter(exportfile.FormatParquet, &buf, testColumns)
+	require.NoError(t, err)
+	for _, row := range testRows {
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+
+	f, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, f.NumRows())
+
+	r := parquet.NewReader(f)
+	defer r.Close()
+	rows := make([]parquet.Row, 2)
+	n, _ := r.ReadRows(rows)
+	require.Equal(t, 2, n)
+
+	schema := f.Schema()
+	value := func(row parquet.Row, col string) parquet.Value {
+		leaf, ok := schema.Lookup(col)
+		require.True(t, ok)
+		return row[leaf.ColumnIndex]
+	}
+	require.EqualValues(t, 1, value(rows[0], "id").Int64())
+	require.Equal(t, "pin,2", value(rows[1], "order_number").String())
+	require.EqualValues(t, 7009, value(rows[0], "reject_reason").Int32())
+	require.True(t, value(rows[1], "reject_reason").IsNull())
+	require.Equal(t, time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC).UnixMicro(), value(rows[0], "from_date").Int64())
+}
+
+func TestDescribeFileAndManifest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "req_number.csv"), []byte("id\n"), 0o600))
+
+	fm, err := exportfile.DescribeFile(filepath.Join(dir, "req_number.csv"), exportfile.FormatCSV)
+	require.NoError(t, err)
+	require.Equal(t, "req_number.csv", fm.Name)
+	require.EqualValues(t, 3, fm.Size)
+	require.Equal(t, "984a644ec3b56d32b0404777e1eb73390c4b0742a6a0e183f07861056b6746de", fm.SHA256)
+
+	m := exportfile.Manifest{ExportID: "20261019T093000Z", Consumer: "sas", Mode: "incremental", Tables: []exportfile.TableManifest{
+		{Table: "req_number", Rows: 0, Files: []exportfile.FileManifest{fm}},
+	}}
+	require.NoError(t, exportfile.WriteManifest(dir, m))
+
+	got, err := exportfile.ReadManifest(dir)
+	require.NoError(t, err)
+	require.Equal(t, m.Tables, got.Tables)
+}
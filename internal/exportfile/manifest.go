@@ -0,0 +1,90 @@
+package exportfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFile - имя манифеста в каталоге выгрузки. Манифест пишется последним.
+const ManifestFile = "manifest.json"
+
+// ExportIDLayout - имя каталога выгрузки по времени запуска в UTC.
+const ExportIDLayout = "20060102T150405Z"
+
+type Manifest struct {
+	ExportID  string          `json:"exportId"`
+	Consumer  string          `json:"consumer"`
+	Mode      string          `json:"mode"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Tables    []TableManifest `json:"tables"`
+}
+
+// TableManifest - выгрузка таблицы. Строки с ключом (change_date, id) в интервале (From, To],
+// From отсутствует у полной выгрузки, To - если строк не было.
+type TableManifest struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// Until - верхняя граница change_date (не включительно), до которой читалась таблица.
+	Until time.Time      `json:"until"`
+	From  *Key           `json:"from,omitempty"`
+	To    *Key           `json:"to,omitempty"`
+	Files []FileManifest `json:"files"`
+}
+
+type Key struct {
+	ChangeDate time.Time `json:"changeDate"`
+	ID         int64     `json:"id"`
+}
+
+type FileManifest struct {
+	Name   string `json:"name"`
+	Format Format `json:"format"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DescribeFile считает размер и sha256 записанного файла.
+func DescribeFile(path string, f Format) (FileManifest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileManifest{}, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return FileManifest{}, err
+	}
+
+	return FileManifest{
+		Name:   filepath.Base(path),
+		Format: f,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+func WriteManifest(dir string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, ManifestFile), data, 0o644) //nolint:gosec
+}
+
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return m, err
+	}
+
+	return m, json.Unmarshal(data, &m)
+}
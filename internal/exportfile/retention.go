@@ -0,0 +1,66 @@
+package exportfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ExportCreated возвращает время запуска выгрузки по имени ее каталога (ExportIDLayout).
+func ExportCreated(name string) (time.Time, bool) {
+	created, err := time.Parse(ExportIDLayout, name)
+
+	return created, err == nil
+}
+
+// RemoveDelivered удаляет в root каталоги выгрузок с файлом-маркером доставки marker и возвращает их имена.
+func RemoveDelivered(root, marker string) ([]string, error) {
+	return removeExports(root, func(dir string, _ time.Time) bool {
+		_, err := os.Stat(filepath.Join(dir, marker))
+		return err == nil
+	})
+}
+
+// RemoveExpired удаляет в root каталоги выгрузок старше retention на момент now по времени в имени
+// и возвращает их имена. retention <= 0 - не удалять.
+func RemoveExpired(root string, now time.Time, retention time.Duration) ([]string, error) {
+	if retention <= 0 {
+		return nil, nil
+	}
+
+	return removeExports(root, func(_ string, created time.Time) bool {
+		return now.Sub(created) > retention
+	})
+}
+
+// removeExports удаляет в root каталоги выгрузок, для которых remove возвращает true, и возвращает их имена.
+// Каталоги с именем не по ExportIDLayout, в том числе незавершенные *.tmp, не трогаются.
+func removeExports(root string, remove func(dir string, created time.Time) bool) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed []string
+		errs    []error
+	)
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		created, ok := ExportCreated(e.Name())
+		dir := filepath.Join(root, e.Name())
+		if !ok || !remove(dir, created) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, e.Name())
+	}
+
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,66 @@
+package exportfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/exportfile"
+)
+
+func mkdirs(t *testing.T, root string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, name), 0o755))
+	}
+}
+
+func TestExportCreated(t *testing.T) {
+	created, ok := exportfile.ExportCreated("20261019T093000Z")
+	require.True(t, ok)
+	require.True(t, time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC).Equal(created))
+
+	for _, name := range []string{"manual", "20261019T093000Z.tmp", "2026-10-19"} {
+		_, ok := exportfile.ExportCreated(name)
+		require.False(t, ok, name)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	root := t.TempDir()
+	mkdirs(t, root, "20261001T000000Z", "20261012T000000Z", "20261019T090000Z", "20261001T000000Z.tmp", "manual")
+	now := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+	removed, err := exportfile.RemoveExpired(root, now, 0)
+	require.NoError(t, err)
+	require.Empty(t, removed)
+
+	removed, err = exportfile.RemoveExpired(root, now, 168*time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, []string{"20261001T000000Z", "20261012T000000Z"}, removed)
+	require.NoDirExists(t, filepath.Join(root, "20261001T000000Z"))
+	require.DirExists(t, filepath.Join(root, "20261019T090000Z"))
+	require.DirExists(t, filepath.Join(root, "20261001T000000Z.tmp"))
+	require.DirExists(t, filepath.Join(root, "manual"))
+}
+
+func TestRemoveDelivered(t *testing.T) {
+	root := t.TempDir()
+	mkdirs(t, root, "20261018T000000Z", "20261019T000000Z", "manual")
+	for _, name := range []string{"20261018T000000Z", "manual"} {
+		require.NoError(t, os.WriteFile(filepath.Join(root, name, ".delivered"), nil, 0o600))
+	}
+
+	removed, err := exportfile.RemoveDelivered(root, ".delivered")
+	require.NoError(t, err)
+	require.Equal(t, []string{"20261018T000000Z"}, removed)
+	require.DirExists(t, filepath.Join(root, "20261019T000000Z"))
+	require.DirExists(t, filepath.Join(root, "manual"))
+
+	_, err = exportfile.RemoveDelivered(filepath.Join(root, "missing"), ".delivered")
+	require.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package exportfile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+)
+
+// DoneFile - маркер полностью доставленной выгрузки в каталоге на SFTP. Пишется последним.
+const DoneFile = ".done"
+
+// partSuffix - суффикс файла на время загрузки, после загрузки файл переименовывается.
+const partSuffix = ".part"
+
+// SFTPConnector открывает соединение с SFTP. В сервисе - клиент go-base sftp.
+type SFTPConnector interface {
+	GetAndOpenSFTPConnection(ctx context.Context) (*sftp.Client, func(), error)
+}
+
+type SFTPConfig struct {
+	// Root - каталог на сервере, выгрузки пишутся в Root/<consumer>/<export id>.
+	Root       string
+	Retries    int
+	RetryDelay time.Duration
+	// Retention - срок хранения выгрузок на сервере по времени в имени каталога, 0 - не удалять.
+	Retention time.Duration
+}
+
+// SFTPSink доставляет каталоги выгрузки на SFTP.
+type SFTPSink struct {
+	conn   SFTPConnector
+	cfg    SFTPConfig
+	logger *zap.Logger
+}
+
+func NewSFTPSink(conn SFTPConnector, cfg SFTPConfig, logger *zap.Logger) *SFTPSink {
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+
+	return &SFTPSink{conn: conn, cfg: cfg, logger: logger.Named("export-sftp")}
+}
+
+// Deliver загружает файлы из манифеста и сам манифест, затем создает DoneFile.
+// Каждый файл пишется под временным именем и переименовывается после загрузки.
+// Каталог с DoneFile считается доставленным и повторно не загружается.
+func (s *SFTPSink) Deliver(ctx context.Context, consumer, dir string) error {
+	var err error
+	for attempt := 0; attempt <= s.cfg.Retries; attempt++ {
+		if attempt > 0 {
+			s.logger.Warn("sftp delivery failed, retrying",
+				zap.String("consumer", consumer),
+				zap.String("dir", dir),
+				zap.Int("attempt", attempt),
+				zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.cfg.RetryDelay):
+			}
+		}
+
+		if err = s.deliver(ctx, consumer, dir); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (s *SFTPSink) deliver(ctx context.Context, consumer, dir string) error {
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	client, cleanup, err := s.conn.GetAndOpenSFTPConnection(ctx)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	remote := path.Join(s.cfg.Root, consumer, m.ExportID)
+	if _, err := client.Stat(path.Join(remote, DoneFile)); err == nil {
+		return nil
+	}
+	if err := client.MkdirAll(remote); err != nil {
+		return fmt.Errorf("mkdir %s: %w", remote, err)
+	}
+
+	var names []string
+	for _, t := range m.Tables {
+		for _, f := range t.Files {
+			names = append(names, f.Name)
+		}
+	}
+	names = append(names, ManifestFile)
+	for _, name := range names {
+		if err := upload(client, filepath.Join(dir, name), path.Join(remote, name)); err != nil {
+			return fmt.Errorf("upload %s: %w", name, err)
+		}
+	}
+
+	done, err := client.Create(path.Join(remote, DoneFile))
+	if err != nil {
+		return err
+	}
+	if err := done.Close(); err != nil {
+		return err
+	}
+
+	// Удаление старых выгрузок не влияет на результат доставки.
+	if err := s.expire(client, consumer, time.Now()); err != nil {
+		s.logger.Warn("sftp retention failed", zap.String("consumer", consumer), zap.Error(err))
+	}
+
+	return nil
+}
+
+func upload(client *sftp.Client, local, remote string) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	part := remote + partSuffix
+	dst, err := client.Create(part)
+	if err != nil {
+		return err
+	}
+	size, err := dst.ReadFrom(src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	fi, err := client.Stat(part)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != size {
+		return fmt.Errorf("size mismatch: uploaded %d, remote %d", size, fi.Size())
+	}
+
+	// Rename в SFTP v3 не перезаписывает существующий файл, он остается от прерванной доставки.
+	if _, err := client.Stat(remote); err == nil {
+		if err := client.Remove(remote); err != nil {
+			return err
+		}
+	}
+
+	return client.Rename(part, remote)
+}
+
+// expire удаляет каталоги выгрузок потребителя старше Retention. Каталоги с именем не по ExportIDLayout не трогаются.
+func (s *SFTPSink) expire(client *sftp.Client, consumer string, now time.Time) error {
+	if s.cfg.Retention <= 0 {
+		return nil
+	}
+
+	root := path.Join(s.cfg.Root, consumer)
+	entries, err := client.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		created, ok := ExportCreated(e.Name())
+		if !ok || now.Sub(created) <= s.cfg.Retention {
+			continue
+		}
+		if err := client.RemoveAll(path.Join(root, e.Name())); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.logger.Info("sftp export expired", zap.String("consumer", consumer), zap.String("export_id", e.Name()))
+	}
+
+	return errors.Join(errs...)
+}
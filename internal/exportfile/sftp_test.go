@@ -0,0 +1,109 @@
+package exportfile_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/exportfile"
+)
+
+// pipeConnector поднимает in-process SFTP-сервер над каталогом root.
+type pipeConnector struct {
+	root  string
+	fails int
+	opens int
+}
+
+func (c *pipeConnector) GetAndOpenSFTPConnection(context.Context) (*sftp.Client, func(), error) {
+	c.opens++
+	if c.opens <= c.fails {
+		return nil, nil, errors.New("connection refused")
+	}
+
+	clientConn, serverConn := net.Pipe()
+	srv, err := sftp.NewServer(serverConn, sftp.WithServerWorkingDirectory(c.root))
+	if err != nil {
+		return nil, nil, err
+	}
+	go srv.Serve() //nolint:errcheck
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, func() {
+		_ = client.Close()
+		_ = srv.Close()
+	}, nil
+}
+
+func writeTestExport(t *testing.T, exportID string) string {
+	dir := filepath.Join(t.TempDir(), exportID)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "req_number.csv"), []byte("id\n1\n"), 0o600))
+
+	fm, err := exportfile.DescribeFile(filepath.Join(dir, "req_number.csv"), exportfile.FormatCSV)
+	require.NoError(t, err)
+	require.NoError(t, exportfile.WriteManifest(dir, exportfile.Manifest{
+		ExportID: exportID,
+		Consumer: "sas",
+		Tables:   []exportfile.TableManifest{{Table: "req_number", Rows: 1, Files: []exportfile.FileManifest{fm}}},
+	}))
+
+	return dir
+}
+
+func TestSFTPSinkDeliver(t *testing.T) {
+	remote := t.TempDir()
+	drop := filepath.Join(remote, "drop", "sas")
+	require.NoError(t, os.MkdirAll(filepath.Join(drop, "20200101T000000Z"), 0o755))
+	require.NoError(t, os.MkdirAll(filepath.Join(drop, "manual"), 0o755))
+
+	conn := &pipeConnector{root: remote, fails: 1}
+	sink := exportfile.NewSFTPSink(conn, exportfile.SFTPConfig{
+		Root:      "drop",
+		Retries:   2,
+		Retention: 365 * 24 * time.Hour,
+	}, zap.NewNop())
+
+	dir := writeTestExport(t, "20261019T093000Z")
+	require.NoError(t, sink.Deliver(context.Background(), "sas", dir))
+	require.Equal(t, 2, conn.opens)
+
+	delivered := filepath.Join(drop, "20261019T093000Z")
+	data, err := os.ReadFile(filepath.Join(delivered, "req_number.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "id\n1\n", string(data))
+	require.FileExists(t, filepath.Join(delivered, exportfile.ManifestFile))
+	require.FileExists(t, filepath.Join(delivered, exportfile.DoneFile))
+	require.NoFileExists(t, filepath.Join(delivered, "req_number.csv.part"))
+
+	require.NoDirExists(t, filepath.Join(drop, "20200101T000000Z"))
+	require.DirExists(t, filepath.Join(drop, "manual"))
+
+	// Доставленный каталог не перезаписывается.
+	require.NoError(t, os.WriteFile(filepath.Join(delivered, "req_number.csv"), []byte("changed"), 0o600))
+	require.NoError(t, sink.Deliver(context.Background(), "sas", dir))
+	data, err = os.ReadFile(filepath.Join(delivered, "req_number.csv"))
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(data))
+}
+
+func TestSFTPSinkRetriesExhausted(t *testing.T) {
+	conn := &pipeConnector{root: t.TempDir(), fails: 10}
+	sink := exportfile.NewSFTPSink(conn, exportfile.SFTPConfig{Root: "drop", Retries: 2}, zap.NewNop())
+
+	err := sink.Deliver(context.Background(), "sas", writeTestExport(t, "20261019T093000Z"))
+	require.EqualError(t, err, "connection refused")
+	require.Equal(t, 3, conn.opens)
+}
@@ -0,0 +1,127 @@
+package cdbmessage
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// runCDC загружает изменения mnp_message из слота логической репликации вместо опроса по message_date.
+func (j *Job) runCDC(ctx context.Context) error {
+	pos, err := j.store.CDCPosition(ctx, j.cfg.CDC.Slot)
+	if err != nil {
+		return err
+	}
+	if pos == "" {
+		if pos, err = j.initCDC(ctx); err != nil {
+			return err
+		}
+	}
+	from, err := cdc.ParseLSN(pos)
+	if err != nil {
+		return err
+	}
+
+	to, err := cdc.Drain(ctx, *j.cfg.CDC, from, j.applyChanges)
+	if err != nil {
+		return err
+	}
+	j.logger.Info("cdc slot drained", zap.String("slot", j.cfg.CDC.Slot), zap.Stringer("from", from), zap.Stringer("to", to))
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.processBackfill(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	j.reportWatermark(ctx)
+
+	return nil
+}
+
+// initCDC создает слот и догружает опросом сообщения, сохраненные до его создания: после выключения опроса
+// или при первичной загрузке. Опрос повторяется, пока продвигается watermark. Позиция создания слота
+// сохраняется только после догрузки, поэтому прерванная догрузка повторяется при следующем запуске.
+func (j *Job) initCDC(ctx context.Context) (string, error) {
+	lsn, err := cdc.CreateSlot(ctx, *j.cfg.CDC)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		before, err := j.store.MaxRawRequestTime(ctx, j.cfg.Source)
+		if err != nil {
+			return "", err
+		}
+		if err := j.runPoll(ctx); err != nil {
+			return "", err
+		}
+		after, err := j.store.MaxRawRequestTime(ctx, j.cfg.Source)
+		if err != nil {
+			return "", err
+		}
+		if (before == nil && after == nil) || (before != nil && after != nil && before.Equal(*after)) {
+			break
+		}
+	}
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.store.SaveCDCPosition(ctx, tx, j.LockKey(), j.cfg.CDC.Slot, lsn.String()); err != nil {
+		return "", err
+	}
+	j.logger.Info("cdc slot created", zap.String("slot", j.cfg.CDC.Slot), zap.Stringer("lsn", lsn))
+
+	return lsn.String(), tx.Commit()
+}
+
+// applyChanges перечитывает измененные сообщения из источника и сохраняет позицию слота в той же транзакции.
+func (j *Job) applyChanges(ctx context.Context, b cdc.Batch) error {
+	ids := make([]int64, 0, len(b.Changes))
+	for _, c := range b.Changes {
+		if c.Op == cdc.OpDelete || c.Table != messageTable {
+			continue
+		}
+		id, ok := c.Int64("message_id")
+		if !ok {
+			j.logger.Warn("cdc change without message_id", zap.String("table", c.Table))
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	messages, err := j.sourceMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	metrics.RowsExtracted.WithLabelValues(j.Name(), messageTable).Add(float64(len(messages)))
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.store.UpsertRawRequests(ctx, tx, messages); err != nil {
+		return err
+	}
+	metrics.RowsUpserted.WithLabelValues(j.Name(), messageTable).Add(float64(len(messages)))
+	if err := j.store.SaveCDCPosition(ctx, tx, j.LockKey(), j.cfg.CDC.Slot, b.EndLSN.String()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -9,15 +9,30 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
 )
 
 var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/cdbmessage")
 
+const (
+	name         = "cdb-message"
+	messageTable = "mnp_message"
+)
+
 type Config struct {
+	// Source - имя установки MNPHUB, значение колонки source mnp_raw_request. Пусто - target.DefaultSource.
+	Source    string
 	Lookback  time.Duration
 	BatchSize int
 	Prefix    string
+	Location  *time.Location
+	// CDC включает чтение mnp_message из слота логической репликации вместо опроса.
+	CDC       *cdc.Config
+	Schedule  scheduler.Spec
+	DependsOn []string
 }
 
 type Job struct {
@@ -32,24 +47,49 @@ func NewJob(cfg Config, sourceDB, targetDB *sql.DB, store *target.Store, logger
 	if cfg.BatchSize <= 0 {
 		cfg.BatchSize = 5000
 	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.Source == "" {
+		cfg.Source = target.DefaultSource
+	}
+
+	return &Job{cfg: cfg, sourceDB: sourceDB, targetDB: targetDB, store: store, logger: logger.Named(jobName(cfg.Source) + "-job")}
+}
+
+// jobName возвращает имя джобы источника source. Джоба target.DefaultSource называется cdb-message,
+// чтобы сохранить ключ блокировки, etl_state и backfill единственной установки.
+func jobName(source string) string {
+	if source == target.DefaultSource {
+		return name
+	}
 
-	return &Job{cfg: cfg, sourceDB: sourceDB, targetDB: targetDB, store: store, logger: logger.Named("cdb-message-job")}
+	return name + "-" + source
 }
 
+func (j *Job) Name() string { return jobName(j.cfg.Source) }
+
+func (j *Job) LockKey() string { return j.Name() + "-dag" }
+
+// DependsOn: связка mnp_raw_request с заявками требует актуального mnp_request.
+func (j *Job) DependsOn() []string { return j.cfg.DependsOn }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
 func (j *Job) Run(ctx context.Context) error {
 	ctx, span := tracer.Start(ctx, "Run")
 	defer span.End()
-	locked, err := j.store.TryLockJob(ctx, "cdb-message-dag")
-	if err != nil {
-		return err
-	}
-	if !locked {
-		j.logger.Info("job already running")
-		return nil
+
+	if j.cfg.CDC != nil {
+		return j.runCDC(ctx)
 	}
-	defer j.store.UnlockJob(context.Background(), "cdb-message-dag")
 
-	depth, err := j.store.MaxRawRequestTime(ctx)
+	return j.runPoll(ctx)
+}
+
+// runPoll загружает одну пачку сообщений mnp_message после watermark витрины.
+func (j *Job) runPoll(ctx context.Context) error {
+	depth, err := j.store.MaxRawRequestTime(ctx, j.cfg.Source)
 	if err != nil {
 		return err
 	}
@@ -58,68 +98,124 @@ func (j *Job) Run(ctx context.Context) error {
 		depth = &t
 	}
 
-	rows, err := j.sourceDB.QueryContext(ctx, `
-SELECT m.message_id, p.order_id, m.request_data, m.message_data, m.message_type, m.message_direction
-FROM mnp_message m
-JOIN mnp_process p ON p.process_id = m.process_id
-WHERE ($1::timestamp is null or m.message_date > $1)
+	observe := metrics.ObserveQuery(metrics.DBCDBMessaging, messageTable)
+	rows, err := j.sourceDB.QueryContext(ctx, messageQuery+`WHERE ($1::timestamptz is null or m.message_date > $1)
 ORDER BY m.message_date, m.message_id
 LIMIT $2`, depth, j.cfg.BatchSize)
+	observe()
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	tx, err := j.targetDB.BeginTx(ctx, nil)
+	tx, err := j.store.BeginWrite(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	for rows.Next() {
-		var (
-			id          int64
-			orderID     string
-			requestTime time.Time
-			messageData sql.NullString
-			messageType sql.NullString
-			direction   int
-		)
-		if err := rows.Scan(&id, &orderID, &requestTime, &messageData, &messageType, &direction); err != nil {
-			return err
-		}
+	if err := j.upsertMessages(ctx, tx, rows); err != nil {
+		return err
+	}
+	if err := j.processBackfill(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
 
-		source := "MNPHUB"
-		dest := "CDB"
-		if direction == 1 {
-			source = "CDB"
-			dest = "MNPHUB"
-		}
+	j.reportWatermark(ctx)
 
-		reqID := orderID
-		if j.cfg.Prefix != "" && len(orderID) >= len(j.cfg.Prefix) && orderID[:len(j.cfg.Prefix)] != j.cfg.Prefix {
-			reqID = fmt.Sprintf("%s%s", j.cfg.Prefix, orderID)
-		}
+	return nil
+}
+
+const messageQuery = `SELECT m.message_id, p.order_id, m.request_data, m.message_data, m.message_type, m.message_direction
+FROM mnp_message m
+JOIN mnp_process p ON p.process_id = m.process_id
+`
 
-		if err := j.store.UpsertRawRequest(ctx, tx, target.RawRequest{
-			ID:            id,
-			ReqID:         reqID,
-			RequestTime:   requestTime,
-			XMLMessage:    messageData.String,
-			OperationInfo: messageType.String,
-			SystemSource:  source,
-			SystemDest:    dest,
-		}); err != nil {
+func (j *Job) upsertMessages(ctx context.Context, tx *sql.Tx, rows *sql.Rows) error {
+	var batch []target.RawRequest
+	for rows.Next() {
+		rr, err := j.scanMessage(rows)
+		if err != nil {
 			return err
 		}
+		metrics.RowsExtracted.WithLabelValues(j.Name(), messageTable).Inc()
+		batch = append(batch, rr)
 	}
-
 	if err := rows.Err(); err != nil {
 		return err
 	}
-	if err := tx.Commit(); err != nil {
+
+	if err := j.store.UpsertRawRequests(ctx, tx, batch); err != nil {
 		return err
 	}
+	metrics.RowsUpserted.WithLabelValues(j.Name(), messageTable).Add(float64(len(batch)))
 
 	return nil
 }
+
+func (j *Job) scanMessage(rows *sql.Rows) (target.RawRequest, error) {
+	var (
+		id          int64
+		orderID     string
+		requestTime time.Time
+		messageData sql.NullString
+		messageType sql.NullString
+		direction   int
+	)
+	if err := rows.Scan(&id, &orderID, &requestTime, &messageData, &messageType, &direction); err != nil {
+		return target.RawRequest{}, err
+	}
+
+	source := "MNPHUB"
+	dest := "CDB"
+	if direction == 1 {
+		source = "CDB"
+		dest = "MNPHUB"
+	}
+
+	reqID := orderID
+	if j.cfg.Prefix != "" && len(orderID) >= len(j.cfg.Prefix) && orderID[:len(j.cfg.Prefix)] != j.cfg.Prefix {
+		reqID = fmt.Sprintf("%s%s", j.cfg.Prefix, orderID)
+	}
+
+	return target.RawRequest{
+		ID:            id,
+		ReqID:         reqID,
+		RequestTime:   requestTime,
+		XMLMessage:    messageData.String,
+		OperationInfo: messageType.String,
+		SystemSource:  source,
+		SystemDest:    dest,
+		Source:        j.cfg.Source,
+	}, nil
+}
+
+// reportWatermark обновляет метрики отставания витрины. Ошибка не влияет на результат запуска.
+func (j *Job) reportWatermark(ctx context.Context) {
+	watermark, sourceNow, err := j.Watermark(ctx)
+	if err != nil {
+		j.logger.Warn("watermark metrics failed", zap.Error(err))
+		return
+	}
+	metrics.SetWatermark(j.Name(), watermark, sourceNow)
+}
+
+// Watermark возвращает последнюю загруженную в витрину дату изменения и текущее время источника.
+func (j *Job) Watermark(ctx context.Context) (*time.Time, time.Time, error) {
+	watermark, err := j.store.MaxRawRequestTime(ctx, j.cfg.Source)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	defer metrics.ObserveQuery(metrics.DBCDBMessaging, "now")()
+
+	var sourceNow time.Time
+	if err := j.sourceDB.QueryRowContext(ctx, `SELECT now()`).Scan(&sourceNow); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return watermark, sourceNow, nil
+}
@@ -0,0 +1,167 @@
+package cdbmessage
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/reconcile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+const checkRawRequests = "mnp_raw_request"
+
+// Reconcile сверяет сообщения mnp_message с request_data в [from, to) с mnp_raw_request.
+func (j *Job) Reconcile(ctx context.Context, from, to time.Time, sample int) ([]target.ReconciliationResult, error) {
+	ctx, span := tracer.Start(ctx, "Reconcile")
+	defer span.End()
+
+	expected, err := j.sourceMessageTimes(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := j.store.RawRequestTimesBetween(ctx, j.cfg.Source, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reconcile.NewTally(j.LockKey(), checkRawRequests, j.cfg.Location, sample)
+	var sampled []int64
+	for id, at := range expected {
+		t.Source(at, "")
+		if _, ok := actual[id]; !ok {
+			t.Missing(at, "", strconv.FormatInt(id, 10))
+			continue
+		}
+		if t.Sample() {
+			sampled = append(sampled, id)
+		}
+	}
+	for id, at := range actual {
+		t.Target(at, "")
+		if _, ok := expected[id]; !ok {
+			t.Extra(at, "", strconv.FormatInt(id, 10))
+		}
+	}
+
+	if err := j.compareChecksums(ctx, t, sampled); err != nil {
+		return nil, err
+	}
+
+	return t.Results(), nil
+}
+
+func (j *Job) compareChecksums(ctx context.Context, t *reconcile.Tally, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	expected, err := j.sourceMessages(ctx, ids)
+	if err != nil {
+		return err
+	}
+	actual, err := j.store.RawRequests(ctx, j.cfg.Source, ids)
+	if err != nil {
+		return err
+	}
+	actualByID := make(map[int64]target.RawRequest, len(actual))
+	for _, rr := range actual {
+		actualByID[rr.ID] = rr
+	}
+
+	for _, rr := range expected {
+		t.Checksum(rr.RequestTime, "", strconv.FormatInt(rr.ID, 10), rawRequestChecksum(rr), rawRequestChecksum(actualByID[rr.ID]))
+	}
+
+	return nil
+}
+
+func (j *Job) sourceMessageTimes(ctx context.Context, from, to time.Time) (map[int64]time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBCDBMessaging, "reconcile_"+messageTable)()
+
+	rows, err := j.sourceDB.QueryContext(ctx, `
+SELECT m.message_id, m.request_data
+FROM mnp_message m
+JOIN mnp_process p ON p.process_id = m.process_id
+WHERE m.request_data >= $1 AND m.request_data < $2`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			id int64
+			at time.Time
+		)
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		res[id] = at
+	}
+
+	return res, rows.Err()
+}
+
+func (j *Job) sourceMessages(ctx context.Context, ids []int64) ([]target.RawRequest, error) {
+	defer metrics.ObserveQuery(metrics.DBCDBMessaging, "messages_by_id")()
+
+	rows, err := j.sourceDB.QueryContext(ctx, messageQuery+`WHERE m.message_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []target.RawRequest
+	for rows.Next() {
+		rr, err := j.scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rr)
+	}
+
+	return res, rows.Err()
+}
+
+// processBackfill повторно загружает сообщения из etl_backfill.
+func (j *Job) processBackfill(ctx context.Context, tx *sql.Tx) error {
+	keys, err := j.store.PendingBackfill(ctx, j.LockKey(), j.cfg.BatchSize)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	ids := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			j.logger.Warn("invalid backfill key", zap.String("source_key", key), zap.Error(err))
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	observe := metrics.ObserveQuery(metrics.DBCDBMessaging, "backfill_"+messageTable)
+	rows, err := j.sourceDB.QueryContext(ctx, messageQuery+`WHERE m.message_id = ANY($1)`, pq.Array(ids))
+	observe()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := j.upsertMessages(ctx, tx, rows); err != nil {
+		return err
+	}
+	j.logger.Info("backfill processed", zap.Int("messages", len(ids)))
+
+	return j.store.CompleteBackfill(ctx, tx, j.LockKey(), keys)
+}
+
+func rawRequestChecksum(rr target.RawRequest) string {
+	return reconcile.Checksum(rr.ID, rr.ReqID, rr.RequestTime, rr.XMLMessage, rr.OperationInfo, rr.SystemSource, rr.SystemDest)
+}
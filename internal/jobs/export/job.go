@@ -0,0 +1,317 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/exportfile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/export")
+
+const (
+	ModeFull        = "full"
+	ModeIncremental = "incremental"
+)
+
+// deliveredFile - локальный маркер выгрузки, доставленной через Sink.
+const deliveredFile = ".delivered"
+
+// Consumer - потребитель файлов. Full - каждый запуск выгружает таблицы целиком,
+// иначе только строки, измененные после сохраненной позиции потребителя.
+// Без Sink потребитель забирает файлы из локального каталога.
+type Consumer struct {
+	Name string
+	Full bool
+	Sink Sink
+}
+
+// Sink доставляет готовый каталог выгрузки потребителю. Повторная доставка того же каталога не должна дублировать файлы.
+type Sink interface {
+	Deliver(ctx context.Context, consumer, dir string) error
+}
+
+type Config struct {
+	// Dir - корень выгрузки, файлы потребителя пишутся в Dir/<consumer>/<export id>.
+	Dir       string
+	Consumers []Consumer
+	Tables    []target.ExportTable
+	Formats   []exportfile.Format
+	// Settle - строки с change_date позже now()-Settle ждут следующего запуска. Граница также не позже
+	// начала самой старой открытой транзакции витрины (Store.OldestTransactionStart).
+	Settle time.Duration
+	// Retention - срок хранения локальных выгрузок потребителей без Sink по времени в имени каталога,
+	// 0 - не удалять. Выгрузки потребителей с Sink удаляются сразу после доставки.
+	Retention time.Duration
+	Schedule  scheduler.Spec
+}
+
+type Job struct {
+	cfg    Config
+	store  *target.Store
+	logger *zap.Logger
+}
+
+func NewJob(cfg Config, store *target.Store, logger *zap.Logger) *Job {
+	return &Job{cfg: cfg, store: store, logger: logger.Named("export-job")}
+}
+
+func (j *Job) Name() string { return "export" }
+
+func (j *Job) LockKey() string { return "export-dag" }
+
+func (j *Job) DependsOn() []string { return nil }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
+func (j *Job) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Run")
+	defer span.End()
+
+	runAt := time.Now()
+	until, err := j.until(ctx, runAt)
+	if err != nil {
+		return err
+	}
+	for _, c := range j.cfg.Consumers {
+		if err := j.exportConsumer(ctx, c, runAt, until); err != nil {
+			return fmt.Errorf("export for %s: %w", c.Name, err)
+		}
+		if c.Sink != nil {
+			if err := j.deliverPending(ctx, c); err != nil {
+				return fmt.Errorf("deliver export for %s: %w", c.Name, err)
+			}
+		}
+		if err := j.cleanup(c, runAt); err != nil {
+			return fmt.Errorf("cleanup export for %s: %w", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// cleanup удаляет локальные выгрузки потребителя: доставленные через Sink, а без Sink - старше Retention.
+// Недоставленные выгрузки остаются до следующей доставки.
+func (j *Job) cleanup(c Consumer, now time.Time) error {
+	root := filepath.Join(j.cfg.Dir, c.Name)
+	var (
+		removed []string
+		err     error
+	)
+	if c.Sink != nil {
+		removed, err = exportfile.RemoveDelivered(root, deliveredFile)
+	} else {
+		removed, err = exportfile.RemoveExpired(root, now, j.cfg.Retention)
+	}
+	for _, name := range removed {
+		j.logger.Info("local export removed", zap.String("consumer", c.Name), zap.String("export_id", name))
+	}
+
+	return err
+}
+
+// until возвращает границу change_date выгрузки: runAt - Settle, но не позже начала самой старой открытой
+// транзакции витрины. change_date - время начала транзакции, и ее строки становятся видимыми только после commit,
+// поэтому без этой границы позиция потребителя могла бы уйти дальше незавершенной записи.
+func (j *Job) until(ctx context.Context, runAt time.Time) (time.Time, error) {
+	until := runAt.Add(-j.cfg.Settle)
+	oldest, err := j.store.OldestTransactionStart(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if oldest != nil && oldest.Before(until) {
+		j.logger.Debug("export bounded by open transaction", zap.Time("xact_start", *oldest))
+		until = *oldest
+	}
+
+	return until, nil
+}
+
+// exportConsumer пишет выгрузку во временный каталог и переименовывает его после манифеста,
+// поэтому потребитель видит только полные выгрузки. Позиции сохраняются после переименования:
+// при сбое между ними следующий запуск повторит те же строки.
+func (j *Job) exportConsumer(ctx context.Context, c Consumer, runAt, until time.Time) error {
+	offsets := map[string]target.ExportOffset{}
+	if !c.Full {
+		var err error
+		offsets, err = j.store.ExportOffsets(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	m := exportfile.Manifest{
+		ExportID:  runAt.UTC().Format(exportfile.ExportIDLayout),
+		Consumer:  c.Name,
+		Mode:      ModeIncremental,
+		CreatedAt: runAt,
+	}
+	if c.Full {
+		m.Mode = ModeFull
+	}
+
+	dir := filepath.Join(j.cfg.Dir, c.Name, m.ExportID)
+	tmp := dir + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, 0o755); err != nil { //nolint:gosec
+		return err
+	}
+	// После переименования tmp уже нет, удаление остается только для прерванной выгрузки.
+	defer os.RemoveAll(tmp) //nolint:errcheck
+
+	var saved []target.ExportOffset
+	for _, t := range j.cfg.Tables {
+		var after *target.ExportOffset
+		if o, ok := offsets[t.Name]; ok {
+			after = &o
+		}
+
+		tm, last, err := j.exportTable(ctx, tmp, t, after, until)
+		if err != nil {
+			return fmt.Errorf("export %s: %w", t.Name, err)
+		}
+		m.Tables = append(m.Tables, tm)
+		if last != nil {
+			last.ExportID = m.ExportID
+			saved = append(saved, *last)
+		}
+		metrics.ExportRows.WithLabelValues(c.Name, t.Name).Add(float64(tm.Rows))
+	}
+
+	if err := exportfile.WriteManifest(tmp, m); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return err
+	}
+	if err := j.store.SaveExportOffsets(ctx, c.Name, saved); err != nil {
+		return err
+	}
+
+	var rows int64
+	for _, tm := range m.Tables {
+		rows += tm.Rows
+	}
+	j.logger.Info("export written",
+		zap.String("consumer", c.Name),
+		zap.String("mode", m.Mode),
+		zap.String("dir", dir),
+		zap.Int64("rows", rows))
+
+	return nil
+}
+
+// deliverPending доставляет через Sink все недоставленные выгрузки потребителя, начиная со старой:
+// выгрузка, которую не удалось доставить, повторяется следующим запуском.
+func (j *Job) deliverPending(ctx context.Context, c Consumer) error {
+	root := filepath.Join(j.cfg.Dir, c.Name)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		if _, err := os.Stat(filepath.Join(dir, deliveredFile)); err == nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, exportfile.ManifestFile)); err != nil {
+			continue
+		}
+
+		if err := c.Sink.Deliver(ctx, c.Name, dir); err != nil {
+			metrics.ExportDeliveries.WithLabelValues(c.Name, "failed").Inc()
+			return err
+		}
+		metrics.ExportDeliveries.WithLabelValues(c.Name, "delivered").Inc()
+		if err := os.WriteFile(filepath.Join(dir, deliveredFile), nil, 0o644); err != nil { //nolint:gosec
+			return err
+		}
+		j.logger.Info("export delivered", zap.String("consumer", c.Name), zap.String("dir", dir))
+	}
+
+	return nil
+}
+
+func (j *Job) exportTable(
+	ctx context.Context,
+	dir string,
+	t target.ExportTable,
+	after *target.ExportOffset,
+	until time.Time,
+) (exportfile.TableManifest, *target.ExportOffset, error) {
+	tm := exportfile.TableManifest{Table: t.Name, Until: until}
+	if after != nil {
+		tm.From = &exportfile.Key{ChangeDate: after.ChangeDate, ID: after.ID}
+	}
+
+	files := make([]*os.File, 0, len(j.cfg.Formats))
+	writers := make([]exportfile.TableWriter, 0, len(j.cfg.Formats))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, format := range j.cfg.Formats {
+		f, err := os.Create(filepath.Join(dir, t.Name+"."+string(format)))
+		if err != nil {
+			return tm, nil, err
+		}
+		files = append(files, f)
+
+		w, err := exportfile.NewTableWriter(format, f, t.Columns)
+		if err != nil {
+			return tm, nil, err
+		}
+		writers = append(writers, w)
+	}
+
+	last, err := j.store.ExportRows(ctx, t, after, until, func(row []any) error {
+		for _, w := range writers {
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		tm.Rows++
+
+		return nil
+	})
+	if err != nil {
+		return tm, nil, err
+	}
+
+	for i, w := range writers {
+		if err := w.Close(); err != nil {
+			return tm, nil, err
+		}
+		if err := files[i].Close(); err != nil {
+			return tm, nil, err
+		}
+
+		fm, err := exportfile.DescribeFile(files[i].Name(), j.cfg.Formats[i])
+		if err != nil {
+			return tm, nil, err
+		}
+		tm.Files = append(tm.Files, fm)
+	}
+	if last != nil {
+		tm.To = &exportfile.Key{ChangeDate: last.ChangeDate, ID: last.ID}
+	}
+
+	return tm, last, nil
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+const watermarkProbeTimeout = 5 * time.Second
+
+// FreshnessPolicy - допустимое отставание данных джобы. Нулевое значение отключает проверку.
+type FreshnessPolicy struct {
+	// MaxStaleness - максимальное время с последнего успешного запуска.
+	MaxStaleness time.Duration
+	// MaxLag - максимальное отставание watermark витрины от now() источника.
+	MaxLag time.Duration
+}
+
+// WatermarkProber - джоба, которая умеет измерить watermark витрины относительно времени источника.
+type WatermarkProber interface {
+	Watermark(ctx context.Context) (*time.Time, time.Time, error)
+}
+
+type Freshness struct {
+	State
+	Fresh               bool       `json:"fresh"`
+	Problems            []string   `json:"problems,omitempty"`
+	StalenessSeconds    float64    `json:"stalenessSeconds"`
+	MaxStalenessSeconds float64    `json:"maxStalenessSeconds,omitempty"`
+	Watermark           *time.Time `json:"watermark,omitempty"`
+	LagSeconds          *float64   `json:"lagSeconds,omitempty"`
+	MaxLagSeconds       float64    `json:"maxLagSeconds,omitempty"`
+}
+
+type DataHealth struct {
+	Fresh bool        `json:"fresh"`
+	Jobs  []Freshness `json:"jobs"`
+}
+
+func (r *Registry) SetFreshnessPolicy(name string, p FreshnessPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.entries[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	e.freshness = p
+
+	return nil
+}
+
+// DataHealth проверяет актуальность данных каждой джобы: время с последнего успешного запуска на любом поде
+// (до первого успеха - с момента создания реестра) и отставание watermark от источника.
+func (r *Registry) DataHealth(ctx context.Context) DataHealth {
+	successes, successErr := r.jobSuccesses(ctx)
+
+	r.mu.Lock()
+	type check struct {
+		job    Job
+		state  State
+		policy FreshnessPolicy
+	}
+	checks := make([]check, 0, len(r.order))
+	for _, name := range r.order {
+		e := r.entries[name]
+		checks = append(checks, check{job: e.job, state: e.state, policy: e.freshness})
+	}
+	created := r.created
+	r.mu.Unlock()
+
+	res := DataHealth{Fresh: true, Jobs: make([]Freshness, 0, len(checks))}
+	for _, c := range checks {
+		if at, ok := successes[c.job.Name()]; ok && (c.state.LastSuccess == nil || at.After(*c.state.LastSuccess)) {
+			c.state.LastSuccess = &at
+		}
+		f := r.freshness(ctx, c.job, c.state, c.policy, created)
+		if successErr != nil && c.policy.MaxStaleness > 0 {
+			f.Problems = append(f.Problems, "last success probe failed: "+successErr.Error())
+			f.Fresh = false
+		}
+		res.Fresh = res.Fresh && f.Fresh
+		res.Jobs = append(res.Jobs, f)
+	}
+
+	return res
+}
+
+// jobSuccesses читает время успешных запусков из SuccessStore. Без него используется только состояние в памяти.
+func (r *Registry) jobSuccesses(ctx context.Context) (map[string]time.Time, error) {
+	store, ok := r.locker.(SuccessStore)
+	if !ok {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, watermarkProbeTimeout)
+	defer cancel()
+
+	return store.JobSuccesses(ctx)
+}
+
+func (r *Registry) freshness(ctx context.Context, job Job, state State, policy FreshnessPolicy, created time.Time) Freshness {
+	now := time.Now()
+	f := Freshness{
+		State:               state,
+		MaxStalenessSeconds: policy.MaxStaleness.Seconds(),
+		MaxLagSeconds:       policy.MaxLag.Seconds(),
+	}
+
+	since := created
+	if state.LastSuccess != nil {
+		since = *state.LastSuccess
+	}
+	staleness := now.Sub(since)
+	f.StalenessSeconds = staleness.Seconds()
+	if policy.MaxStaleness > 0 && staleness > policy.MaxStaleness {
+		f.Problems = append(f.Problems, fmt.Sprintf("no successful run for %s", staleness.Round(time.Second)))
+	}
+
+	if prober, ok := job.(WatermarkProber); ok && policy.MaxLag > 0 {
+		probeCtx, cancel := context.WithTimeout(ctx, watermarkProbeTimeout)
+		watermark, sourceNow, err := prober.Watermark(probeCtx)
+		cancel()
+
+		switch {
+		case err != nil:
+			f.Problems = append(f.Problems, "watermark probe failed: "+err.Error())
+		case watermark == nil:
+			f.Problems = append(f.Problems, "datamart is empty")
+		default:
+			metrics.SetWatermark(job.Name(), watermark, sourceNow)
+			lag := sourceNow.Sub(*watermark)
+			lagSeconds := lag.Seconds()
+			f.Watermark = watermark
+			f.LagSeconds = &lagSeconds
+			if lag > policy.MaxLag {
+				f.Problems = append(f.Problems, fmt.Sprintf("watermark lags source by %s", lag.Round(time.Second)))
+			}
+		}
+	}
+	f.Fresh = len(f.Problems) == 0
+
+	return f
+}
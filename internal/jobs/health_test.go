@@ -0,0 +1,94 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs"
+)
+
+type probedJob struct {
+	*fakeJob
+	lag time.Duration
+	err error
+}
+
+func (j *probedJob) Watermark(context.Context) (*time.Time, time.Time, error) {
+	now := time.Now()
+	watermark := now.Add(-j.lag)
+
+	return &watermark, now, j.err
+}
+
+func TestRegistryDataHealth(t *testing.T) {
+	fresh := &probedJob{fakeJob: newFakeJob("portin"), lag: 10 * time.Minute}
+	behind := &probedJob{fakeJob: newFakeJob("cdb-message"), lag: 3 * time.Hour}
+
+	r := jobs.NewRegistry(fakeLocker{}, zap.NewNop())
+	require.NoError(t, r.Register(fresh, behind))
+	policy := jobs.FreshnessPolicy{MaxStaleness: time.Hour, MaxLag: 2 * time.Hour}
+	require.NoError(t, r.SetFreshnessPolicy("portin", policy))
+	require.NoError(t, r.SetFreshnessPolicy("cdb-message", policy))
+	require.ErrorIs(t, r.SetFreshnessPolicy("portout", policy), jobs.ErrUnknownJob)
+
+	health := r.DataHealth(context.Background())
+	require.False(t, health.Fresh)
+	require.Len(t, health.Jobs, 2)
+	require.True(t, health.Jobs[0].Fresh)
+	require.InDelta(t, (10 * time.Minute).Seconds(), *health.Jobs[0].LagSeconds, 1)
+	require.False(t, health.Jobs[1].Fresh)
+	require.Len(t, health.Jobs[1].Problems, 1)
+	require.Contains(t, health.Jobs[1].Problems[0], "watermark lags source")
+
+	behind.lag = 0
+	behind.err = errors.New("source unavailable")
+	health = r.DataHealth(context.Background())
+	require.False(t, health.Fresh)
+	require.Contains(t, health.Jobs[1].Problems[0], "source unavailable")
+
+	behind.err = nil
+	require.True(t, r.DataHealth(context.Background()).Fresh)
+}
+
+// successLocker - витрина, в которой джобу успешно выполнил другой под.
+type successLocker struct {
+	fakeLocker
+	successes map[string]time.Time
+}
+
+func (l *successLocker) SaveJobSuccess(_ context.Context, job string, at time.Time) error {
+	l.successes[job] = at
+	return nil
+}
+
+func (l *successLocker) JobSuccesses(context.Context) (map[string]time.Time, error) {
+	return l.successes, nil
+}
+
+func TestRegistryDataHealthUsesStoredSuccess(t *testing.T) {
+	store := &successLocker{successes: map[string]time.Time{
+		"portin":      time.Now().Add(-time.Minute),
+		"cdb-message": time.Now().Add(-2 * time.Hour),
+	}}
+
+	r := jobs.NewRegistry(store, zap.NewNop())
+	require.NoError(t, r.Register(newFakeJob("portin"), newFakeJob("cdb-message")))
+	policy := jobs.FreshnessPolicy{MaxStaleness: time.Hour}
+	require.NoError(t, r.SetFreshnessPolicy("portin", policy))
+	require.NoError(t, r.SetFreshnessPolicy("cdb-message", policy))
+
+	// Джобы выполнялись на другом поде: этот реестр их не запускал.
+	health := r.DataHealth(context.Background())
+	require.True(t, health.Jobs[0].Fresh)
+	require.NotNil(t, health.Jobs[0].LastSuccess)
+	require.False(t, health.Jobs[1].Fresh)
+
+	require.NoError(t, r.Trigger(context.Background(), "cdb-message"))
+	require.WithinDuration(t, time.Now(), store.successes["cdb-message"], time.Second)
+	require.True(t, r.DataHealth(context.Background()).Fresh)
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// RegisterRoutes добавляет ручной запуск джоб (POST /jobs/{name}/run), их состояние (GET /jobs)
+// и проверку актуальности данных (GET /health/data).
+func (r *Registry) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("POST /jobs/{name}/run", r.runHandler)
+	mux.HandleFunc("GET /jobs", r.statesHandler)
+	mux.HandleFunc("GET /health/data", r.dataHealthHandler)
+}
+
+func (r *Registry) runHandler(w http.ResponseWriter, req *http.Request) {
+	name := req.PathValue("name")
+
+	err := r.Trigger(req.Context(), name)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, ErrUnknownJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrUpstreamFailed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		r.logger.Error("job run failed", zap.String("job", name), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (r *Registry) statesHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(r.States()); err != nil {
+		r.logger.Error("job states encode failed", zap.Error(err))
+	}
+}
+
+// dataHealthHandler отвечает 503, если данные хотя бы одной джобы устарели.
+// Не используется в readiness-пробе: сбой источника не должен приводить к рестарту пода.
+func (r *Registry) dataHealthHandler(w http.ResponseWriter, req *http.Request) {
+	health := r.DataHealth(req.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !health.Fresh {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(health); err != nil {
+		r.logger.Error("data health encode failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,198 @@
+package portin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// Статусы заявок на отмену в portin-cancel-db.
+const (
+	cancelRequested = 50
+	cancelConfirmed = 51
+	cancelRejected  = -51
+)
+
+var cancelStatuses = []int64{cancelRequested, cancelConfirmed, cancelRejected}
+
+// isCancelled - по последнему статусу отмены заявка считается отмененной (статус 11 в витрине).
+// Отклоненная отмена (-51) статус заявки не меняет.
+func isCancelled(status int) bool {
+	return status == cancelRequested || status == cancelConfirmed
+}
+
+func (j *Job) cancelTable() (string, error) {
+	table := j.cfg.CancelTable
+	if table == "" {
+		table = "orders"
+	}
+	if !regexp.MustCompile(`^[a-zA-Z0-9_\.\"]+$`).MatchString(table) {
+		return "", fmt.Errorf("unsafe cancel table name: %s", table)
+	}
+
+	return table, nil
+}
+
+func (j *Job) loadCancelStatus(ctx context.Context, orderID string) (bool, error) {
+	cancelled, err := j.cancelledOrders(ctx, j.cancelDB, []string{orderID})
+	if err != nil {
+		return false, err
+	}
+
+	return cancelled[orderID], nil
+}
+
+// cancelledOrders возвращает заявки из orderIDs, отмененные в portin-cancel-db.
+// Решение принимается по последней по changing_date записи отмены заявки.
+func (j *Job) cancelledOrders(ctx context.Context, cancelDB queryer, orderIDs []string) (map[string]bool, error) {
+	res := make(map[string]bool)
+	if len(orderIDs) == 0 {
+		return res, nil
+	}
+	table, err := j.cancelTable()
+	if err != nil {
+		return nil, err
+	}
+
+	defer metrics.ObserveQuery(metrics.DBPortInCancel, "cancelled_orders")()
+
+	query := fmt.Sprintf(`SELECT DISTINCT ON (order_id) order_id, status FROM %s
+WHERE order_id = ANY($1::%s[]) AND status = ANY($2)
+ORDER BY order_id, changing_date DESC`, table, j.cfg.OrderIDKind.sqlType())
+	rows, err := cancelDB.QueryContext(ctx, query, pq.Array(orderIDs), pq.Array(cancelStatuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			orderID string
+			status  int
+		)
+		if err := rows.Scan(&orderID, &status); err != nil {
+			return nil, err
+		}
+		if isCancelled(status) {
+			res[orderID] = true
+		}
+	}
+
+	return res, rows.Err()
+}
+
+// changedCancelOrders возвращает пачку заявок, статус отмены которых изменился после from, и время последнего изменения в пачке.
+// Заявки с тем же временем, что и последняя, попадают в пачку целиком, чтобы watermark не разделил их.
+func (j *Job) changedCancelOrders(ctx context.Context, cancelDB queryer, from time.Time) ([]string, time.Time, error) {
+	table, err := j.cancelTable()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	defer metrics.ObserveQuery(metrics.DBPortInCancel, "changed_cancel_orders")()
+
+	query := fmt.Sprintf(`WITH c AS (
+  SELECT order_id, max(changing_date) AS changed FROM %s
+  WHERE changing_date > $1 AND status = ANY($2)
+  GROUP BY order_id
+)
+SELECT order_id, changed FROM c
+WHERE changed <= (SELECT max(changed) FROM (SELECT changed FROM c ORDER BY changed LIMIT $3) b)
+ORDER BY changed, order_id`, table)
+	rows, err := cancelDB.QueryContext(ctx, query, from, pq.Array(cancelStatuses), j.cfg.BatchSize)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var (
+		ids  []string
+		last time.Time
+	)
+	for rows.Next() {
+		var orderID string
+		if err := rows.Scan(&orderID, &last); err != nil {
+			return nil, time.Time{}, err
+		}
+		ids = append(ids, orderID)
+	}
+
+	return ids, last, rows.Err()
+}
+
+// cancelWatermarkKey - ключ etl_state, под которым хранится watermark опроса таблицы отмен.
+func (j *Job) cancelWatermarkKey() string { return j.Name() + ":cancel" }
+
+// processCancelChanges повторно загружает в mnp_request заявки, у которых изменилась отмена,
+// даже если строка orders не менялась. Таблица отмен читается пачками от собственного watermark,
+// без него - от start (watermark заявок). При первой загрузке (start == nil) статусы отмен читаются вместе
+// с заявками, и сохраняется только watermark. Возвращает true, если пачка полная и изменения еще остались.
+func (j *Job) processCancelChanges(ctx context.Context, src sources, tx *sql.Tx, start *time.Time) (bool, error) {
+	from, err := j.store.JobWatermark(ctx, j.cancelWatermarkKey())
+	if err != nil {
+		return false, err
+	}
+	if from == nil {
+		from = start
+	}
+
+	// Watermark не уходит дальше now() - Lookback: changing_date проставляется до commit,
+	// и отмена может стать видимой позже более новых.
+	var cancelNow time.Time
+	if err := src.cancel.QueryRowContext(ctx, `SELECT now()`).Scan(&cancelNow); err != nil {
+		return false, err
+	}
+	limit := cancelNow.Add(-j.cfg.Lookback)
+
+	var (
+		ids  []string
+		last time.Time
+	)
+	if from != nil {
+		ids, last, err = j.changedCancelOrders(ctx, src.cancel, *from)
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(ids) > 0 {
+		cancelled, err := j.cancelledOrders(ctx, src.cancel, ids)
+		if err != nil {
+			return false, err
+		}
+		if err := j.backfillOrders(ctx, src.orders, tx, ids, cancelled); err != nil {
+			return false, err
+		}
+		j.logger.Info("cancel changes processed", zap.Int("orders", len(ids)), zap.Time("last_change", last))
+	}
+
+	next, more := nextCancelWatermark(from, len(ids), j.cfg.BatchSize, last, limit)
+
+	return more, j.store.SaveJobWatermark(ctx, tx, j.cancelWatermarkKey(), next)
+}
+
+// nextCancelWatermark возвращает watermark таблицы отмен после пачки из n заявок, последняя из которых
+// изменилась в last, и признак, что изменения еще остались. limit - now() - Lookback portin-cancel-db.
+// Неполная пачка означает, что все изменения до limit прочитаны. Watermark не уходит назад от from,
+// а без from (первая загрузка) сразу равен limit.
+func nextCancelWatermark(from *time.Time, n, batchSize int, last, limit time.Time) (time.Time, bool) {
+	if from == nil {
+		return limit, false
+	}
+
+	next, more := limit, false
+	if n >= batchSize && last.Before(limit) {
+		next, more = last, true
+	}
+	if next.Before(*from) {
+		next = *from
+	}
+
+	return next, more
+}
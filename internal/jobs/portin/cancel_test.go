@@ -0,0 +1,151 @@
+package portin_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+func TestNextCancelWatermark(t *testing.T) {
+	base := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	limit := base.Add(10 * time.Hour)
+	at := func(h int) *time.Time {
+		ts := base.Add(time.Duration(h) * time.Hour)
+		return &ts
+	}
+
+	for _, tc := range []struct {
+		name     string
+		from     *time.Time
+		n        int
+		last     time.Time
+		wantNext time.Time
+		wantMore bool
+	}{
+		{name: "first load", wantNext: limit},
+		{name: "no changes", from: at(1), wantNext: limit},
+		{name: "partial batch", from: at(1), n: 1, last: *at(2), wantNext: limit},
+		{name: "full batch", from: at(1), n: 2, last: *at(2), wantNext: *at(2), wantMore: true},
+		{name: "full batch with ties", from: at(1), n: 3, last: *at(2), wantNext: *at(2), wantMore: true},
+		{name: "full batch reaches limit", from: at(1), n: 2, last: limit, wantNext: limit},
+		{name: "full batch after limit", from: at(1), n: 2, last: limit.Add(time.Minute), wantNext: limit},
+		{name: "from after limit", from: at(12), wantNext: *at(12)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			next, more := portin.NextCancelWatermark(tc.from, tc.n, 2, tc.last, limit)
+			require.True(t, tc.wantNext.Equal(next), "next %s, want %s", next, tc.wantNext)
+			require.Equal(t, tc.wantMore, more)
+		})
+	}
+}
+
+func TestProcessCancelChanges(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+	ctx := context.Background()
+
+	_, err := db.Exec(`
+CREATE TABLE orders (
+	order_id bigint PRIMARY KEY,
+	state int NOT NULL,
+	creation_date timestamptz,
+	due_date timestamptz,
+	changing_date timestamptz NOT NULL,
+	cdb_process_id varchar,
+	order_type varchar NOT NULL,
+	order_data jsonb
+);
+CREATE TABLE cancel_orders (
+	order_id bigint NOT NULL,
+	status int NOT NULL,
+	changing_date timestamptz NOT NULL
+);
+INSERT INTO orders (order_id, state, changing_date, order_type, order_data)
+SELECT id, 1, now() - interval '1 day', 'portin', '{"person": {"firstName": "Ivan"}, "status": {"code": "created"}}'
+FROM generate_series(1, 5) id;
+-- Заявки 2 и 3 отменены в одно время, отмена 4 еще внутри Lookback, статус 5 не относится к отмене.
+INSERT INTO cancel_orders (order_id, status, changing_date) VALUES
+	(1, 50, now() - interval '3 hours'),
+	(2, 51, now() - interval '2 hours'),
+	(3, 50, now() - interval '2 hours'),
+	(4, 50, now() - interval '30 seconds'),
+	(5, 99, now() - interval '3 hours');`)
+	require.NoError(t, err)
+
+	job := portin.NewJob(portin.Config{BatchSize: 2, Lookback: time.Minute, CancelTable: "cancel_orders"},
+		db, db, db, store, zap.NewNop())
+	key := job.Name() + ":cancel"
+
+	var dbNow, tie time.Time
+	require.NoError(t, db.QueryRow(`SELECT now()`).Scan(&dbNow))
+	require.NoError(t, db.QueryRow(`SELECT changing_date FROM cancel_orders WHERE order_id = 2`).Scan(&tie))
+	limit := dbNow.Add(-time.Minute)
+
+	cancelledOrders := func() []string {
+		t.Helper()
+		rows, err := db.Query(`SELECT order_id FROM mnp_request WHERE request_status_id = 11 ORDER BY order_id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		var res []string
+		for rows.Next() {
+			var id string
+			require.NoError(t, rows.Scan(&id))
+			res = append(res, id)
+		}
+		require.NoError(t, rows.Err())
+
+		return res
+	}
+	watermark := func() time.Time {
+		t.Helper()
+		wm, err := store.JobWatermark(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, wm)
+
+		return *wm
+	}
+
+	t.Run("first load saves only the watermark", func(t *testing.T) {
+		more, err := job.ProcessCancelChanges(ctx, nil)
+		require.NoError(t, err)
+		require.False(t, more)
+		require.WithinDuration(t, limit, watermark(), 5*time.Second)
+		require.Empty(t, cancelledOrders())
+	})
+
+	_, err = db.Exec(`DELETE FROM etl_state WHERE job_name = $1`, key)
+	require.NoError(t, err)
+	start := dbNow.Add(-4 * time.Hour)
+
+	t.Run("full batch takes orders with the same time whole", func(t *testing.T) {
+		more, err := job.ProcessCancelChanges(ctx, &start)
+		require.NoError(t, err)
+		require.True(t, more)
+		require.True(t, tie.Equal(watermark()))
+		require.Equal(t, []string{"1", "2", "3"}, cancelledOrders())
+	})
+
+	t.Run("partial batch stops at lookback", func(t *testing.T) {
+		more, err := job.ProcessCancelChanges(ctx, &start)
+		require.NoError(t, err)
+		require.False(t, more)
+		require.WithinDuration(t, limit, watermark(), 5*time.Second)
+		require.Equal(t, []string{"1", "2", "3", "4"}, cancelledOrders())
+	})
+
+	t.Run("watermark does not move back", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE etl_state SET watermark = now() + interval '1 hour' WHERE job_name = $1`, key)
+		require.NoError(t, err)
+		ahead := watermark()
+
+		more, err := job.ProcessCancelChanges(ctx, &start)
+		require.NoError(t, err)
+		require.False(t, more)
+		require.True(t, ahead.Equal(watermark()))
+	})
+}
@@ -0,0 +1,233 @@
+package portin
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+)
+
+// runCDC загружает изменения из слотов логической репликации portin-orders-db и portin-cancel-db
+// вместо опроса по changing_date. Затронутые заявки перечитываются в снимке источника
+// и загружаются тем же путем, что и backfill. Без CancelCDC таблица отмен опрашивается как обычно.
+func (j *Job) runCDC(ctx context.Context) error {
+	if err := j.initCDC(ctx); err != nil {
+		return err
+	}
+	if err := j.drainCDC(ctx, *j.cfg.OrdersCDC, false); err != nil {
+		return err
+	}
+	if j.cfg.CancelCDC != nil {
+		if err := j.drainCDC(ctx, *j.cfg.CancelCDC, true); err != nil {
+			return err
+		}
+	}
+	if err := j.runBackfill(ctx, j.cfg.CancelCDC == nil); err != nil {
+		return err
+	}
+	j.reportWatermark(ctx)
+
+	return nil
+}
+
+// initCDC создает слоты, которые еще не читались, и догружает опросом изменения, сделанные до их создания:
+// после выключения опроса или при первичной загрузке. Опрос повторяется, пока продвигается watermark
+// заявок или в таблице отмен остаются непрочитанные изменения. Позиция создания слота сохраняется только после догрузки, поэтому прерванная
+// догрузка повторяется при следующем запуске, а слот хранит изменения с момента создания.
+func (j *Job) initCDC(ctx context.Context) error {
+	slots := []cdc.Config{*j.cfg.OrdersCDC}
+	if j.cfg.CancelCDC != nil {
+		slots = append(slots, *j.cfg.CancelCDC)
+	}
+
+	created := make(map[string]cdc.LSN)
+	for _, cfg := range slots {
+		pos, err := j.store.CDCPosition(ctx, cfg.Slot)
+		if err != nil {
+			return err
+		}
+		if pos != "" {
+			continue
+		}
+		lsn, err := cdc.CreateSlot(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		created[cfg.Slot] = lsn
+	}
+	if len(created) == 0 {
+		return nil
+	}
+
+	for {
+		before, err := j.store.MaxFromDate(ctx, j.cfg.Source)
+		if err != nil {
+			return err
+		}
+		moreCancel, err := j.runPoll(ctx)
+		if err != nil {
+			return err
+		}
+		after, err := j.store.MaxFromDate(ctx, j.cfg.Source)
+		if err != nil {
+			return err
+		}
+		if !moreCancel && sameTime(before, after) {
+			break
+		}
+	}
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for slot, lsn := range created {
+		if err := j.store.SaveCDCPosition(ctx, tx, j.LockKey(), slot, lsn.String()); err != nil {
+			return err
+		}
+		j.logger.Info("cdc slot created", zap.String("slot", slot), zap.Stringer("lsn", lsn))
+	}
+
+	return tx.Commit()
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+func (j *Job) drainCDC(ctx context.Context, cfg cdc.Config, cancelSlot bool) error {
+	pos, err := j.store.CDCPosition(ctx, cfg.Slot)
+	if err != nil {
+		return err
+	}
+	from, err := cdc.ParseLSN(pos)
+	if err != nil {
+		return err
+	}
+
+	to, err := cdc.Drain(ctx, cfg, from, func(ctx context.Context, b cdc.Batch) error {
+		return j.applyChanges(ctx, cfg.Slot, cancelSlot, b)
+	})
+	if err != nil {
+		return err
+	}
+	j.logger.Info("cdc slot drained", zap.String("slot", cfg.Slot), zap.Stringer("from", from), zap.Stringer("to", to))
+
+	return nil
+}
+
+// applyChanges перезагружает заявки, затронутые пачкой, и сохраняет позицию слота в той же транзакции.
+// Изменения таблицы отмен перезагружают только mnp_request, orders_log - только историю.
+func (j *Job) applyChanges(ctx context.Context, slot string, cancelSlot bool, b cdc.Batch) error {
+	orders := make(map[string]struct{})
+	versions := make(map[string]struct{})
+	for _, c := range b.Changes {
+		if c.Op == cdc.OpDelete {
+			continue
+		}
+		orderID, err := j.cfg.OrderIDKind.parse(c.Values["order_id"])
+		if err != nil {
+			j.logger.Warn("cdc change without order_id", zap.String("table", c.Table), zap.Error(err))
+			continue
+		}
+		switch {
+		case cancelSlot, c.Table == ordersTable:
+			orders[orderID] = struct{}{}
+		case c.Table == ordersLogTable:
+			versions[orderID] = struct{}{}
+		}
+	}
+
+	src, snapshotAt, release, err := j.beginSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	changedOrders, changedVersions := keys(orders), keys(versions)
+	cancelled, err := j.cancelledOrders(ctx, src.cancel, slices.Concat(changedOrders, changedVersions))
+	if err != nil {
+		return err
+	}
+	if len(changedOrders) > 0 {
+		if err := j.backfillOrders(ctx, src.orders, tx, changedOrders, cancelled); err != nil {
+			return err
+		}
+	}
+	if len(changedVersions) > 0 {
+		if err := j.backfillVersions(ctx, src.orders, tx, changedVersions, cancelled); err != nil {
+			return err
+		}
+	}
+	if err := j.store.SaveCDCPosition(ctx, tx, j.LockKey(), slot, b.EndLSN.String()); err != nil {
+		return err
+	}
+	if err := j.store.SaveRunSnapshot(ctx, tx, j.LockKey(), snapshotAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// runBackfill обрабатывает очередь etl_backfill отдельной транзакцией.
+// pollCancel - опросить таблицу отмен по watermark витрины.
+func (j *Job) runBackfill(ctx context.Context, pollCancel bool) error {
+	var depth *time.Time
+	if pollCancel {
+		watermark, err := j.store.MaxFromDate(ctx, j.cfg.Source)
+		if err != nil {
+			return err
+		}
+		if watermark != nil {
+			t := watermark.Add(-j.cfg.Lookback)
+			depth = &t
+		}
+	}
+
+	src, _, release, err := j.beginSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if pollCancel {
+		if _, err := j.processCancelChanges(ctx, src, tx, depth); err != nil {
+			return err
+		}
+	}
+	if err := j.processBackfill(ctx, src, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func keys(set map[string]struct{}) []string {
+	res := make([]string, 0, len(set))
+	for k := range set {
+		res = append(res, k)
+	}
+
+	return res
+}
@@ -0,0 +1,102 @@
+package portin
+
+import (
+	"context"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+// Доступ к внутренним функциям пакета для тестов portin_test.
+
+func (j *Job) ParseOrderID(key string) (string, error) { return j.parseOrderID(key) }
+
+func CompareIntOrderIDs(a, b string) int { return compareIntOrderIDs(a, b) }
+
+// SplitOrders возвращает размеры срезов splitOrders для пачки из n заявок.
+func SplitOrders(n, workers int) []int {
+	var res []int
+	for _, part := range splitOrders(make([]parsedOrder, n), workers) {
+		res = append(res, len(part))
+	}
+
+	return res
+}
+
+// SplitOrderIDRange возвращает диапазоны splitOrderIDRange парами [from, to].
+func SplitOrderIDRange(from, to int64, n int) [][2]int64 {
+	var res [][2]int64
+	for _, r := range splitOrderIDRange(orderIDRange{from: from, to: to}, n) {
+		res = append(res, [2]int64{r.from, r.to})
+	}
+
+	return res
+}
+
+// ExtractOrderKeys читает пачку orders после depth последовательно и параллельно в одном снимке
+// и возвращает ключи "changing_date/order_id" строк в порядке результата.
+func (j *Job) ExtractOrderKeys(ctx context.Context, depth *time.Time) (sequential, parallel []string, err error) {
+	src, _, release, err := j.beginSnapshot(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	orders, err := j.extractOrders(ctx, src.orders, depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, o := range orders {
+		sequential = append(sequential, o.ChangingDate.UTC().Format(time.RFC3339Nano)+"/"+o.OrderID)
+	}
+	parsed, err := j.extractOrdersParallel(ctx, src, depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, o := range parsed {
+		parallel = append(parallel, o.ChangingDate.UTC().Format(time.RFC3339Nano)+"/"+o.OrderID)
+	}
+
+	return sequential, parallel, nil
+}
+
+// ProfileDrift накапливает профиль батча payloads и возвращает его отклонения от профиля с путями known.
+func ProfileDrift(payloads []transform.OrderPayload, known []string) (missing, added, unknown []string) {
+	p := newPayloadProfile(ordersTable)
+	for _, payload := range payloads {
+		p.add(payload)
+	}
+	knownSet := make(map[string]struct{}, len(known))
+	for _, path := range known {
+		knownSet[path] = struct{}{}
+	}
+	d := p.drift(knownSet)
+
+	return d.missing, d.added, d.unknown
+}
+
+func NextCancelWatermark(from *time.Time, n, batchSize int, last, limit time.Time) (time.Time, bool) {
+	return nextCancelWatermark(from, n, batchSize, last, limit)
+}
+
+// ProcessCancelChanges выполняет один опрос таблицы отмен в снимке и фиксирует его.
+func (j *Job) ProcessCancelChanges(ctx context.Context, start *time.Time) (bool, error) {
+	src, _, release, err := j.beginSnapshot(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	more, err := j.processCancelChanges(ctx, src, tx, start)
+	if err != nil {
+		return false, err
+	}
+
+	return more, tx.Commit()
+}
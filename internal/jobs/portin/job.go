@@ -3,24 +3,55 @@ package portin
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/cdc"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
 	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
 )
 
 var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/portin")
 
+const (
+	name = "portin"
+	// DefaultSource - источник единственной установки MNPHUB, заданной без PORTIN_SOURCES.
+	DefaultSource = target.DefaultSource
+
+	ordersTable    = "orders"
+	ordersLogTable = "orders_log"
+)
+
+var (
+	ErrJobRunning      = errors.New("job is already running")
+	ErrNotPortInRecord = errors.New("quarantine record does not belong to portin job")
+)
+
 type Config struct {
-	Lookback    time.Duration
-	BatchSize   int
-	Prefix      string
+	// Source - имя установки MNPHUB, значение колонки source витрины. Пусто - DefaultSource.
+	Source    string
+	Lookback  time.Duration
+	BatchSize int
+	// Prefix - префикс order_number: order_number = Prefix + order_id.
+	Prefix string
+	// OrderIDKind - тип order_id источника. Пусто - OrderIDInt.
+	OrderIDKind OrderIDKind
 	CancelTable string
+	Location    *time.Location
+	// Workers - число параллельных чтений orders по диапазонам order_id. 1 - последовательное чтение.
+	Workers int
+	// OrdersCDC включает чтение orders и orders_log из слота логической репликации вместо опроса.
+	// CancelCDC - слот таблицы отмен, используется только вместе с OrdersCDC.
+	OrdersCDC *cdc.Config
+	CancelCDC *cdc.Config
+	Schedule  scheduler.Spec
 }
 
 type Job struct {
@@ -36,55 +67,142 @@ func NewJob(cfg Config, sourceDB, cancelDB, targetDB *sql.DB, store *target.Stor
 	if cfg.BatchSize <= 0 {
 		cfg.BatchSize = 5000
 	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Source == "" {
+		cfg.Source = DefaultSource
+	}
+	if cfg.OrderIDKind == "" {
+		cfg.OrderIDKind = OrderIDInt
+	}
 
-	return &Job{cfg: cfg, sourceDB: sourceDB, cancelDB: cancelDB, targetDB: targetDB, store: store, logger: logger.Named("portin-job")}
+	return &Job{cfg: cfg, sourceDB: sourceDB, cancelDB: cancelDB, targetDB: targetDB, store: store, logger: logger.Named(jobName(cfg.Source) + "-job")}
 }
 
+// jobName возвращает имя джобы источника source. Джоба DefaultSource называется portin,
+// чтобы сохранить ключ блокировки, etl_state, карантин и backfill единственной установки.
+func jobName(source string) string {
+	if source == DefaultSource {
+		return name
+	}
+
+	return name + "-" + source
+}
+
+func (j *Job) Name() string { return jobName(j.cfg.Source) }
+
+func (j *Job) LockKey() string { return j.Name() + "-dag" }
+
+func (j *Job) DependsOn() []string { return nil }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
 func (j *Job) Run(ctx context.Context) error {
 	ctx, span := tracer.Start(ctx, "Run")
 	defer span.End()
-	locked, err := j.store.TryLockJob(ctx, "portin-dag")
-	if err != nil {
-		return err
-	}
-	if !locked {
-		j.logger.Info("job already running")
-		return nil
+
+	if j.cfg.OrdersCDC != nil {
+		return j.runCDC(ctx)
 	}
-	defer j.store.UnlockJob(context.Background(), "portin-dag")
 
-	depth, err := j.store.MaxFromDate(ctx)
+	_, err := j.runPoll(ctx)
+
+	return err
+}
+
+// runPoll загружает одну пачку изменений orders, orders_log и таблицы отмен после watermark витрины.
+// Возвращает true, если в таблице отмен остались непрочитанные изменения.
+func (j *Job) runPoll(ctx context.Context) (bool, error) {
+	ctx, span := tracer.Start(ctx, "runPoll")
+	defer span.End()
+
+	depth, err := j.store.MaxFromDate(ctx, j.cfg.Source)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if depth != nil {
 		t := depth.Add(-j.cfg.Lookback)
 		depth = &t
 	}
 
-	cancelMap, err := j.loadCancelStatuses(ctx, depth)
+	src, snapshotAt, release, err := j.beginSnapshot(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
+	defer release()
+	span.SetAttributes(attribute.String("snapshot_at", snapshotAt.Format(time.RFC3339Nano)))
 
-	tx, err := j.targetDB.BeginTx(ctx, nil)
+	stageRun, err := j.beginStageRun(ctx, src)
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer j.dropStageRun(ctx, stageRun)
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return false, err
 	}
 	defer tx.Rollback() //nolint:errcheck
 
-	if err := j.processOrders(ctx, tx, depth, cancelMap); err != nil {
-		return err
+	if err := j.processOrders(ctx, src, tx, depth, stageRun); err != nil {
+		return false, err
 	}
-	if err := j.processOrderHistory(ctx, tx, depth, cancelMap); err != nil {
-		return err
+	if err := j.processOrderHistory(ctx, src, tx, depth); err != nil {
+		return false, err
+	}
+	moreCancel, err := j.processCancelChanges(ctx, src, tx, depth)
+	if err != nil {
+		return false, err
+	}
+	if err := j.processBackfill(ctx, src, tx); err != nil {
+		return false, err
+	}
+	if err := j.store.SaveRunSnapshot(ctx, tx, j.LockKey(), snapshotAt); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
 	}
 
-	return tx.Commit()
+	j.logger.Info("portin run committed", zap.Time("snapshot_at", snapshotAt))
+	j.reportWatermark(ctx)
+
+	return moreCancel, nil
+}
+
+// reportWatermark обновляет метрики отставания витрины. Ошибка не влияет на результат запуска.
+func (j *Job) reportWatermark(ctx context.Context) {
+	watermark, sourceNow, err := j.Watermark(ctx)
+	if err != nil {
+		j.logger.Warn("watermark metrics failed", zap.Error(err))
+		return
+	}
+	metrics.SetWatermark(j.Name(), watermark, sourceNow)
+}
+
+// Watermark возвращает последнюю загруженную в витрину дату изменения и текущее время источника.
+func (j *Job) Watermark(ctx context.Context) (*time.Time, time.Time, error) {
+	watermark, err := j.store.MaxFromDate(ctx, j.cfg.Source)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	defer metrics.ObserveQuery(metrics.DBPortIn, "now")()
+
+	var sourceNow time.Time
+	if err := j.sourceDB.QueryRowContext(ctx, `SELECT now()`).Scan(&sourceNow); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return watermark, sourceNow, nil
 }
 
 type sourceOrder struct {
-	OrderID      int64
+	OrderID      string
 	State        int
 	CreationDate sql.NullTime
 	DueDate      sql.NullTime
@@ -94,172 +212,402 @@ type sourceOrder struct {
 	OrderData    []byte
 }
 
-func (j *Job) processOrders(ctx context.Context, tx *sql.Tx, depth *time.Time, cancelMap map[int64]bool) error {
-	query := `SELECT order_id, state, creation_date, due_date, changing_date, cdb_process_id, order_type, order_data
+type sourceOrderVersion struct {
+	sourceOrder
+	VersionDate time.Time
+	ToDate      sql.NullTime
+}
+
+const ordersQuery = `SELECT order_id, state, creation_date, due_date, changing_date, cdb_process_id, order_type, order_data
 FROM orders
-WHERE ($1::timestamp is null or changing_date > $1)
+`
+
+const ordersLogQuery = `SELECT l.order_id, l.state, l.creation_date, l.due_date, l.version_date, l.cdb_process_id, l.order_type, l.order_data_log,
+(
+	coalesce(
+		(select min(l2.version_date) from orders_log l2 where l2.order_id = l.order_id and l2.version_date > l.version_date),
+		o.changing_date
+	) - interval '1 second'
+) as to_date
+FROM orders_log l
+JOIN orders o ON o.order_id = l.order_id
+`
+
+// processOrders загружает пачку orders. При stageRun != 0 пачку преобразуют и пишут в staging воркеры (stageOrders).
+func (j *Job) processOrders(ctx context.Context, src sources, tx *sql.Tx, depth *time.Time, stageRun int64) error {
+	orders, err := j.extractParsedOrders(ctx, src, depth)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.OrderID)
+	}
+	cancelled, err := j.cancelledOrders(ctx, src.cancel, ids)
+	if err != nil {
+		return err
+	}
+
+	profile := newPayloadProfile(ordersTable)
+
+	parsed := make([]parsedOrder, 0, len(orders))
+	for _, o := range orders {
+		if o.err != nil {
+			if err := j.quarantine(ctx, tx, ordersTable, o.OrderID, o.ChangingDate, o.OrderData, o.err); err != nil {
+				return err
+			}
+			continue
+		}
+		profile.add(o.payload)
+		parsed = append(parsed, o)
+	}
+	if stageRun != 0 {
+		err = j.stageOrders(ctx, tx, stageRun, parsed, cancelled)
+	} else {
+		var b orderBatch
+		for _, o := range parsed {
+			j.addOrder(&b, o.sourceOrder, o.payload, cancelled[o.OrderID])
+		}
+		err = j.writeBatch(ctx, tx, b)
+	}
+	if err != nil {
+		return err
+	}
+
+	return j.reportProfile(ctx, tx, profile)
+}
+
+// extractOrders читает пачку portin-заявок orders, измененных после depth.
+func (j *Job) extractOrders(ctx context.Context, sourceDB queryer, depth *time.Time) ([]sourceOrder, error) {
+	query := ordersQuery + `WHERE ($1::timestamptz is null or changing_date > $1)
 ORDER BY changing_date, order_id
 LIMIT $2`
-	rows, err := j.sourceDB.QueryContext(ctx, query, depth, j.cfg.BatchSize)
+	observe := metrics.ObserveQuery(metrics.DBPortIn, ordersTable)
+	rows, err := sourceDB.QueryContext(ctx, query, depth, j.cfg.BatchSize)
+	observe()
 	if err != nil {
-		return err
+		return nil, err
 	}
+
+	return j.collectOrders(rows)
+}
+
+// collectOrders читает portin-заявки из rows и закрывает их.
+func (j *Job) collectOrders(rows *sql.Rows) ([]sourceOrder, error) {
 	defer rows.Close()
 
+	var res []sourceOrder
 	for rows.Next() {
-		var o sourceOrder
-		if err := rows.Scan(&o.OrderID, &o.State, &o.CreationDate, &o.DueDate, &o.ChangingDate, &o.CDBProcessID, &o.OrderType, &o.OrderData); err != nil {
-			return err
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
 		}
+		metrics.RowsExtracted.WithLabelValues(j.Name(), ordersTable).Inc()
 		if o.OrderType != "portin" {
+			metrics.RowsSkipped.WithLabelValues(j.Name(), ordersTable, metrics.SkipOrderType).Inc()
 			continue
 		}
+		res = append(res, o)
+	}
 
-		payload, err := transform.ParseOrderPayload(o.OrderData)
-		if err != nil {
-			return err
-		}
-		subscriberType := transform.SubscriberType(payload)
-		if subscriberType != "Person" {
-			continue
-		}
-		statusID := mapStatus(o.State)
-		if cancelMap[o.OrderID] {
-			statusID = 11
-		}
+	return res, rows.Err()
+}
 
-		request := target.Request{
-			OrderNumber:     fmt.Sprintf("%s%d", j.cfg.Prefix, o.OrderID),
-			RequestStatusID: statusID,
-			RequestDate:     nullTime(o.CreationDate),
-			ContractDate:    transform.ParseContractDate(payload.Contract.DocumentDate),
-			PortDate:        nullTime(o.DueDate),
-			FromDate:        o.ChangingDate,
-			CDBID:           o.CDBProcessID.String,
-			ProcessType:     payload.ProcessType,
-			PortType:        o.OrderType,
-			SubscriberType:  subscriberType,
-			MessageCode:     payload.Status.Code,
-			RejectReason:    transform.ParseRejectReason(o.State, payload.Status.Message),
-			OrderID:         o.OrderID,
-		}
-		if err := j.store.UpsertRequest(ctx, tx, request); err != nil {
-			return err
-		}
+func (j *Job) processOrderHistory(ctx context.Context, src sources, tx *sql.Tx, depth *time.Time) error {
+	versions, err := j.extractOrderVersions(ctx, src.orders, depth)
+	if err != nil {
+		return err
+	}
+	cancelled, err := j.cancelledOrders(ctx, src.cancel, versionOrderIDs(versions))
+	if err != nil {
+		return err
+	}
 
-		for _, n := range payload.PortationNumbers {
-			if n.MSISDN == "" {
-				continue
-			}
-			err = j.store.UpsertReqNumber(ctx, tx, target.RequestNumber{
-				ReqID:       request.OrderNumber,
-				RecipientID: payload.Recipient.CDBCode,
-				MSISDN:      n.MSISDN,
-				RN:          n.RN,
-			})
-			if err != nil {
+	profile := newPayloadProfile(ordersLogTable)
+
+	var b orderBatch
+	for _, v := range versions {
+		payload, err := transform.ParseOrderPayload(v.OrderData)
+		if err != nil {
+			if err := j.quarantine(ctx, tx, ordersLogTable, v.OrderID, v.VersionDate, v.OrderData, err); err != nil {
 				return err
 			}
+			continue
 		}
+		profile.add(payload)
+		j.addVersion(&b, v, payload, cancelled[v.OrderID])
+	}
+	if err := j.writeBatch(ctx, tx, b); err != nil {
+		return err
 	}
 
-	return rows.Err()
+	return j.reportProfile(ctx, tx, profile)
 }
 
-func (j *Job) processOrderHistory(ctx context.Context, tx *sql.Tx, depth *time.Time, cancelMap map[int64]bool) error {
-	query := `SELECT l.order_id, l.state, l.creation_date, l.due_date, l.version_date, l.cdb_process_id, l.order_type, l.order_data_log,
-(
-	coalesce(
-		(select min(l2.version_date) from orders_log l2 where l2.order_id = l.order_id and l2.version_date > l.version_date),
-		o.changing_date
-	) - interval '1 second'
-) as to_date
-FROM orders_log l
-JOIN orders o ON o.order_id = l.order_id
-WHERE ($1::timestamp is null or l.version_date > $1)
+// extractOrderVersions читает пачку portin-версий orders_log, созданных после depth.
+func (j *Job) extractOrderVersions(ctx context.Context, sourceDB queryer, depth *time.Time) ([]sourceOrderVersion, error) {
+	query := ordersLogQuery + `WHERE ($1::timestamptz is null or l.version_date > $1)
 ORDER BY l.version_date, l.order_id
 LIMIT $2`
-	rows, err := j.sourceDB.QueryContext(ctx, query, depth, j.cfg.BatchSize)
+	observe := metrics.ObserveQuery(metrics.DBPortIn, ordersLogTable)
+	rows, err := sourceDB.QueryContext(ctx, query, depth, j.cfg.BatchSize)
+	observe()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer rows.Close()
 
+	var res []sourceOrderVersion
 	for rows.Next() {
-		var o sourceOrder
-		var versionDate time.Time
-		var toDate sql.NullTime
-		if err := rows.Scan(&o.OrderID, &o.State, &o.CreationDate, &o.DueDate, &versionDate, &o.CDBProcessID, &o.OrderType, &o.OrderData, &toDate); err != nil {
-			return err
-		}
-		if o.OrderType != "portin" {
-			continue
-		}
-		payload, err := transform.ParseOrderPayload(o.OrderData)
+		v, err := scanOrderVersion(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		subscriberType := transform.SubscriberType(payload)
-		if subscriberType != "Person" {
+		metrics.RowsExtracted.WithLabelValues(j.Name(), ordersLogTable).Inc()
+		if v.OrderType != "portin" {
+			metrics.RowsSkipped.WithLabelValues(j.Name(), ordersLogTable, metrics.SkipOrderType).Inc()
 			continue
 		}
-		statusID := mapStatus(o.State)
-		if cancelMap[o.OrderID] {
-			statusID = 11
-		}
-		request := target.Request{
-			OrderNumber:     fmt.Sprintf("%s%d", j.cfg.Prefix, o.OrderID),
-			RequestStatusID: statusID,
-			RequestDate:     nullTime(o.CreationDate),
-			ContractDate:    transform.ParseContractDate(payload.Contract.DocumentDate),
-			PortDate:        nullTime(o.DueDate),
-			FromDate:        versionDate,
-			ToDate:          nullTime(toDate),
-			CDBID:           o.CDBProcessID.String,
-			ProcessType:     payload.ProcessType,
-			PortType:        o.OrderType,
-			SubscriberType:  subscriberType,
-			MessageCode:     payload.Status.Code,
-			RejectReason:    transform.ParseRejectReason(o.State, payload.Status.Message),
-			OrderID:         o.OrderID,
-		}
-		if err := j.store.InsertRequestHistory(ctx, tx, request); err != nil {
-			return err
+		res = append(res, v)
+	}
+
+	return res, rows.Err()
+}
+
+// orderBatch - пачка записи в витрину. loadedOrders и loadedVersions - разобранные без ошибок строки,
+// в том числе пропущенные маппингом: их записи в карантине закрываются вместе с пачкой.
+type orderBatch struct {
+	requests       []target.Request
+	numbers        []target.RequestNumber
+	versions       []target.Request
+	loadedOrders   []target.QuarantineKey
+	loadedVersions []target.QuarantineKey
+}
+
+func (j *Job) addOrder(b *orderBatch, o sourceOrder, payload transform.OrderPayload, cancelled bool) {
+	b.loadedOrders = append(b.loadedOrders, target.QuarantineKey{SourceKey: o.OrderID, VersionDate: o.ChangingDate})
+	request, ok := j.buildRequest(o, payload, cancelled)
+	if !ok {
+		metrics.RowsSkipped.WithLabelValues(j.Name(), ordersTable, metrics.SkipSubscriberType).Inc()
+		return
+	}
+	request.FromDate = o.ChangingDate
+	b.requests = append(b.requests, request)
+	b.numbers = append(b.numbers, requestNumbers(request, payload)...)
+}
+
+func (j *Job) addVersion(b *orderBatch, v sourceOrderVersion, payload transform.OrderPayload, cancelled bool) {
+	b.loadedVersions = append(b.loadedVersions, target.QuarantineKey{SourceKey: v.OrderID, VersionDate: v.VersionDate})
+	request, ok := j.buildRequest(v.sourceOrder, payload, cancelled)
+	if !ok {
+		metrics.RowsSkipped.WithLabelValues(j.Name(), ordersLogTable, metrics.SkipSubscriberType).Inc()
+		return
+	}
+	request.FromDate = v.VersionDate
+	request.ToDate = nullTime(v.ToDate)
+	b.versions = append(b.versions, request)
+}
+
+// writeBatch пишет пачку в витрину. Большие пачки идут через COPY и staging-таблицы (target.BulkMinRows).
+// mnp_request пишется до req_number из-за внешнего ключа.
+func (j *Job) writeBatch(ctx context.Context, tx *sql.Tx, b orderBatch) error {
+	if err := j.store.UpsertRequests(ctx, tx, b.requests); err != nil {
+		return err
+	}
+	if err := j.store.UpsertOpenRequestVersions(ctx, tx, b.requests); err != nil {
+		return err
+	}
+	if err := j.store.UpsertReqNumbers(ctx, tx, b.numbers); err != nil {
+		return err
+	}
+	metrics.RowsUpserted.WithLabelValues(j.Name(), ordersTable).Add(float64(len(b.requests)))
+
+	if err := j.store.InsertRequestHistories(ctx, tx, b.versions); err != nil {
+		return err
+	}
+	metrics.RowsUpserted.WithLabelValues(j.Name(), ordersLogTable).Add(float64(len(b.versions)))
+
+	// orders хранит только последнюю версию заявки, поэтому она закрывает и более ранние записи карантина.
+	if err := j.store.ResolveLoadedQuarantine(ctx, tx, j.LockKey(), ordersTable, b.loadedOrders, true); err != nil {
+		return err
+	}
+
+	return j.store.ResolveLoadedQuarantine(ctx, tx, j.LockKey(), ordersLogTable, b.loadedVersions, false)
+}
+
+func requestNumbers(request target.Request, payload transform.OrderPayload) []target.RequestNumber {
+	res := make([]target.RequestNumber, 0, len(payload.PortationNumbers))
+	for _, n := range payload.PortationNumbers {
+		if n.Msisdn == "" {
+			continue
 		}
+		res = append(res, target.RequestNumber{
+			ReqID:       request.OrderNumber,
+			RecipientID: payload.Recipient.CDBCode(),
+			MSISDN:      n.Msisdn,
+			RN:          n.RN,
+		})
 	}
 
-	return rows.Err()
+	return res
 }
 
-func (j *Job) loadCancelStatuses(ctx context.Context, depth *time.Time) (map[int64]bool, error) {
-	table := j.cfg.CancelTable
-	if table == "" {
-		table = "orders"
+func (j *Job) buildRequest(o sourceOrder, payload transform.OrderPayload, cancelled bool) (target.Request, bool) {
+	subscriberType := transform.SubscriberType(payload)
+	if subscriberType != "Person" {
+		return target.Request{}, false
 	}
-	if !regexp.MustCompile(`^[a-zA-Z0-9_\.\"]+$`).MatchString(table) {
-		return nil, fmt.Errorf("unsafe cancel table name: %s", table)
+	statusID := mapStatus(o.State)
+	if cancelled {
+		statusID = 11
 	}
+	status := payload.OrderStatus()
+
+	return target.Request{
+		OrderNumber:     j.cfg.Prefix + o.OrderID,
+		RequestStatusID: statusID,
+		RequestDate:     nullTime(o.CreationDate),
+		ContractDate:    transform.ParseContractDate(payload.Contract.Date(), j.cfg.Location),
+		PortDate:        nullTime(o.DueDate),
+		CDBID:           o.CDBProcessID.String,
+		ProcessType:     payload.ProcessTypeName(),
+		PortType:        o.OrderType,
+		SubscriberType:  subscriberType,
+		MessageCode:     status.Code,
+		RejectReason:    transform.ParseRejectReason(o.State, status.MessageText()),
+		OrderID:         o.OrderID,
+		Source:          j.cfg.Source,
+	}, true
+}
 
-	query := fmt.Sprintf(`SELECT order_id, status FROM %s WHERE ($1::timestamp is null or changing_date > $1)`, table)
-	rows, err := j.cancelDB.QueryContext(ctx, query, depth)
+func (j *Job) quarantine(ctx context.Context, tx *sql.Tx, table string, orderID string, version time.Time, data []byte, cause error) error {
+	j.logger.Warn("order payload quarantined",
+		zap.String("source_table", table),
+		zap.String("order_id", orderID),
+		zap.Time("version_date", version),
+		zap.Error(cause))
+
+	err := j.store.Quarantine(ctx, tx, target.QuarantineRecord{
+		Job:         j.LockKey(),
+		SourceTable: table,
+		SourceKey:   orderID,
+		VersionDate: version,
+		Error:       cause.Error(),
+		Payload:     string(data),
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	metrics.RowsQuarantined.WithLabelValues(j.Name(), table).Inc()
 
-	res := make(map[int64]bool)
-	for rows.Next() {
-		var orderID int64
-		var status int
-		if err := rows.Scan(&orderID, &status); err != nil {
-			return nil, err
+	return nil
+}
+
+// RetryQuarantined перечитывает строку из источника и повторно загружает её.
+// При успехе запись удаляется из карантина, иначе увеличивается счетчик попыток.
+func (j *Job) RetryQuarantined(ctx context.Context, id int64) error {
+	ctx, span := tracer.Start(ctx, "RetryQuarantined")
+	defer span.End()
+
+	rec, err := j.store.GetQuarantine(ctx, id)
+	if err != nil {
+		return err
+	}
+	if rec.Job != j.LockKey() {
+		return ErrNotPortInRecord
+	}
+	orderID, err := j.parseOrderID(rec.SourceKey)
+	if err != nil {
+		return fmt.Errorf("invalid quarantine source key: %w", err)
+	}
+
+	unlock, locked, err := j.store.TryLockJob(ctx, j.LockKey())
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrJobRunning
+	}
+	defer unlock()
+
+	cancelled, err := j.loadCancelStatus(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.retrySource(ctx, tx, rec, orderID, cancelled); err != nil {
+		if touchErr := j.store.TouchQuarantine(ctx, id, err.Error()); touchErr != nil {
+			return errors.Join(err, touchErr)
 		}
-		if status == 50 {
-			res[orderID] = true
+
+		return err
+	}
+	// Запись уже могла закрыться в writeBatch, если загружена та же или более поздняя версия.
+	if err := j.store.ResolveQuarantine(ctx, tx, id); err != nil && !errors.Is(err, target.ErrQuarantineNotFound) {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (j *Job) retrySource(ctx context.Context, tx *sql.Tx, rec target.QuarantineRecord, orderID string, cancelled bool) error {
+	switch rec.SourceTable {
+	case ordersTable:
+		o, err := scanOrder(j.sourceDB.QueryRowContext(ctx, ordersQuery+`WHERE order_id = $1`, orderID))
+		if err != nil {
+			return err
 		}
+		payload, err := transform.ParseOrderPayload(o.OrderData)
+		if err != nil {
+			return err
+		}
+
+		var b orderBatch
+		j.addOrder(&b, o, payload, cancelled)
+
+		return j.writeBatch(ctx, tx, b)
+	case ordersLogTable:
+		v, err := scanOrderVersion(j.sourceDB.QueryRowContext(ctx,
+			ordersLogQuery+`WHERE l.order_id = $1 AND l.version_date = $2`, orderID, rec.VersionDate))
+		if err != nil {
+			return err
+		}
+		payload, err := transform.ParseOrderPayload(v.OrderData)
+		if err != nil {
+			return err
+		}
+
+		var b orderBatch
+		j.addVersion(&b, v, payload, cancelled)
+
+		return j.writeBatch(ctx, tx, b)
+	default:
+		return fmt.Errorf("unsupported quarantine source table: %s", rec.SourceTable)
 	}
+}
 
-	return res, rows.Err()
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (sourceOrder, error) {
+	var o sourceOrder
+	err := row.Scan(&o.OrderID, &o.State, &o.CreationDate, &o.DueDate, &o.ChangingDate, &o.CDBProcessID, &o.OrderType, &o.OrderData)
+
+	return o, err
+}
+
+func scanOrderVersion(row rowScanner) (sourceOrderVersion, error) {
+	var v sourceOrderVersion
+	err := row.Scan(&v.OrderID, &v.State, &v.CreationDate, &v.DueDate, &v.VersionDate, &v.CDBProcessID, &v.OrderType, &v.OrderData, &v.ToDate)
+
+	return v, err
 }
 
 func nullTime(ts sql.NullTime) *time.Time {
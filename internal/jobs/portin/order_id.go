@@ -0,0 +1,99 @@
+package portin
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// OrderIDKind - тип order_id в БД источника. От него зависят приведение ключей в запросах к источнику
+// и проверка ключей, пришедших извне (карантин, backfill, CDC, события).
+type OrderIDKind string
+
+const (
+	// OrderIDInt - BIGINT.
+	OrderIDInt OrderIDKind = "int"
+	// OrderIDGUID - uuid.
+	OrderIDGUID OrderIDKind = "guid"
+)
+
+var guidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+func ParseOrderIDKind(s string) (OrderIDKind, error) {
+	switch k := OrderIDKind(s); k {
+	case OrderIDInt, OrderIDGUID:
+		return k, nil
+	default:
+		return "", fmt.Errorf("invalid order id kind %q", s)
+	}
+}
+
+// sqlType - тип, к которому приводятся массивы ключей в запросах к источнику.
+func (k OrderIDKind) sqlType() string {
+	if k == OrderIDGUID {
+		return "uuid"
+	}
+
+	return "bigint"
+}
+
+// parse проверяет ключ и приводит его к виду, в котором Postgres возвращает order_id источника:
+// десятичное число без ведущих нулей или GUID в нижнем регистре.
+func (k OrderIDKind) parse(id string) (string, error) {
+	if k == OrderIDGUID {
+		guid := strings.ToLower(id)
+		if !guidPattern.MatchString(guid) {
+			return "", fmt.Errorf("invalid guid order id %q", id)
+		}
+
+		return guid, nil
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid order id %q: %w", id, err)
+	}
+
+	return strconv.FormatInt(n, 10), nil
+}
+
+// compareIntOrderIDs сравнивает неотрицательные целые order_id в виде строк как числа.
+func compareIntOrderIDs(a, b string) int {
+	if c := cmp.Compare(len(a), len(b)); c != 0 {
+		return c
+	}
+
+	return strings.Compare(a, b)
+}
+
+// parseOrderID приводит внешний ключ к order_id источника. Ключ может быть как order_id, так и order_number
+// (Prefix + order_id, его передают события portin-service): префикс отрезается, если остаток - допустимый order_id.
+func (j *Job) parseOrderID(key string) (string, error) {
+	key = strings.TrimSpace(key)
+	if rest, ok := strings.CutPrefix(key, j.cfg.Prefix); ok && j.cfg.Prefix != "" {
+		if id, err := j.cfg.OrderIDKind.parse(rest); err == nil {
+			return id, nil
+		}
+	}
+
+	return j.cfg.OrderIDKind.parse(key)
+}
+
+// parseOrderIDs оставляет ключи, допустимые для OrderIDKind источника, остальные пропускаются с предупреждением.
+func (j *Job) parseOrderIDs(keys []string, origin string) []string {
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		id, err := j.parseOrderID(key)
+		if err != nil {
+			j.logger.Warn("invalid order id", zap.String("origin", origin), zap.String("source_key", key), zap.Error(err))
+			continue
+		}
+		res = append(res, id)
+	}
+
+	return res
+}
@@ -0,0 +1,45 @@
+package portin_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+)
+
+func TestParseOrderID(t *testing.T) {
+	const guid = "0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d"
+
+	for _, tc := range []struct {
+		name   string
+		kind   portin.OrderIDKind
+		prefix string
+		key    string
+		want   string
+	}{
+		{name: "int", kind: portin.OrderIDInt, prefix: "pin", key: "123", want: "123"},
+		{name: "int with prefix", kind: portin.OrderIDInt, prefix: "pin", key: "pin123", want: "123"},
+		{name: "int leading zeros", kind: portin.OrderIDInt, prefix: "pin", key: " pin0042 ", want: "42"},
+		{name: "guid", kind: portin.OrderIDGUID, prefix: "pin", key: guid, want: guid},
+		{name: "guid with prefix", kind: portin.OrderIDGUID, prefix: "pin", key: "pin" + guid, want: guid},
+		{name: "guid upper case", kind: portin.OrderIDGUID, prefix: "pin", key: "pin0A1B2C3D-4E5F-6A7B-8C9D-0E1F2A3B4C5D", want: guid},
+		{name: "guid starting with hex prefix", kind: portin.OrderIDGUID, prefix: "0a", key: guid, want: guid},
+		{name: "guid with hex prefix", kind: portin.OrderIDGUID, prefix: "0a", key: "0a" + guid, want: guid},
+		{name: "other prefix", kind: portin.OrderIDInt, prefix: "pin", key: "p03123", want: ""},
+		{name: "int for guid source", kind: portin.OrderIDGUID, prefix: "pin", key: "pin123", want: ""},
+		{name: "guid for int source", kind: portin.OrderIDInt, prefix: "pin", key: "pin" + guid, want: ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			job := portin.NewJob(portin.Config{Prefix: tc.prefix, OrderIDKind: tc.kind}, nil, nil, nil, nil, zap.NewNop())
+			id, err := job.ParseOrderID(tc.key)
+			if tc.want == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, id)
+		})
+	}
+}
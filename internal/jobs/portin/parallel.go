@@ -0,0 +1,269 @@
+package portin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+// parsedOrder - строка orders с разобранным order_data. err - ошибка разбора, такая строка уходит в карантин.
+type parsedOrder struct {
+	sourceOrder
+	payload transform.OrderPayload
+	err     error
+}
+
+func parseOrders(orders []sourceOrder) []parsedOrder {
+	res := make([]parsedOrder, 0, len(orders))
+	for _, o := range orders {
+		payload, err := transform.ParseOrderPayload(o.OrderData)
+		res = append(res, parsedOrder{sourceOrder: o, payload: payload, err: err})
+	}
+
+	return res
+}
+
+// parallel сообщает, читается и пишется ли пачка orders запуска воркерами.
+func (j *Job) parallel(src sources) bool {
+	return j.cfg.Workers > 1 && src.snapshot != "" && j.cfg.OrderIDKind == OrderIDInt
+}
+
+// extractParsedOrders читает и разбирает пачку orders. При Workers > 1 чтение делится по диапазонам order_id,
+// поэтому параллельно читаются только целые order_id.
+func (j *Job) extractParsedOrders(ctx context.Context, src sources, depth *time.Time) ([]parsedOrder, error) {
+	if !j.parallel(src) {
+		orders, err := j.extractOrders(ctx, src.orders, depth)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseOrders(orders), nil
+	}
+
+	return j.extractOrdersParallel(ctx, src, depth)
+}
+
+// beginStageRun регистрирует staging запуска для stageOrders. 0 - пачка пишется последовательно.
+func (j *Job) beginStageRun(ctx context.Context, src sources) (int64, error) {
+	if !j.parallel(src) {
+		return 0, nil
+	}
+
+	return j.store.BeginStageRun(ctx, j.LockKey())
+}
+
+// dropStageRun удаляет staging незафиксированного запуска. Ошибка не влияет на результат:
+// остатки удалит следующий запуск джобы.
+func (j *Job) dropStageRun(ctx context.Context, runID int64) {
+	if runID == 0 {
+		return
+	}
+	if err := j.store.DropStageRun(context.WithoutCancel(ctx), runID); err != nil {
+		j.logger.Warn("stage run cleanup failed", zap.Int64("run_id", runID), zap.Error(err))
+	}
+}
+
+// stageOrders делит пачку между воркерами: каждый преобразует свой срез и пишет его в staging запуска runID
+// в собственной транзакции. В витрину пачка попадает одним MergeStageRun в транзакции запуска,
+// поэтому пачка, карантин и watermark фиксируются вместе.
+func (j *Job) stageOrders(ctx context.Context, tx *sql.Tx, runID int64, orders []parsedOrder, cancelled map[string]bool) error {
+	parts := splitOrders(orders, j.cfg.Workers)
+	batches := make([]orderBatch, len(parts))
+	errs := make([]error, len(parts))
+
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Go(func() {
+			for _, o := range part {
+				j.addOrder(&batches[i], o.sourceOrder, o.payload, cancelled[o.OrderID])
+			}
+			errs[i] = j.store.StageRunRequests(ctx, runID, batches[i].requests, batches[i].numbers)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	if err := j.store.MergeStageRun(ctx, tx, runID); err != nil {
+		return err
+	}
+
+	var (
+		upserted int
+		loaded   []target.QuarantineKey
+	)
+	for _, b := range batches {
+		upserted += len(b.requests)
+		loaded = append(loaded, b.loadedOrders...)
+	}
+	metrics.RowsUpserted.WithLabelValues(j.Name(), ordersTable).Add(float64(upserted))
+
+	// orders хранит только последнюю версию заявки, поэтому она закрывает и более ранние записи карантина.
+	return j.store.ResolveLoadedQuarantine(ctx, tx, j.LockKey(), ordersTable, loaded, true)
+}
+
+// splitOrders делит orders на не более чем n идущих подряд срезов.
+func splitOrders(orders []parsedOrder, n int) [][]parsedOrder {
+	if len(orders) == 0 {
+		return nil
+	}
+	size := (len(orders) + n - 1) / n
+	res := make([][]parsedOrder, 0, n)
+	for from := 0; from < len(orders); from += size {
+		res = append(res, orders[from:min(from+size, len(orders))])
+	}
+
+	return res
+}
+
+// orderKey - позиция строки в порядке (changing_date, order_id) последовательного чтения.
+type orderKey struct {
+	changingDate time.Time
+	orderID      string
+}
+
+type orderIDRange struct {
+	from, to int64
+}
+
+// extractOrdersParallel читает те же строки, что и extractOrders: граница пачки считается заранее
+// по ключу последовательного чтения, поэтому watermark следующего запуска не теряет строк.
+// Воркеры читают свои диапазоны order_id в снимке, экспортированном из транзакции запуска.
+func (j *Job) extractOrdersParallel(ctx context.Context, src sources, depth *time.Time) ([]parsedOrder, error) {
+	cutoff, err := j.orderBatchCutoff(ctx, src.orders, depth)
+	if err != nil {
+		return nil, err
+	}
+	bounds, ok, err := j.orderIDBounds(ctx, src.orders, depth, cutoff)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	ranges := splitOrderIDRange(bounds, j.cfg.Workers)
+	results := make([][]parsedOrder, len(ranges))
+	errs := make([]error, len(ranges))
+
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		wg.Go(func() {
+			results[i], errs[i] = j.extractOrderRange(ctx, src.snapshot, depth, cutoff, r)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	res := slices.Concat(results...)
+	slices.SortFunc(res, func(a, b parsedOrder) int {
+		if c := a.ChangingDate.Compare(b.ChangingDate); c != 0 {
+			return c
+		}
+
+		return compareIntOrderIDs(a.OrderID, b.OrderID)
+	})
+
+	return res, nil
+}
+
+// orderBatchCutoff возвращает ключ последней строки пачки или nil, если после depth строк не больше BatchSize.
+func (j *Job) orderBatchCutoff(ctx context.Context, sourceDB queryer, depth *time.Time) (*orderKey, error) {
+	defer metrics.ObserveQuery(metrics.DBPortIn, "orders_cutoff")()
+
+	rows, err := sourceDB.QueryContext(ctx, `SELECT changing_date, order_id FROM orders
+WHERE ($1::timestamptz is null or changing_date > $1)
+ORDER BY changing_date, order_id
+OFFSET $2 LIMIT 1`, depth, j.cfg.BatchSize-1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var k orderKey
+	if err := rows.Scan(&k.changingDate, &k.orderID); err != nil {
+		return nil, err
+	}
+
+	return &k, rows.Err()
+}
+
+func (j *Job) orderIDBounds(ctx context.Context, sourceDB queryer, depth *time.Time, cutoff *orderKey) (orderIDRange, bool, error) {
+	defer metrics.ObserveQuery(metrics.DBPortIn, "orders_bounds")()
+
+	cutoffDate, cutoffID := cutoffArgs(cutoff)
+	var lo, hi *int64
+	err := sourceDB.QueryRowContext(ctx, `SELECT min(order_id), max(order_id) FROM orders
+WHERE ($1::timestamptz is null or changing_date > $1)
+  AND ($2::timestamptz is null or (changing_date, order_id) <= ($2, $3::bigint))`, depth, cutoffDate, cutoffID).Scan(&lo, &hi)
+	if err != nil || lo == nil || hi == nil {
+		return orderIDRange{}, false, err
+	}
+
+	return orderIDRange{from: *lo, to: *hi}, true, nil
+}
+
+// extractOrderRange читает и разбирает диапазон order_id пачки в отдельной транзакции на снимке snapshot.
+func (j *Job) extractOrderRange(ctx context.Context, snapshot string, depth *time.Time, cutoff *orderKey, r orderIDRange) ([]parsedOrder, error) {
+	tx, err := j.sourceDB.BeginTx(ctx, snapshotTxOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if _, err := tx.ExecContext(ctx, `SET TRANSACTION SNAPSHOT `+pq.QuoteLiteral(snapshot)); err != nil {
+		return nil, err
+	}
+
+	cutoffDate, cutoffID := cutoffArgs(cutoff)
+	query := ordersQuery + `WHERE ($1::timestamptz is null or changing_date > $1)
+  AND ($2::timestamptz is null or (changing_date, order_id) <= ($2, $3::bigint))
+  AND order_id >= $4 AND order_id <= $5`
+	observe := metrics.ObserveQuery(metrics.DBPortIn, ordersTable+"_range")
+	rows, err := tx.QueryContext(ctx, query, depth, cutoffDate, cutoffID, r.from, r.to)
+	observe()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := j.collectOrders(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseOrders(orders), nil
+}
+
+func cutoffArgs(cutoff *orderKey) (*time.Time, *string) {
+	if cutoff == nil {
+		return nil, nil
+	}
+
+	return &cutoff.changingDate, &cutoff.orderID
+}
+
+// splitOrderIDRange делит [r.from, r.to] на не более чем n непересекающихся диапазонов.
+func splitOrderIDRange(r orderIDRange, n int) []orderIDRange {
+	size := (r.to-r.from)/int64(n) + 1
+	res := make([]orderIDRange, 0, n)
+	for from := r.from; from <= r.to; from += size {
+		to := min(from+size-1, r.to)
+		res = append(res, orderIDRange{from: from, to: to})
+		if to == r.to {
+			break
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,140 @@
+package portin_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+)
+
+func TestSplitOrderIDRange(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		from, to int64
+		n        int
+		want     [][2]int64
+	}{
+		{name: "one worker", from: 1, to: 10, n: 1, want: [][2]int64{{1, 10}}},
+		{name: "single id", from: 7, to: 7, n: 4, want: [][2]int64{{7, 7}}},
+		{name: "n larger than span", from: 5, to: 7, n: 10, want: [][2]int64{{5, 5}, {6, 6}, {7, 7}}},
+		{name: "even", from: 0, to: 99, n: 4, want: [][2]int64{{0, 24}, {25, 49}, {50, 74}, {75, 99}}},
+		{name: "uneven", from: 1, to: 10, n: 3, want: [][2]int64{{1, 4}, {5, 8}, {9, 10}}},
+		{name: "short tail", from: 1, to: 7, n: 3, want: [][2]int64{{1, 3}, {4, 6}, {7, 7}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := portin.SplitOrderIDRange(tc.from, tc.to, tc.n)
+			require.Equal(t, tc.want, got)
+			require.LessOrEqual(t, len(got), tc.n)
+		})
+	}
+}
+
+func TestSplitOrders(t *testing.T) {
+	for _, tc := range []struct {
+		n, workers int
+		want       []int
+	}{
+		{n: 0, workers: 4},
+		{n: 10, workers: 1, want: []int{10}},
+		{n: 10, workers: 3, want: []int{4, 4, 2}},
+		{n: 8, workers: 4, want: []int{2, 2, 2, 2}},
+		{n: 2, workers: 4, want: []int{1, 1}},
+	} {
+		require.Equal(t, tc.want, portin.SplitOrders(tc.n, tc.workers), "%d orders, %d workers", tc.n, tc.workers)
+	}
+}
+
+func TestCompareIntOrderIDs(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{a: "10", b: "10", want: 0},
+		{a: "9", b: "10", want: -1},
+		{a: "123", b: "99", want: 1},
+		{a: "100", b: "101", want: -1},
+		{a: "0", b: "0", want: 0},
+	} {
+		require.Equal(t, tc.want, portin.CompareIntOrderIDs(tc.a, tc.b), "%s vs %s", tc.a, tc.b)
+	}
+}
+
+// TestExtractOrdersParallel проверяет, что параллельное чтение возвращает те же строки и в том же порядке,
+// что и последовательное. Запускается только с PORTIN_TEST_PG_DSN, например:
+// PORTIN_TEST_PG_DSN="host=localhost user=postgres password=postgres dbname=postgres sslmode=disable".
+func TestExtractOrdersParallel(t *testing.T) {
+	dsn := os.Getenv("PORTIN_TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("PORTIN_TEST_PG_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() }) //nolint:errcheck
+
+	schema := fmt.Sprintf("portin_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) }) //nolint:errcheck
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() }) //nolint:errcheck
+
+	_, err = db.Exec(`CREATE TABLE orders (
+	order_id bigint PRIMARY KEY,
+	state int NOT NULL,
+	creation_date timestamptz,
+	due_date timestamptz,
+	changing_date timestamptz NOT NULL,
+	cdb_process_id varchar,
+	order_type varchar NOT NULL,
+	order_data jsonb
+)`)
+	require.NoError(t, err)
+
+	// Строки с одинаковым changing_date попадают в разные диапазоны order_id, граница пачки проходит внутри группы.
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 40 {
+		orderID := int64(i*37%101 + 1)
+		if i%2 == 1 {
+			orderID += 1000
+		}
+		orderType := "portin"
+		if i%9 == 0 {
+			orderType = "portout"
+		}
+		_, err := db.Exec(`INSERT INTO orders (order_id, state, changing_date, order_type, order_data) VALUES ($1, 1, $2, $3, '{}')`,
+			orderID, base.Add(time.Duration(i/4)*time.Minute), orderType)
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	depth := base.Add(2 * time.Minute)
+	for _, tc := range []struct {
+		name      string
+		batchSize int
+		depth     *time.Time
+	}{
+		{name: "cutoff inside tie", batchSize: 7},
+		{name: "cutoff after depth", batchSize: 10, depth: &depth},
+		{name: "no cutoff", batchSize: 100},
+		{name: "no cutoff after depth", batchSize: 100, depth: &depth},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			job := portin.NewJob(portin.Config{BatchSize: tc.batchSize, Workers: 3}, db, db, nil, nil, zap.NewNop())
+			sequential, parallel, err := job.ExtractOrderKeys(ctx, tc.depth)
+			require.NoError(t, err)
+			require.NotEmpty(t, sequential)
+			require.Equal(t, sequential, parallel)
+		})
+	}
+}
@@ -0,0 +1,365 @@
+package portin
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/reconcile"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+const (
+	checkRequests = "mnp_request"
+	checkHistory  = "mnp_request_h"
+	checkNumbers  = "req_number"
+)
+
+type expectedOrder struct {
+	request target.Request
+	numbers []target.RequestNumber
+}
+
+// Reconcile сверяет заявки, версии и номера, измененные в источнике за [from, to), с витриной.
+// Строки с некорректным order_data не участвуют в сверке: они учитываются в etl_quarantine.
+func (j *Job) Reconcile(ctx context.Context, from, to time.Time, sample int) ([]target.ReconciliationResult, error) {
+	ctx, span := tracer.Start(ctx, "Reconcile")
+	defer span.End()
+
+	expected, err := j.expectedOrders(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	expectedVersions, err := j.expectedVersions(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	// Текущая версия заявки хранится в mnp_request_h открытой (to_date is null).
+	for _, exp := range expected {
+		expectedVersions[versionKey(exp.request.OrderID, exp.request.FromDate)] = exp.request
+	}
+	actual, err := j.store.RequestsBetween(ctx, j.cfg.Source, from, to)
+	if err != nil {
+		return nil, err
+	}
+	actualVersions, err := j.store.RequestVersionsBetween(ctx, j.cfg.Source, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	actualByNumber := make(map[string]target.Request, len(actual))
+	for _, r := range actual {
+		actualByNumber[r.OrderNumber] = r
+	}
+	matched := make([]string, 0, len(expected))
+	requests := reconcile.NewTally(j.LockKey(), checkRequests, j.cfg.Location, sample)
+	compareRequests(requests, expected, actualByNumber, func(num string) { matched = append(matched, num) })
+
+	history := reconcile.NewTally(j.LockKey(), checkHistory, j.cfg.Location, sample)
+	compareVersions(history, expectedVersions, actualVersions)
+
+	numbers, err := j.store.RequestNumbers(ctx, matched)
+	if err != nil {
+		return nil, err
+	}
+	reqNumbers := reconcile.NewTally(j.LockKey(), checkNumbers, j.cfg.Location, sample)
+	for _, num := range matched {
+		compareNumbers(reqNumbers, expected[num], numbers[num])
+	}
+
+	res := requests.Results()
+	res = append(res, history.Results()...)
+
+	return append(res, reqNumbers.Results()...), nil
+}
+
+func compareRequests(t *reconcile.Tally, expected map[string]expectedOrder, actual map[string]target.Request, onMatch func(string)) {
+	for num, exp := range expected {
+		r := exp.request
+		status := strconv.Itoa(r.RequestStatusID)
+		key := r.OrderID
+		t.Source(r.FromDate, status)
+
+		act, ok := actual[num]
+		if !ok || !act.FromDate.Equal(r.FromDate) || act.RequestStatusID != r.RequestStatusID {
+			t.Missing(r.FromDate, status, key)
+			continue
+		}
+		onMatch(num)
+		if t.Sample() {
+			t.Checksum(r.FromDate, status, key, requestChecksum(r), requestChecksum(act))
+		}
+	}
+	for num, act := range actual {
+		status := strconv.Itoa(act.RequestStatusID)
+		t.Target(act.FromDate, status)
+		if _, ok := expected[num]; !ok {
+			t.Extra(act.FromDate, status, act.OrderID)
+		}
+	}
+}
+
+func compareVersions(t *reconcile.Tally, expected map[string]target.Request, actual []target.Request) {
+	actualByKey := make(map[string]target.Request, len(actual))
+	for _, act := range actual {
+		key := versionKey(act.OrderID, act.FromDate)
+		actualByKey[key] = act
+
+		status := strconv.Itoa(act.RequestStatusID)
+		t.Target(act.FromDate, status)
+		if _, ok := expected[key]; !ok {
+			t.Extra(act.FromDate, status, act.OrderID)
+		}
+	}
+	for key, r := range expected {
+		status := strconv.Itoa(r.RequestStatusID)
+		orderKey := r.OrderID
+		t.Source(r.FromDate, status)
+
+		act, ok := actualByKey[key]
+		if !ok || act.RequestStatusID != r.RequestStatusID {
+			t.Missing(r.FromDate, status, orderKey)
+			continue
+		}
+		if t.Sample() {
+			t.Checksum(r.FromDate, status, orderKey, requestChecksum(r), requestChecksum(act))
+		}
+	}
+}
+
+func compareNumbers(t *reconcile.Tally, expected expectedOrder, actual []target.RequestNumber) {
+	at := expected.request.FromDate
+	key := expected.request.OrderID
+
+	actualByMSISDN := make(map[string]target.RequestNumber, len(actual))
+	for _, n := range actual {
+		actualByMSISDN[n.MSISDN] = n
+		t.Target(at, "")
+	}
+	expectedMSISDN := make(map[string]struct{}, len(expected.numbers))
+	for _, n := range expected.numbers {
+		expectedMSISDN[n.MSISDN] = struct{}{}
+		t.Source(at, "")
+		act, ok := actualByMSISDN[n.MSISDN]
+		if !ok || act != n {
+			t.Missing(at, "", key)
+		}
+	}
+	for _, n := range actual {
+		if _, ok := expectedMSISDN[n.MSISDN]; !ok {
+			t.Extra(at, "", key)
+		}
+	}
+}
+
+func (j *Job) expectedOrders(ctx context.Context, from, to time.Time) (map[string]expectedOrder, error) {
+	observe := metrics.ObserveQuery(metrics.DBPortIn, "reconcile_"+ordersTable)
+	rows, err := j.sourceDB.QueryContext(ctx, ordersQuery+`WHERE changing_date >= $1 AND changing_date < $2 AND order_type = 'portin'`, from, to)
+	observe()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []sourceOrder
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cancelled, err := j.cancelledOrders(ctx, j.cancelDB, orderIDs(orders))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]expectedOrder, len(orders))
+	for _, o := range orders {
+		payload, err := transform.ParseOrderPayload(o.OrderData)
+		if err != nil {
+			continue
+		}
+		request, ok := j.buildRequest(o, payload, cancelled[o.OrderID])
+		if !ok {
+			continue
+		}
+		request.FromDate = o.ChangingDate
+		res[request.OrderNumber] = expectedOrder{request: request, numbers: requestNumbers(request, payload)}
+	}
+
+	return res, nil
+}
+
+func (j *Job) expectedVersions(ctx context.Context, from, to time.Time) (map[string]target.Request, error) {
+	observe := metrics.ObserveQuery(metrics.DBPortIn, "reconcile_"+ordersLogTable)
+	rows, err := j.sourceDB.QueryContext(ctx, ordersLogQuery+`WHERE l.version_date >= $1 AND l.version_date < $2 AND l.order_type = 'portin'`, from, to)
+	observe()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []sourceOrderVersion
+	for rows.Next() {
+		v, err := scanOrderVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	cancelled, err := j.cancelledOrders(ctx, j.cancelDB, versionOrderIDs(versions))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]target.Request, len(versions))
+	for _, v := range versions {
+		payload, err := transform.ParseOrderPayload(v.OrderData)
+		if err != nil {
+			continue
+		}
+		request, ok := j.buildRequest(v.sourceOrder, payload, cancelled[v.OrderID])
+		if !ok {
+			continue
+		}
+		request.FromDate = v.VersionDate
+		request.ToDate = nullTime(v.ToDate)
+		res[versionKey(v.OrderID, v.VersionDate)] = request
+	}
+
+	return res, nil
+}
+
+// processBackfill повторно загружает заявки из etl_backfill вместе с их историей.
+func (j *Job) processBackfill(ctx context.Context, src sources, tx *sql.Tx) error {
+	keys, err := j.store.PendingBackfill(ctx, j.LockKey(), j.cfg.BatchSize)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	ids := j.parseOrderIDs(keys, "backfill")
+	cancelled, err := j.cancelledOrders(ctx, src.cancel, ids)
+	if err != nil {
+		return err
+	}
+
+	if err := j.backfillOrders(ctx, src.orders, tx, ids, cancelled); err != nil {
+		return err
+	}
+	if err := j.backfillVersions(ctx, src.orders, tx, ids, cancelled); err != nil {
+		return err
+	}
+	j.logger.Info("backfill processed", zap.Int("orders", len(ids)))
+
+	return j.store.CompleteBackfill(ctx, tx, j.LockKey(), keys)
+}
+
+func (j *Job) backfillOrders(ctx context.Context, sourceDB queryer, tx *sql.Tx, ids []string, cancelled map[string]bool) error {
+	observe := metrics.ObserveQuery(metrics.DBPortIn, "backfill_"+ordersTable)
+	rows, err := sourceDB.QueryContext(ctx, ordersQuery+`WHERE order_id = ANY($1::`+j.cfg.OrderIDKind.sqlType()+`[])`, pq.Array(ids))
+	observe()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var b orderBatch
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return err
+		}
+		if o.OrderType != "portin" {
+			continue
+		}
+		payload, err := transform.ParseOrderPayload(o.OrderData)
+		if err != nil {
+			if err := j.quarantine(ctx, tx, ordersTable, o.OrderID, o.ChangingDate, o.OrderData, err); err != nil {
+				return err
+			}
+			continue
+		}
+		j.addOrder(&b, o, payload, cancelled[o.OrderID])
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return j.writeBatch(ctx, tx, b)
+}
+
+func (j *Job) backfillVersions(ctx context.Context, sourceDB queryer, tx *sql.Tx, ids []string, cancelled map[string]bool) error {
+	observe := metrics.ObserveQuery(metrics.DBPortIn, "backfill_"+ordersLogTable)
+	rows, err := sourceDB.QueryContext(ctx, ordersLogQuery+`WHERE l.order_id = ANY($1::`+j.cfg.OrderIDKind.sqlType()+`[])`, pq.Array(ids))
+	observe()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var b orderBatch
+	for rows.Next() {
+		v, err := scanOrderVersion(rows)
+		if err != nil {
+			return err
+		}
+		if v.OrderType != "portin" {
+			continue
+		}
+		payload, err := transform.ParseOrderPayload(v.OrderData)
+		if err != nil {
+			if err := j.quarantine(ctx, tx, ordersLogTable, v.OrderID, v.VersionDate, v.OrderData, err); err != nil {
+				return err
+			}
+			continue
+		}
+		j.addVersion(&b, v, payload, cancelled[v.OrderID])
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return j.writeBatch(ctx, tx, b)
+}
+
+func requestChecksum(r target.Request) string {
+	return reconcile.Checksum(r.OrderNumber, r.RequestStatusID, r.RequestDate, r.ContractDate, r.PortDate, r.FromDate, r.ToDate,
+		r.CDBID, r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode, r.RejectReason, r.OrderID)
+}
+
+func versionKey(orderID string, versionDate time.Time) string {
+	return orderID + "@" + versionDate.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+func orderIDs(orders []sourceOrder) []string {
+	ids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		ids = append(ids, o.OrderID)
+	}
+
+	return ids
+}
+
+func versionOrderIDs(versions []sourceOrderVersion) []string {
+	ids := make([]string, 0, len(versions))
+	for _, v := range versions {
+		ids = append(ids, v.OrderID)
+	}
+
+	return ids
+}
@@ -0,0 +1,86 @@
+package portin
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// RefreshOrders перечитывает заявки из источника и перезагружает mnp_request, mnp_request_h и req_number
+// тем же путем, что и backfill. Watermark и etl_state не меняются: плановый запуск остается страховкой.
+// Ключи, не подходящие под OrderIDKind источника, пропускаются. Если джоба выполняется, возвращает ErrJobRunning.
+func (j *Job) RefreshOrders(ctx context.Context, keys []string) error {
+	ctx, span := tracer.Start(ctx, "RefreshOrders")
+	defer span.End()
+	span.SetAttributes(attribute.Int("orders", len(keys)))
+
+	ids := j.parseOrderIDs(keys, "refresh")
+	if len(ids) == 0 {
+		return nil
+	}
+
+	unlock, locked, err := j.store.TryLockJob(ctx, j.LockKey())
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return ErrJobRunning
+	}
+	defer unlock()
+
+	src, _, release, err := j.beginSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	cancelled, err := j.cancelledOrders(ctx, src.cancel, ids)
+	if err != nil {
+		return err
+	}
+	if err := j.backfillOrders(ctx, src.orders, tx, ids, cancelled); err != nil {
+		return err
+	}
+	if err := j.backfillVersions(ctx, src.orders, tx, ids, cancelled); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	j.logger.Debug("orders refreshed", zap.Int("orders", len(ids)))
+
+	return nil
+}
+
+// RouteOrders распределяет ключи заявок из событий portin-service (order_number = Prefix + order_id) по джобам
+// источников: ключ относится к источнику, с префикса которого начинается и остаток которого - допустимый order_id.
+// Префиксы источников не начинают друг друга (проверяется в config), поэтому такой источник один.
+// Ключи без источника возвращаются в unrouted.
+func RouteOrders(jobs []*Job, keys []string) (routed map[*Job][]string, unrouted []string) {
+	routed = make(map[*Job][]string)
+keys:
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		for _, j := range jobs {
+			rest, ok := strings.CutPrefix(key, j.cfg.Prefix)
+			if !ok {
+				continue
+			}
+			if id, err := j.cfg.OrderIDKind.parse(rest); err == nil {
+				routed[j] = append(routed[j], id)
+				continue keys
+			}
+		}
+		unrouted = append(unrouted, key)
+	}
+
+	return routed, unrouted
+}
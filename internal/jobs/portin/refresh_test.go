@@ -0,0 +1,29 @@
+package portin_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+)
+
+func TestRouteOrders(t *testing.T) {
+	const guid = "0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d"
+	newJob := func(source, prefix string, kind portin.OrderIDKind) *portin.Job {
+		return portin.NewJob(portin.Config{Source: source, Prefix: prefix, OrderIDKind: kind}, nil, nil, nil, nil, zap.NewNop())
+	}
+	s7 := newJob("s7", "pin", portin.OrderIDInt)
+	s3 := newJob("s3", "p03", portin.OrderIDInt)
+	g := newJob("g", "pg", portin.OrderIDGUID)
+
+	routed, unrouted := portin.RouteOrders([]*portin.Job{s7, s3, g},
+		[]string{"pin123", "p03456", " pin007 ", "pg" + guid, "pinx", "123", "pg123"})
+	require.Equal(t, map[*portin.Job][]string{
+		s7: {"123", "7"},
+		s3: {"456"},
+		g:  {guid},
+	}, routed)
+	require.Equal(t, []string{"pinx", "123", "pg123"}, unrouted)
+}
@@ -0,0 +1,130 @@
+package portin
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+// payloadProfile накапливает пути полей order_data за один батч.
+type payloadProfile struct {
+	table    string
+	payloads int
+	counts   map[target.SchemaPath]int
+	paths    map[string]struct{}
+	unknown  map[string]struct{}
+}
+
+func newPayloadProfile(table string) *payloadProfile {
+	return &payloadProfile{
+		table:   table,
+		counts:  make(map[target.SchemaPath]int),
+		paths:   make(map[string]struct{}),
+		unknown: make(map[string]struct{}),
+	}
+}
+
+func (p *payloadProfile) add(payload transform.OrderPayload) {
+	p.payloads++
+	for _, kp := range payload.Paths {
+		p.counts[target.SchemaPath{Path: kp.Path, JSONType: kp.JSONType}]++
+		p.paths[kp.Path] = struct{}{}
+	}
+	for _, f := range payload.UnknownFields {
+		p.unknown[f] = struct{}{}
+	}
+}
+
+// driftMinPayloads - минимальный размер батча для проверки путей маппинга: в маленьком батче необязательных
+// групп (например, contract) может не быть без изменения схемы.
+const driftMinPayloads = 100
+
+// profileDrift - отклонения батча: пропавшие группы путей маппинга, новые пути верхнего уровня
+// и поля, неизвестные модели. Новые пути и неизвестные поля отбираются по путям, которых еще нет в профиле.
+type profileDrift struct {
+	missing []string
+	added   []string
+	unknown []string
+}
+
+func (p *payloadProfile) drift(known map[string]struct{}) profileDrift {
+	var d profileDrift
+	if p.payloads >= driftMinPayloads {
+		d.missing = p.missingMappedPaths()
+	}
+	// При пустом профиле (первый запуск) все пути новые, предупреждать не о чем.
+	if len(known) > 0 {
+		d.added = p.newTopLevelPaths(known)
+	}
+	for f := range p.unknown {
+		if _, ok := known[f]; !ok {
+			d.unknown = append(d.unknown, f)
+		}
+	}
+	slices.Sort(d.unknown)
+
+	return d
+}
+
+func (p *payloadProfile) missingMappedPaths() []string {
+	var missing []string
+	for _, group := range transform.MappedPaths {
+		if !slices.ContainsFunc(group, p.has) {
+			missing = append(missing, group...)
+		}
+	}
+
+	return missing
+}
+
+func (p *payloadProfile) newTopLevelPaths(known map[string]struct{}) []string {
+	var res []string
+	for path := range p.paths {
+		if _, ok := known[path]; ok || !transform.IsTopLevelPath(path) {
+			continue
+		}
+		res = append(res, path)
+	}
+	slices.Sort(res)
+
+	return res
+}
+
+func (p *payloadProfile) has(path string) bool {
+	_, ok := p.paths[path]
+	return ok
+}
+
+func (j *Job) reportProfile(ctx context.Context, tx *sql.Tx, p *payloadProfile) error {
+	if p.payloads == 0 {
+		return nil
+	}
+
+	known, err := j.store.KnownSchemaPaths(ctx, j.LockKey(), p.table)
+	if err != nil {
+		return err
+	}
+
+	d := p.drift(known)
+	log := j.logger.With(zap.String("source_table", p.table), zap.Int("payloads", p.payloads))
+	if len(d.missing) > 0 {
+		log.Warn("order payload schema drift: mapped paths are missing in batch", zap.Strings("paths", d.missing))
+		metrics.SchemaDrift.WithLabelValues(j.Name(), p.table, metrics.DriftMissingMapped).Add(float64(len(d.missing)))
+	}
+	if len(d.added) > 0 {
+		log.Warn("order payload schema drift: new top-level paths", zap.Strings("paths", d.added))
+		metrics.SchemaDrift.WithLabelValues(j.Name(), p.table, metrics.DriftNewPath).Add(float64(len(d.added)))
+	}
+	if len(d.unknown) > 0 {
+		log.Warn("order payload contains fields unknown to the model", zap.Strings("fields", d.unknown))
+		metrics.SchemaDrift.WithLabelValues(j.Name(), p.table, metrics.DriftUnknownField).Add(float64(len(d.unknown)))
+	}
+
+	return j.store.UpsertSchemaProfile(ctx, tx, j.LockKey(), p.table, p.counts)
+}
@@ -0,0 +1,76 @@
+package portin_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/portin"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+func parsePayloads(t *testing.T, n int, raw string) []transform.OrderPayload {
+	t.Helper()
+
+	p, err := transform.ParseOrderPayload([]byte(raw))
+	require.NoError(t, err)
+	res := make([]transform.OrderPayload, n)
+	for i := range res {
+		res[i] = p
+	}
+
+	return res
+}
+
+func TestProfileDrift(t *testing.T) {
+	const full = `{
+		"processType": "ShortTimePort",
+		"contract": {"contractDate": "2026-03-01"},
+		"status": {"code": "created"},
+		"recipient": {"rn": "D0001", "cdbCode": "mMTS"},
+		"person": {"firstName": "Ivan"},
+		"portationNumbers": [{"msisdn": "79000000000"}]
+	}`
+	const noContract = `{
+		"processType": "ShortTimePort",
+		"status": {"code": "created"},
+		"recipient": {"rn": "D0001", "cdbCode": "mMTS"},
+		"person": {"firstName": "Ivan"},
+		"portationNumbers": [{"msisdn": "79000000000"}],
+		"channel": "web"
+	}`
+	known := []string{"processType", "contract", "contract.contractDate", "status", "status.code", "recipient",
+		"recipient.rn", "recipient.cdbCode", "person", "person.firstName", "portationNumbers",
+		"portationNumbers[]", "portationNumbers[].msisdn"}
+
+	t.Run("no drift", func(t *testing.T) {
+		missing, added, unknown := portin.ProfileDrift(parsePayloads(t, 200, full), known)
+		require.Empty(t, missing)
+		require.Empty(t, added)
+		require.Empty(t, unknown)
+	})
+
+	t.Run("small batch without optional group", func(t *testing.T) {
+		missing, _, _ := portin.ProfileDrift(parsePayloads(t, 1, noContract), known)
+		require.Empty(t, missing)
+	})
+
+	t.Run("large batch without mapped group", func(t *testing.T) {
+		missing, added, unknown := portin.ProfileDrift(parsePayloads(t, 100, noContract), known)
+		require.Equal(t, []string{"contract.documentDate", "contract.contractDate"}, missing)
+		require.Equal(t, []string{"channel"}, added)
+		require.Equal(t, []string{"channel"}, unknown)
+	})
+
+	t.Run("unknown field already in profile", func(t *testing.T) {
+		_, added, unknown := portin.ProfileDrift(parsePayloads(t, 1, noContract), append(known, "channel"))
+		require.Empty(t, added)
+		require.Empty(t, unknown)
+	})
+
+	t.Run("first run", func(t *testing.T) {
+		_, added, unknown := portin.ProfileDrift(parsePayloads(t, 1, noContract), nil)
+		require.Empty(t, added)
+		require.Equal(t, []string{"channel"}, unknown)
+	})
+}
@@ -0,0 +1,65 @@
+package portin
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+var snapshotTxOptions = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+
+// queryer - *sql.DB или транзакция снимка источника.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sources - источники, из которых читает запуск.
+type sources struct {
+	orders queryer
+	cancel queryer
+	// snapshot - экспортированный снимок portin-orders-db для параллельных чтений, пусто при Workers = 1.
+	snapshot string
+}
+
+// beginSnapshot открывает транзакции REPEATABLE READ READ ONLY в portin-orders-db и portin-cancel-db.
+// Все чтения запуска видят один снимок, поэтому to_date версий orders_log согласован с changing_date orders.
+// Снимок фиксируется первым запросом, его время - now() источника.
+func (j *Job) beginSnapshot(ctx context.Context) (sources, time.Time, func(), error) {
+	defer metrics.ObserveQuery(metrics.DBPortIn, "begin_snapshot")()
+
+	ordersTx, err := j.sourceDB.BeginTx(ctx, snapshotTxOptions)
+	if err != nil {
+		return sources{}, time.Time{}, nil, err
+	}
+	cancelTx, err := j.cancelDB.BeginTx(ctx, snapshotTxOptions)
+	if err != nil {
+		_ = ordersTx.Rollback()
+		return sources{}, time.Time{}, nil, err
+	}
+	release := func() {
+		_ = cancelTx.Rollback()
+		_ = ordersTx.Rollback()
+	}
+
+	var snapshotAt time.Time
+	if err := ordersTx.QueryRowContext(ctx, `SELECT now()`).Scan(&snapshotAt); err != nil {
+		release()
+		return sources{}, time.Time{}, nil, err
+	}
+	if _, err := cancelTx.ExecContext(ctx, `SELECT 1`); err != nil {
+		release()
+		return sources{}, time.Time{}, nil, err
+	}
+	src := sources{orders: ordersTx, cancel: cancelTx}
+	if j.cfg.Workers > 1 {
+		if err := ordersTx.QueryRowContext(ctx, `SELECT pg_export_snapshot()`).Scan(&src.snapshot); err != nil {
+			release()
+			return sources{}, time.Time{}, nil, err
+		}
+	}
+
+	return src, snapshotAt, release, nil
+}
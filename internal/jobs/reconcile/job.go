@@ -0,0 +1,122 @@
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/reconcile")
+
+type Config struct {
+	// Window - длительность сверяемого периода.
+	Window time.Duration
+	// Settle - период перед запуском, который не сверяется: данные за него еще загружаются.
+	Settle time.Duration
+	// SampleSize - сколько совпавших ключей каждой проверки сверяется по контрольной сумме полей.
+	SampleSize int
+	// AutoBackfill - ставить ключи с расхождениями в etl_backfill.
+	AutoBackfill bool
+	Schedule     scheduler.Spec
+}
+
+// Checker - джоба, которая умеет сверить свои данные в источнике и витрине за период [from, to).
+type Checker interface {
+	Reconcile(ctx context.Context, from, to time.Time, sample int) ([]target.ReconciliationResult, error)
+}
+
+type Job struct {
+	cfg      Config
+	checkers []Checker
+	targetDB *sql.DB
+	store    *target.Store
+	logger   *zap.Logger
+}
+
+func NewJob(cfg Config, checkers []Checker, targetDB *sql.DB, store *target.Store, logger *zap.Logger) *Job {
+	if cfg.Window <= 0 {
+		cfg.Window = 24 * time.Hour
+	}
+	if cfg.SampleSize < 0 {
+		cfg.SampleSize = 0
+	}
+
+	return &Job{cfg: cfg, checkers: checkers, targetDB: targetDB, store: store, logger: logger.Named("reconcile-job")}
+}
+
+func (j *Job) Name() string { return "reconcile" }
+
+func (j *Job) LockKey() string { return "reconcile-dag" }
+
+func (j *Job) DependsOn() []string { return nil }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
+func (j *Job) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Run")
+	defer span.End()
+
+	runAt := time.Now()
+	to := runAt.Add(-j.cfg.Settle)
+	from := to.Add(-j.cfg.Window)
+
+	var results []target.ReconciliationResult
+	for _, c := range j.checkers {
+		res, err := c.Reconcile(ctx, from, to, j.cfg.SampleSize)
+		if err != nil {
+			return err
+		}
+		results = append(results, res...)
+	}
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	mismatches := make(map[string]int)
+	for i := range results {
+		r := &results[i]
+		r.RunAt = runAt
+		if !r.Mismatched() {
+			continue
+		}
+		mismatches[r.Check]++
+		j.logger.Warn("reconciliation mismatch",
+			zap.String("check", r.Check),
+			zap.String("day", r.Day),
+			zap.String("status", r.Status),
+			zap.Int("source_count", r.SourceCount),
+			zap.Int("target_count", r.TargetCount),
+			zap.Int("missing", r.MissingCount),
+			zap.Int("extra", r.ExtraCount),
+			zap.Int("checksum_mismatched", r.ChecksumMismatched))
+
+		if j.cfg.AutoBackfill && len(r.MismatchedKeys) > 0 {
+			if err := j.store.EnqueueBackfill(ctx, tx, r.Job, r.Check, r.MismatchedKeys); err != nil {
+				return err
+			}
+			r.BackfillEnqueued = true
+		}
+	}
+	if err := j.store.SaveReconciliation(ctx, tx, results); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		metrics.ReconciliationMismatches.WithLabelValues(r.Check).Set(float64(mismatches[r.Check]))
+	}
+
+	return nil
+}
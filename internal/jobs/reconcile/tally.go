@@ -0,0 +1,119 @@
+package reconcile
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+type groupKey struct {
+	day    string
+	status string
+}
+
+// Tally накапливает результат одной проверки по дням бизнес-зоны и статусам.
+type Tally struct {
+	job    string
+	check  string
+	loc    *time.Location
+	sample int
+
+	sampled int
+	groups  map[groupKey]*target.ReconciliationResult
+}
+
+// NewTally создает проверку check данных джобы job (ключ блокировки). sample - сколько ключей
+// сверяется по контрольной сумме полей.
+func NewTally(job, check string, loc *time.Location, sample int) *Tally {
+	return &Tally{job: job, check: check, loc: loc, sample: sample, groups: make(map[groupKey]*target.ReconciliationResult)}
+}
+
+func (t *Tally) Source(at time.Time, status string) { t.group(at, status).SourceCount++ }
+
+func (t *Tally) Target(at time.Time, status string) { t.group(at, status).TargetCount++ }
+
+// Missing - ключ источника отсутствует в витрине или загружен с другим статусом.
+func (t *Tally) Missing(at time.Time, status, key string) {
+	g := t.group(at, status)
+	g.MissingCount++
+	g.MismatchedKeys = append(g.MismatchedKeys, key)
+}
+
+// Extra - ключ витрины отсутствует в источнике за период.
+func (t *Tally) Extra(at time.Time, status, key string) {
+	g := t.group(at, status)
+	g.ExtraCount++
+	g.MismatchedKeys = append(g.MismatchedKeys, key)
+}
+
+// Sample сообщает, нужно ли сверить контрольную сумму очередного совпавшего ключа.
+func (t *Tally) Sample() bool {
+	if t.sampled >= t.sample {
+		return false
+	}
+	t.sampled++
+
+	return true
+}
+
+func (t *Tally) Checksum(at time.Time, status, key, expected, actual string) {
+	g := t.group(at, status)
+	g.ChecksumSampled++
+	if expected != actual {
+		g.ChecksumMismatched++
+		g.MismatchedKeys = append(g.MismatchedKeys, key)
+	}
+}
+
+func (t *Tally) Results() []target.ReconciliationResult {
+	res := make([]target.ReconciliationResult, 0, len(t.groups))
+	for _, g := range t.groups {
+		slices.Sort(g.MismatchedKeys)
+		g.MismatchedKeys = slices.Compact(g.MismatchedKeys)
+		res = append(res, *g)
+	}
+	slices.SortFunc(res, func(a, b target.ReconciliationResult) int {
+		return cmp.Or(cmp.Compare(a.Day, b.Day), cmp.Compare(a.Status, b.Status))
+	})
+
+	return res
+}
+
+func (t *Tally) group(at time.Time, status string) *target.ReconciliationResult {
+	key := groupKey{day: target.WallClock(at, t.loc).Format(time.DateOnly), status: status}
+	g, ok := t.groups[key]
+	if !ok {
+		g = &target.ReconciliationResult{Job: t.job, Check: t.check, Day: key.day, Status: status}
+		t.groups[key] = g
+	}
+
+	return g
+}
+
+// Checksum считает контрольную сумму значений полей. Время приводится к UTC с точностью Postgres,
+// поэтому значения источника и витрины в разных зонах совпадают.
+func Checksum(fields ...any) string {
+	h := sha256.New()
+	for _, f := range fields {
+		switch v := f.(type) {
+		case time.Time:
+			f = v.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+		case *time.Time:
+			if v != nil {
+				f = v.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+			}
+		case *int:
+			if v != nil {
+				f = *v
+			}
+		}
+		_, _ = fmt.Fprintf(h, "%v\x1f", f)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package reconcile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs/reconcile"
+)
+
+func TestTally(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// 22:30 UTC - уже следующий день в бизнес-зоне.
+	late := time.Date(2026, 10, 18, 22, 30, 0, 0, time.UTC)
+	early := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+
+	tally := reconcile.NewTally("portin-dag", "mnp_request", loc, 1)
+	tally.Source(late, "4")
+	tally.Target(late, "4")
+	require.True(t, tally.Sample())
+	tally.Checksum(late, "4", "101", "a", "b")
+	require.False(t, tally.Sample())
+
+	tally.Source(early, "4")
+	tally.Missing(early, "4", "102")
+	tally.Missing(early, "4", "102")
+	tally.Target(early, "11")
+	tally.Extra(early, "11", "103")
+
+	res := tally.Results()
+	require.Len(t, res, 3)
+
+	require.Equal(t, "2026-10-18", res[0].Day)
+	require.Equal(t, "11", res[0].Status)
+	require.Equal(t, 1, res[0].ExtraCount)
+	require.Equal(t, []string{"103"}, res[0].MismatchedKeys)
+
+	require.Equal(t, "2026-10-18", res[1].Day)
+	require.Equal(t, "4", res[1].Status)
+	require.Equal(t, 1, res[1].SourceCount)
+	require.Equal(t, 0, res[1].TargetCount)
+	require.Equal(t, []string{"102"}, res[1].MismatchedKeys)
+	require.True(t, res[1].Mismatched())
+
+	require.Equal(t, "2026-10-19", res[2].Day)
+	require.Equal(t, 1, res[2].ChecksumSampled)
+	require.Equal(t, 1, res[2].ChecksumMismatched)
+	require.Equal(t, "portin-dag", res[2].Job)
+	require.True(t, res[2].Mismatched())
+}
+
+func TestChecksum(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Vladivostok")
+	require.NoError(t, err)
+
+	ts := time.Date(2026, 10, 19, 10, 0, 0, 123456789, time.UTC)
+	local := ts.Truncate(time.Microsecond).In(loc)
+	reason := 1010
+
+	require.Equal(t,
+		reconcile.Checksum("pin1", 4, ts, &ts, (*time.Time)(nil), &reason),
+		reconcile.Checksum("pin1", 4, local, &local, (*time.Time)(nil), &reason))
+	require.NotEqual(t,
+		reconcile.Checksum("pin1", 4, ts),
+		reconcile.Checksum("pin1", 11, ts))
+}
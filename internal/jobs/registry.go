@@ -0,0 +1,379 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+)
+
+var (
+	ErrUnknownJob        = errors.New("unknown job")
+	ErrUpstreamFailed    = errors.New("upstream job failed")
+	ErrRetryNotSupported = errors.New("job does not support quarantine retry")
+)
+
+type Job interface {
+	// Name - имя джобы в API и логах.
+	Name() string
+	// LockKey - ключ advisory lock, исключающий параллельный запуск на разных подах.
+	LockKey() string
+	// DependsOn - имена джоб, после успешного выполнения любой из которых запускается эта джоба
+	// (например, все источники portin). Запуск пропускается, только если упали все upstream.
+	DependsOn() []string
+	// Schedule - собственное расписание. Джоба без Schedule.Schedule запускается только вручную и после upstream.
+	Schedule() scheduler.Spec
+	Run(ctx context.Context) error
+}
+
+// QuarantineRetrier - джоба, которая умеет повторно загрузить строку из etl_quarantine.
+type QuarantineRetrier interface {
+	RetryQuarantined(ctx context.Context, id int64) error
+}
+
+type Locker interface {
+	TryLockJob(ctx context.Context, name string) (func(), bool, error)
+}
+
+// SuccessStore хранит время успешных запусков в витрине. Джобу выполняет один под из-за advisory lock,
+// поэтому /health/data остальных подов опирается на это время, а не на состояние в памяти.
+type SuccessStore interface {
+	SaveJobSuccess(ctx context.Context, job string, at time.Time) error
+	JobSuccesses(ctx context.Context) (map[string]time.Time, error)
+}
+
+type Status string
+
+const (
+	StatusIdle      Status = "idle"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+)
+
+type State struct {
+	Name        string     `json:"name"`
+	DependsOn   []string   `json:"dependsOn,omitempty"`
+	Status      Status     `json:"status"`
+	LastStart   *time.Time `json:"lastStart,omitempty"`
+	LastFinish  *time.Time `json:"lastFinish,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	// Pending - запуск отложен до завершения выполняющейся upstream-джобы.
+	Pending bool `json:"pending,omitempty"`
+}
+
+type entry struct {
+	job   Job
+	state State
+	// lastResult - статус последнего завершенного запуска, не перезаписывается на время выполнения.
+	lastResult Status
+	freshness  FreshnessPolicy
+}
+
+// Registry управляет запуском джоб: расписание, ручной запуск по HTTP, зависимости и состояние для health.
+type Registry struct {
+	locker Locker
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	order   []string
+	ctx     context.Context
+	created time.Time
+}
+
+func NewRegistry(locker Locker, logger *zap.Logger) *Registry {
+	return &Registry{
+		locker:  locker,
+		logger:  logger,
+		entries: make(map[string]*entry),
+		ctx:     context.Background(),
+		created: time.Now(),
+	}
+}
+
+func (r *Registry) Register(jobs ...Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, job := range jobs {
+		name := job.Name()
+		if _, ok := r.entries[name]; ok {
+			return fmt.Errorf("job %q already registered", name)
+		}
+		r.entries[name] = &entry{
+			job:        job,
+			state:      State{Name: name, DependsOn: job.DependsOn(), Status: StatusIdle},
+			lastResult: StatusIdle,
+		}
+		r.order = append(r.order, name)
+	}
+
+	return nil
+}
+
+// Validate проверяет, что зависимости зарегистрированы и не образуют цикл.
+func (r *Registry) Validate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(r.entries))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("job dependency cycle: %v", append(path, name))
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, dep := range r.entries[name].job.DependsOn() {
+			if _, ok := r.entries[dep]; !ok {
+				return fmt.Errorf("job %q depends on unknown job %q", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+
+		return nil
+	}
+
+	for _, name := range r.order {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start запускает расписания всех джоб и блокируется до отмены ctx.
+func (r *Registry) Start(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	names := slices.Clone(r.order)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		job := r.entries[name].job
+		spec := job.Schedule()
+		if spec.Schedule == nil {
+			continue
+		}
+		// Таймаут запуска применяет реестр, чтобы он действовал и для запусков после upstream.
+		spec.MaxRunDuration = 0
+
+		wg.Go(func() {
+			scheduler.Run(ctx, name, spec, r.logger.Named("scheduler."+name), func(ctx context.Context) error {
+				err := r.run(ctx, name, "schedule")
+				if errors.Is(err, ErrUpstreamFailed) {
+					return nil
+				}
+
+				return err
+			})
+		})
+	}
+	wg.Wait()
+}
+
+// Trigger синхронно выполняет джобу. После успеха асинхронно запускаются зависимые джобы.
+func (r *Registry) Trigger(ctx context.Context, name string) error {
+	r.mu.Lock()
+	_, ok := r.entries[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+
+	return r.run(ctx, name, "manual")
+}
+
+func (r *Registry) States() []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res := make([]State, 0, len(r.order))
+	for _, name := range r.order {
+		res = append(res, r.entries[name].state)
+	}
+
+	return res
+}
+
+// RetryQuarantined передает запись карантина джобе, которая ее создала (etl_quarantine.job - ключ блокировки).
+func (r *Registry) RetryQuarantined(ctx context.Context, lockKey string, id int64) error {
+	r.mu.Lock()
+	var job Job
+	for _, e := range r.entries {
+		if e.job.LockKey() == lockKey {
+			job = e.job
+		}
+	}
+	r.mu.Unlock()
+
+	retrier, ok := job.(QuarantineRetrier)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRetryNotSupported, lockKey)
+	}
+
+	return retrier.RetryQuarantined(ctx, id)
+}
+
+func (r *Registry) run(ctx context.Context, name, trigger string) error {
+	e := r.entries[name]
+	log := r.logger.With(zap.String("job", name), zap.String("trigger", trigger))
+
+	proceed, err := r.checkUpstream(e, log)
+	if !proceed {
+		if err != nil {
+			metrics.JobRuns.WithLabelValues(name, string(StatusSkipped)).Inc()
+		}
+
+		return err
+	}
+
+	unlock, locked, err := r.locker.TryLockJob(ctx, e.job.LockKey())
+	if err != nil {
+		return err
+	}
+	if !locked {
+		metrics.LockContention.WithLabelValues(name).Inc()
+		log.Info("job already running")
+		return nil
+	}
+	defer unlock()
+
+	if timeout := e.job.Schedule().MaxRunDuration; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	r.markStarted(e)
+	start := time.Now()
+	err = e.job.Run(ctx)
+	metrics.JobDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	r.markFinished(e, err)
+	if err != nil {
+		metrics.JobRuns.WithLabelValues(name, string(StatusFailed)).Inc()
+		r.triggerDependents(name, true)
+
+		return err
+	}
+	metrics.JobRuns.WithLabelValues(name, string(StatusSucceeded)).Inc()
+	metrics.JobLastSuccess.WithLabelValues(name).SetToCurrentTime()
+	if store, ok := r.locker.(SuccessStore); ok {
+		if err := store.SaveJobSuccess(ctx, name, time.Now()); err != nil {
+			log.Warn("save job success failed", zap.Error(err))
+		}
+	}
+
+	r.triggerDependents(name, false)
+
+	return nil
+}
+
+// checkUpstream откладывает запуск, если upstream-джоба выполняется: по завершении upstream запустит зависимые джобы сама.
+// Запуск пропускается, только если последний запуск каждой upstream-джобы упал: сбой одного источника
+// не останавливает джобы, которые обрабатывают данные всех источников.
+func (r *Registry) checkUpstream(e *entry, log *zap.Logger) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deps := e.job.DependsOn()
+	failures := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		upstream := r.entries[dep]
+		switch {
+		case upstream.state.Status == StatusRunning:
+			e.state.Pending = true
+			log.Info("job delayed until upstream finishes", zap.String("upstream", dep))
+
+			return false, nil
+		case upstream.lastResult == StatusFailed:
+			failures = append(failures, fmt.Sprintf("upstream %s failed: %s", dep, upstream.state.LastError))
+		}
+	}
+	if len(deps) == 0 || len(failures) < len(deps) {
+		return true, nil
+	}
+
+	e.state.Status = StatusSkipped
+	e.state.Pending = false
+	e.state.LastError = strings.Join(failures, "; ")
+	log.Warn("job skipped: upstream failed", zap.Strings("upstream", deps))
+
+	return false, fmt.Errorf("%w: %s", ErrUpstreamFailed, strings.Join(deps, ", "))
+}
+
+func (r *Registry) markStarted(e *entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	e.state.Status = StatusRunning
+	e.state.LastStart = &now
+	e.state.Pending = false
+}
+
+func (r *Registry) markFinished(e *entry, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	e.state.LastFinish = &now
+	if err != nil {
+		e.state.Status = StatusFailed
+		e.state.LastError = err.Error()
+		e.lastResult = StatusFailed
+
+		return
+	}
+	e.state.Status = StatusSucceeded
+	e.state.LastSuccess = &now
+	e.state.LastError = ""
+	e.lastResult = StatusSucceeded
+}
+
+// triggerDependents запускает зависимые джобы после завершения upstream. После сбоя запускаются только отложенные
+// (Pending): они проверят остальные upstream и выполнятся или будут пропущены.
+func (r *Registry) triggerDependents(name string, pendingOnly bool) {
+	r.mu.Lock()
+	ctx := r.ctx
+	var dependents []string
+	for _, n := range r.order {
+		e := r.entries[n]
+		if slices.Contains(e.job.DependsOn(), name) && (!pendingOnly || e.state.Pending) {
+			dependents = append(dependents, n)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, dep := range dependents {
+		go func() {
+			if err := r.run(ctx, dep, "upstream:"+name); err != nil && !errors.Is(err, ErrUpstreamFailed) {
+				r.logger.Error("job execution failed", zap.String("job", dep), zap.Error(err))
+			}
+		}()
+	}
+}
@@ -0,0 +1,137 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/jobs"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+)
+
+type fakeJob struct {
+	name string
+	deps []string
+	err  error
+	runs chan struct{}
+}
+
+func newFakeJob(name string, deps ...string) *fakeJob {
+	return &fakeJob{name: name, deps: deps, runs: make(chan struct{}, 10)}
+}
+
+func (j *fakeJob) Name() string             { return j.name }
+func (j *fakeJob) LockKey() string          { return j.name + "-dag" }
+func (j *fakeJob) DependsOn() []string      { return j.deps }
+func (j *fakeJob) Schedule() scheduler.Spec { return scheduler.Spec{} }
+
+func (j *fakeJob) Run(context.Context) error {
+	j.runs <- struct{}{}
+	return j.err
+}
+
+type fakeLocker struct{}
+
+func (fakeLocker) TryLockJob(context.Context, string) (func(), bool, error) {
+	return func() {}, true, nil
+}
+
+func TestRegistryValidate(t *testing.T) {
+	r := jobs.NewRegistry(fakeLocker{}, zap.NewNop())
+	require.NoError(t, r.Register(newFakeJob("a", "b"), newFakeJob("b", "a")))
+	require.ErrorContains(t, r.Validate(), "cycle")
+
+	r = jobs.NewRegistry(fakeLocker{}, zap.NewNop())
+	require.NoError(t, r.Register(newFakeJob("a", "missing")))
+	require.ErrorContains(t, r.Validate(), "unknown job")
+
+	require.Error(t, r.Register(newFakeJob("a")))
+}
+
+func TestRegistryDependencies(t *testing.T) {
+	upstream := newFakeJob("portin")
+	downstream := newFakeJob("cdb-message", "portin")
+
+	r := jobs.NewRegistry(fakeLocker{}, zap.NewNop())
+	require.NoError(t, r.Register(upstream, downstream))
+	require.NoError(t, r.Validate())
+
+	require.NoError(t, r.Trigger(context.Background(), "portin"))
+	select {
+	case <-downstream.runs:
+	case <-time.After(time.Second):
+		t.Fatal("downstream job was not triggered after upstream success")
+	}
+
+	upstream.err = errors.New("source unavailable")
+	require.Error(t, r.Trigger(context.Background(), "portin"))
+	require.ErrorIs(t, r.Trigger(context.Background(), "cdb-message"), jobs.ErrUpstreamFailed)
+	require.Empty(t, downstream.runs)
+
+	states := r.States()
+	require.Len(t, states, 2)
+	require.Equal(t, jobs.StatusFailed, states[0].Status)
+	require.Equal(t, jobs.StatusSkipped, states[1].Status)
+
+	require.ErrorIs(t, r.Trigger(context.Background(), "portout"), jobs.ErrUnknownJob)
+}
+
+// blockingJob выполняется, пока тест не закроет release.
+type blockingJob struct {
+	*fakeJob
+	release chan struct{}
+}
+
+func (j *blockingJob) Run(ctx context.Context) error {
+	j.runs <- struct{}{}
+	<-j.release
+
+	return j.err
+}
+
+func TestRegistryDependsOnAnyUpstream(t *testing.T) {
+	ctx := context.Background()
+	s7 := newFakeJob("portin-s7")
+	s3 := &blockingJob{fakeJob: newFakeJob("portin-s3"), release: make(chan struct{})}
+	aggregate := newFakeJob("aggregate", "portin-s7", "portin-s3")
+
+	r := jobs.NewRegistry(fakeLocker{}, zap.NewNop())
+	require.NoError(t, r.Register(s7, s3, aggregate))
+	require.NoError(t, r.Validate())
+
+	// Сбой одного источника не пропускает зависимую джобу.
+	s3.err = errors.New("s3 unavailable")
+	close(s3.release)
+	require.Error(t, r.Trigger(ctx, "portin-s3"))
+	<-s3.runs
+	require.NoError(t, r.Trigger(ctx, "portin-s7"))
+	select {
+	case <-aggregate.runs:
+	case <-time.After(time.Second):
+		t.Fatal("aggregate was not triggered after portin-s7 success")
+	}
+	require.NoError(t, r.Trigger(ctx, "aggregate"))
+	<-aggregate.runs
+
+	// Отложенная джоба пропускается, когда упали все upstream, и перестает быть Pending.
+	s7.err = errors.New("s7 unavailable")
+	require.Error(t, r.Trigger(ctx, "portin-s7"))
+	<-s7.runs
+	s3.release = make(chan struct{})
+	done := make(chan error)
+	go func() { done <- r.Trigger(ctx, "portin-s3") }()
+	<-s3.runs
+	require.NoError(t, r.Trigger(ctx, "aggregate"))
+	require.True(t, r.States()[2].Pending)
+	close(s3.release)
+	require.Error(t, <-done)
+	require.Eventually(t, func() bool {
+		st := r.States()[2]
+		return st.Status == jobs.StatusSkipped && !st.Pending
+	}, time.Second, 10*time.Millisecond)
+	require.Empty(t, aggregate.runs)
+}
@@ -0,0 +1,313 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/replica")
+
+const name = "replica"
+
+type Config struct {
+	// BatchSize - заявок Replica в одной транзакции загрузки.
+	BatchSize int
+	// Lookback - насколько раньше сохраненного watermark перечитываются изменения: change_date в Replica
+	// проставляется до commit, и строка может стать видимой позже более новых.
+	Lookback time.Duration
+	Location *time.Location
+	Schedule scheduler.Spec
+}
+
+// Job загружает выгрузку Replica ESB (mnp_request, mnp_request_h, req_number, mnp_raw_request в формате ESB)
+// в таблицы replica_*. Заявка, у которой изменилась строка или версия, перезагружается целиком.
+type Job struct {
+	cfg      Config
+	sourceDB *sql.DB
+	targetDB *sql.DB
+	store    *target.Store
+	logger   *zap.Logger
+}
+
+func NewJob(cfg Config, sourceDB, targetDB *sql.DB, store *target.Store, logger *zap.Logger) *Job {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+
+	return &Job{cfg: cfg, sourceDB: sourceDB, targetDB: targetDB, store: store, logger: logger.Named("replica-job")}
+}
+
+func (j *Job) Name() string { return name }
+
+func (j *Job) LockKey() string { return "replica-dag" }
+
+func (j *Job) DependsOn() []string { return nil }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
+// changedKey - позиция в списке измененных заявок Replica.
+type changedKey struct {
+	changeDate time.Time
+	id         int64
+}
+
+func (j *Job) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Run")
+	defer span.End()
+
+	wm, err := j.store.JobWatermark(ctx, name)
+	if err != nil {
+		return err
+	}
+	var from *time.Time
+	if wm != nil {
+		t := target.WallClock(wm.Add(-j.cfg.Lookback), j.cfg.Location)
+		from = &t
+	}
+
+	var (
+		after  *changedKey
+		loaded int
+	)
+	for {
+		ids, last, err := j.changedRequests(ctx, from, after)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		if err := j.loadBatch(ctx, ids, last); err != nil {
+			return err
+		}
+		loaded += len(ids)
+		after = &last
+		if len(ids) < j.cfg.BatchSize {
+			break
+		}
+	}
+
+	j.logger.Info("replica loaded", zap.Int("requests", loaded))
+
+	return nil
+}
+
+// changedRequests возвращает пачку ID заявок, строки или версии которых изменены после from, по возрастанию (change_date, id).
+func (j *Job) changedRequests(ctx context.Context, from *time.Time, after *changedKey) ([]int64, changedKey, error) {
+	defer metrics.ObserveQuery(metrics.DBReplica, "changed_requests")()
+
+	var (
+		afterDate *time.Time
+		afterID   int64
+	)
+	if after != nil {
+		afterDate, afterID = &after.changeDate, after.id
+	}
+
+	rows, err := j.sourceDB.QueryContext(ctx, `
+WITH c AS (
+  SELECT id, max(change_date) AS change_date FROM (
+    SELECT id, change_date FROM mnp_request WHERE $1::timestamp IS NULL OR change_date > $1
+    UNION ALL
+    SELECT id, change_date FROM mnp_request_h WHERE $1::timestamp IS NULL OR change_date > $1
+  ) u
+  GROUP BY id
+)
+SELECT id, change_date FROM c
+WHERE $2::timestamp IS NULL OR (change_date, id) > ($2, $3)
+ORDER BY change_date, id
+LIMIT $4`, from, afterDate, afterID, j.cfg.BatchSize)
+	if err != nil {
+		return nil, changedKey{}, err
+	}
+	defer rows.Close()
+
+	var (
+		ids  []int64
+		last changedKey
+	)
+	for rows.Next() {
+		if err := rows.Scan(&last.id, &last.changeDate); err != nil {
+			return nil, changedKey{}, err
+		}
+		ids = append(ids, last.id)
+	}
+
+	return ids, last, rows.Err()
+}
+
+// loadBatch перечитывает заявки ids из Replica и заменяет их в витрине вместе с watermark = last.
+func (j *Job) loadBatch(ctx context.Context, ids []int64, last changedKey) error {
+	b := target.ReplicaBatch{OrderIDs: ids}
+
+	var err error
+	if b.Requests, err = j.requests(ctx, "mnp_request", ids); err != nil {
+		return err
+	}
+	if b.Versions, err = j.requests(ctx, "mnp_request_h", ids); err != nil {
+		return err
+	}
+	if b.Numbers, err = j.numbers(ctx, ids); err != nil {
+		return err
+	}
+	if b.Messages, err = j.messages(ctx, ids); err != nil {
+		return err
+	}
+
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.store.ReplaceReplicaBatch(ctx, tx, b); err != nil {
+		return err
+	}
+	if err := j.store.SaveJobWatermark(ctx, tx, name, target.FromWallClock(last.changeDate, j.cfg.Location)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	for table, n := range map[string]int{
+		"mnp_request":     len(b.Requests),
+		"mnp_request_h":   len(b.Versions),
+		"req_number":      len(b.Numbers),
+		"mnp_raw_request": len(b.Messages),
+	} {
+		metrics.RowsExtracted.WithLabelValues(name, table).Add(float64(n))
+		metrics.RowsUpserted.WithLabelValues(name, table).Add(float64(n))
+	}
+
+	return nil
+}
+
+// replicaOrderNumber - order_number в Replica необязателен, без него заявка идентифицируется своим ID.
+const replicaOrderNumber = `COALESCE(r.order_number, r.id::text)`
+
+func (j *Job) requests(ctx context.Context, table string, ids []int64) ([]target.ReplicaRequest, error) {
+	defer metrics.ObserveQuery(metrics.DBReplica, table)()
+
+	rows, err := j.sourceDB.QueryContext(ctx, `
+SELECT r.id, `+replicaOrderNumber+`, r.request_status_id, r.request_date, r.contract_date, r.port_date,
+  r.from_date, r.to_date, r.deleted, r.cdb_id, r.process_type, r.port_type, r.subscriber_type, r.message_code, r.reject_reason
+FROM `+table+` r
+WHERE r.id = ANY($1)
+ORDER BY r.id, r.from_date`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []target.ReplicaRequest
+	for rows.Next() {
+		var (
+			r                                   target.ReplicaRequest
+			requestDate, contractDate, portDate sql.NullTime
+			toDate                              sql.NullTime
+			cdbID, processType, portType        sql.NullString
+			subscriberType, messageCode         sql.NullString
+			rejectReason                        sql.NullInt32
+		)
+		err := rows.Scan(&r.OrderID, &r.OrderNumber, &r.RequestStatusID, &requestDate, &contractDate, &portDate,
+			&r.FromDate, &toDate, &r.Deleted, &cdbID, &processType, &portType, &subscriberType, &messageCode, &rejectReason)
+		if err != nil {
+			return nil, err
+		}
+		r.RequestDate = j.timePtr(requestDate)
+		r.ContractDate = j.timePtr(contractDate)
+		r.PortDate = j.timePtr(portDate)
+		r.FromDate = target.FromWallClock(r.FromDate, j.cfg.Location)
+		r.ToDate = j.timePtr(toDate)
+		r.CDBID, r.ProcessType, r.PortType = cdbID.String, processType.String, portType.String
+		r.SubscriberType, r.MessageCode = subscriberType.String, messageCode.String
+		if rejectReason.Valid {
+			v := int(rejectReason.Int32)
+			r.RejectReason = &v
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+func (j *Job) numbers(ctx context.Context, ids []int64) ([]target.RequestNumber, error) {
+	defer metrics.ObserveQuery(metrics.DBReplica, "req_number")()
+
+	rows, err := j.sourceDB.QueryContext(ctx, `
+SELECT `+replicaOrderNumber+`, n.recipient_id, n.msisdn::text, n.rn
+FROM req_number n
+JOIN mnp_request r ON r.id = n.req_id
+WHERE n.req_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []target.RequestNumber
+	for rows.Next() {
+		var (
+			n               target.RequestNumber
+			recipientID, rn sql.NullString
+		)
+		if err := rows.Scan(&n.ReqID, &recipientID, &n.MSISDN, &rn); err != nil {
+			return nil, err
+		}
+		n.RecipientID, n.RN = recipientID.String, rn.String
+		res = append(res, n)
+	}
+
+	return res, rows.Err()
+}
+
+func (j *Job) messages(ctx context.Context, ids []int64) ([]target.RawRequest, error) {
+	defer metrics.ObserveQuery(metrics.DBReplica, "mnp_raw_request")()
+
+	rows, err := j.sourceDB.QueryContext(ctx, `
+SELECT m.id, `+replicaOrderNumber+`, m.request_time, m.xml_message, m.operation_info, m.system_source, m.system_dest
+FROM mnp_raw_request m
+JOIN mnp_request r ON r.id = m.req_id
+WHERE m.req_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []target.RawRequest
+	for rows.Next() {
+		var (
+			m                                   target.RawRequest
+			xml, operation, source, destination sql.NullString
+		)
+		if err := rows.Scan(&m.ID, &m.ReqID, &m.RequestTime, &xml, &operation, &source, &destination); err != nil {
+			return nil, err
+		}
+		m.RequestTime = target.FromWallClock(m.RequestTime, j.cfg.Location)
+		m.XMLMessage, m.OperationInfo, m.SystemSource, m.SystemDest = xml.String, operation.String, source.String, destination.String
+		res = append(res, m)
+	}
+
+	return res, rows.Err()
+}
+
+func (j *Job) timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	v := target.FromWallClock(t.Time, j.cfg.Location)
+
+	return &v
+}
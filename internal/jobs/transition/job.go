@@ -0,0 +1,115 @@
+package transition
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/jobs/transition")
+
+type Config struct {
+	// BatchSize - заявок в одной транзакции пересчета.
+	BatchSize int
+	// Settle - версии с change_date позже now()-Settle ждут следующего запуска: их транзакции могут быть не завершены.
+	// Граница также не позже начала самой старой открытой транзакции витрины.
+	Settle   time.Duration
+	Schedule scheduler.Spec
+	// DependsOn - джобы portin всех источников.
+	DependsOn []string
+}
+
+// Job поддерживает mnp_request_transition. Каждый запуск пересчитывает переходы целиком для заявок,
+// версии которых изменились после сохраненного watermark, поэтому поздняя версия исправляет и соседние переходы.
+type Job struct {
+	cfg      Config
+	targetDB *sql.DB
+	store    *target.Store
+	logger   *zap.Logger
+}
+
+func NewJob(cfg Config, targetDB *sql.DB, store *target.Store, logger *zap.Logger) *Job {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+
+	return &Job{cfg: cfg, targetDB: targetDB, store: store, logger: logger.Named("transition-job")}
+}
+
+func (j *Job) Name() string { return "transition" }
+
+func (j *Job) LockKey() string { return "transition-dag" }
+
+func (j *Job) DependsOn() []string { return j.cfg.DependsOn }
+
+func (j *Job) Schedule() scheduler.Spec { return j.cfg.Schedule }
+
+func (j *Job) Run(ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "Run")
+	defer span.End()
+
+	// change_date - время начала транзакции: версии открытой транзакции станут видимыми с change_date раньше ее commit.
+	until := time.Now().Add(-j.cfg.Settle)
+	oldest, err := j.store.OldestTransactionStart(ctx)
+	if err != nil {
+		return err
+	}
+	if oldest != nil && oldest.Before(until) {
+		until = *oldest
+	}
+	from, err := j.store.JobWatermark(ctx, j.Name())
+	if err != nil {
+		return err
+	}
+	if from != nil && !from.Before(until) {
+		return nil
+	}
+
+	orders, err := j.store.TouchedTransitionOrders(ctx, from, until)
+	if err != nil {
+		return err
+	}
+
+	// Пересчет идемпотентен: при сбое между пачками watermark не сохраняется и следующий запуск повторит все заявки.
+	for start := 0; ; start += j.cfg.BatchSize {
+		end := min(start+j.cfg.BatchSize, len(orders))
+		if err := j.rebuild(ctx, orders[start:end], end == len(orders), until); err != nil {
+			return err
+		}
+		if end == len(orders) {
+			break
+		}
+	}
+
+	j.logger.Info("transitions rebuilt",
+		zap.Bool("full", from == nil),
+		zap.Int("orders", len(orders)),
+		zap.Time("until", until))
+
+	return nil
+}
+
+func (j *Job) rebuild(ctx context.Context, orders []string, last bool, until time.Time) error {
+	tx, err := j.store.BeginWrite(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	if err := j.store.RebuildTransitions(ctx, tx, orders); err != nil {
+		return err
+	}
+	if last {
+		if err := j.store.SaveJobWatermark(ctx, tx, j.Name(), until); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
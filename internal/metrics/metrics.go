@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "datamart"
+
+// Базы данных в метке db метрики query_duration_seconds.
+const (
+	DBTarget       = "target"
+	DBPortIn       = "portin"
+	DBPortInCancel = "portin_cancel"
+	DBCDBMessaging = "cdb_messaging"
+	DBReplica      = "replica"
+)
+
+// Причины пропуска строки в метке reason метрики etl_rows_skipped_total.
+const (
+	SkipOrderType      = "order_type"
+	SkipSubscriberType = "subscriber_type"
+)
+
+// Виды отклонений в метке kind метрики etl_schema_drift_total.
+const (
+	DriftMissingMapped = "missing_mapped"
+	DriftNewPath       = "new_top_level_path"
+	DriftUnknownField  = "unknown_field"
+)
+
+var (
+	RowsExtracted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "etl_rows_extracted_total",
+		Help:      "Rows read from the source.",
+	}, []string{"job", "table"})
+
+	RowsUpserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "etl_rows_upserted_total",
+		Help:      "Rows written to the datamart.",
+	}, []string{"job", "table"})
+
+	RowsSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "etl_rows_skipped_total",
+		Help:      "Source rows skipped by the transformation.",
+	}, []string{"job", "table", "reason"})
+
+	RowsQuarantined = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "etl_rows_quarantined_total",
+		Help:      "Source rows moved to etl_quarantine.",
+	}, []string{"job", "table"})
+
+	SchemaDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "etl_schema_drift_total",
+		Help:      "Payload paths reported as schema drift, by kind.",
+	}, []string{"job", "table", "kind"})
+
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Finished job runs by result.",
+	}, []string{"job", "result"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Job run duration.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	}, []string{"job"})
+
+	JobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful job run.",
+	}, []string{"job"})
+
+	LockContention = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_lock_contention_total",
+		Help:      "Job runs skipped because the advisory lock was held by another run.",
+	}, []string{"job"})
+
+	Watermark = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "etl_watermark_timestamp_seconds",
+		Help:      "Unix time of the latest source change loaded into the datamart.",
+	}, []string{"job"})
+
+	WatermarkLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "etl_watermark_lag_seconds",
+		Help:      "Source now() minus the watermark, measured after the last successful run.",
+	}, []string{"job"})
+
+	ReconciliationMismatches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "reconciliation_mismatched_groups",
+		Help:      "Day/status groups with discrepancies in the last reconciliation run.",
+	}, []string{"check"})
+
+	EventsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mnp_events_received_total",
+		Help:      "mnp-event messages received by the near-real-time refresh.",
+	}, []string{"event_type", "result"})
+
+	EventRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mnp_event_refreshes_total",
+		Help:      "Debounced order refreshes triggered by mnp-event messages, by result.",
+	}, []string{"result"})
+
+	ExportRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_rows_total",
+		Help:      "Rows written to file exports by consumer and table.",
+	}, []string{"consumer", "table"})
+
+	ExportDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_deliveries_total",
+		Help:      "Export directories delivered to consumer sinks, by result.",
+	}, []string{"consumer", "result"})
+
+	QueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Database query latency.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10, 30, 60},
+	}, []string{"db", "query"})
+)
+
+func Handler() http.Handler { return promhttp.Handler() }
+
+// ObserveQuery засекает время запроса. Использование: defer metrics.ObserveQuery(db, name)().
+func ObserveQuery(db, query string) func() {
+	start := time.Now()
+
+	return func() {
+		QueryDuration.WithLabelValues(db, query).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetWatermark выставляет водяной знак джобы и его отставание от времени источника.
+func SetWatermark(job string, watermark *time.Time, sourceNow time.Time) {
+	if watermark == nil {
+		return
+	}
+	Watermark.WithLabelValues(job).Set(float64(watermark.Unix()))
+	WatermarkLag.WithLabelValues(job).Set(sourceNow.Sub(*watermark).Seconds())
+}
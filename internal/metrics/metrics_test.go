@@ -0,0 +1,31 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	watermark := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	metrics.SetWatermark("test-job", &watermark, watermark.Add(3*time.Hour))
+	metrics.SetWatermark("test-empty", nil, watermark)
+	metrics.ObserveQuery(metrics.DBTarget, "test_query")()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `datamart_etl_watermark_lag_seconds{job="test-job"} 10800`)
+	require.Contains(t, string(body), `datamart_etl_watermark_timestamp_seconds{job="test-job"} 1.792404e+09`)
+	require.Contains(t, string(body), `datamart_query_duration_seconds_count{db="target",query="test_query"} 1`)
+	require.NotContains(t, string(body), `test-empty`)
+}
@@ -0,0 +1,186 @@
+package mnpevent
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+var tracer = otel.Tracer("gitlab.services.mts.ru/salsa/mnp-datamart/internal/mnpevent")
+
+// RefreshFunc перезагружает заявки в витрине.
+type RefreshFunc func(ctx context.Context, ids []string) error
+
+type Config struct {
+	// Delay - тишина по заявке, после которой она обновляется. Каждое новое событие откладывает обновление.
+	Delay time.Duration
+	// MaxDelay - предельная задержка от первого события, чтобы частые события не откладывали обновление бесконечно.
+	MaxDelay time.Duration
+	// MaxBatch - сколько заявок обновляется за один вызов refresh.
+	MaxBatch int
+}
+
+type pending struct {
+	first, last time.Time
+}
+
+// Debouncer собирает события по order_id и обновляет заявку один раз после серии событий.
+// Если обновление не удалось (например, выполняется плановый запуск portin), заявки остаются в очереди
+// и повторяются через Delay.
+type Debouncer struct {
+	cfg     Config
+	refresh RefreshFunc
+	logger  *zap.Logger
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pending
+}
+
+func NewDebouncer(cfg Config, refresh RefreshFunc, logger *zap.Logger) *Debouncer {
+	if cfg.Delay <= 0 {
+		cfg.Delay = 30 * time.Second
+	}
+	if cfg.MaxDelay < cfg.Delay {
+		cfg.MaxDelay = 5 * cfg.Delay
+	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 500
+	}
+
+	return &Debouncer{
+		cfg:     cfg,
+		refresh: refresh,
+		logger:  logger.Named("mnp-event-refresh"),
+		now:     time.Now,
+		pending: make(map[string]pending),
+	}
+}
+
+// Handle ставит заявку события в очередь. Чужие и некорректные события пропускаются без ошибки,
+// чтобы не блокировать чтение топика.
+func (d *Debouncer) Handle(_ context.Context, ev *PortIn) error {
+	if ev == nil || ev.ProcessType != PortInProcessType {
+		metrics.EventsReceived.WithLabelValues(eventType(ev), "skipped").Inc()
+		return nil
+	}
+	orderID := strings.TrimSpace(ev.Data.OrderID)
+	if orderID == "" {
+		d.logger.Warn("mnp-event without order id", zap.String("event_id", ev.ID))
+		metrics.EventsReceived.WithLabelValues(ev.EventType, "invalid").Inc()
+		return nil
+	}
+	metrics.EventsReceived.WithLabelValues(ev.EventType, "queued").Inc()
+
+	d.add(orderID, d.now())
+
+	return nil
+}
+
+func (d *Debouncer) add(orderID string, at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p, ok := d.pending[orderID]
+	if !ok {
+		p.first = at
+	}
+	p.last = at
+	d.pending[orderID] = p
+}
+
+// Run обновляет созревшие заявки до отмены ctx.
+func (d *Debouncer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(max(d.cfg.Delay/4, 100*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.Flush(ctx)
+		}
+	}
+}
+
+// Flush обновляет заявки, по которым прошло Delay с последнего события или MaxDelay с первого.
+func (d *Debouncer) Flush(ctx context.Context) {
+	for {
+		ids := d.due(d.now())
+		if len(ids) == 0 {
+			return
+		}
+		if err := d.refreshBatch(ctx, ids); err != nil {
+			return
+		}
+	}
+}
+
+func (d *Debouncer) refreshBatch(ctx context.Context, ids []string) error {
+	ctx, span := tracer.Start(ctx, "Refresh")
+	defer span.End()
+
+	err := d.refresh(ctx, ids)
+	if err == nil {
+		metrics.EventRefreshes.WithLabelValues("success").Inc()
+		return nil
+	}
+
+	// Заявки возвращаются в очередь и повторяются через Delay.
+	at := d.now()
+	for _, id := range ids {
+		d.add(id, at)
+	}
+	if ctx.Err() == nil {
+		metrics.EventRefreshes.WithLabelValues("error").Inc()
+		d.logger.Warn("mnp-event refresh failed", zap.Int("orders", len(ids)), zap.Error(err))
+	}
+
+	return err
+}
+
+// due забирает из очереди созревшие заявки, не больше MaxBatch.
+func (d *Debouncer) due(now time.Time) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ids := make([]string, 0)
+	for _, id := range slices.Sorted(maps.Keys(d.pending)) {
+		p := d.pending[id]
+		if now.Sub(p.last) < d.cfg.Delay && now.Sub(p.first) < d.cfg.MaxDelay {
+			continue
+		}
+		ids = append(ids, id)
+		delete(d.pending, id)
+		if len(ids) == d.cfg.MaxBatch {
+			break
+		}
+	}
+
+	return ids
+}
+
+// Pending возвращает число заявок в очереди.
+func (d *Debouncer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.pending)
+}
+
+func eventType(ev *PortIn) string {
+	if ev == nil {
+		return ""
+	}
+
+	return ev.EventType
+}
@@ -0,0 +1,81 @@
+package mnpevent_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/mnpevent"
+)
+
+type refresher struct {
+	mu    sync.Mutex
+	calls [][]string
+	err   error
+}
+
+func (r *refresher) refresh(_ context.Context, ids []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, ids)
+
+	return r.err
+}
+
+func (r *refresher) snapshot() [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([][]string(nil), r.calls...)
+}
+
+func event(orderID string) *mnpevent.PortIn {
+	return &mnpevent.PortIn{ID: "e", EventType: mnpevent.CreatedEventType, ProcessType: mnpevent.PortInProcessType, Data: mnpevent.PortInData{OrderID: orderID}}
+}
+
+func TestDebouncerCoalescesEvents(t *testing.T) {
+	ctx := context.Background()
+	r := &refresher{}
+	d := mnpevent.NewDebouncer(mnpevent.Config{Delay: 50 * time.Millisecond, MaxDelay: time.Second}, r.refresh, zap.NewNop())
+
+	// portin-service передает в data.orderId order_number с префиксом.
+	require.NoError(t, d.Handle(ctx, event("pin2")))
+	require.NoError(t, d.Handle(ctx, event("pin1")))
+	require.NoError(t, d.Handle(ctx, event(" pin2 ")))
+	require.NoError(t, d.Handle(ctx, &mnpevent.PortIn{ProcessType: "portout", Data: mnpevent.PortInData{OrderID: "pin3"}}))
+	require.NoError(t, d.Handle(ctx, event("")))
+	require.Equal(t, 2, d.Pending())
+
+	d.Flush(ctx)
+	require.Empty(t, r.snapshot(), "refresh before the quiet period")
+
+	time.Sleep(60 * time.Millisecond)
+	d.Flush(ctx)
+	require.Equal(t, [][]string{{"pin1", "pin2"}}, r.snapshot())
+	require.Zero(t, d.Pending())
+}
+
+func TestDebouncerRequeuesFailedRefresh(t *testing.T) {
+	ctx := context.Background()
+	r := &refresher{err: errors.New("job is already running")}
+	d := mnpevent.NewDebouncer(mnpevent.Config{Delay: 20 * time.Millisecond}, r.refresh, zap.NewNop())
+
+	require.NoError(t, d.Handle(ctx, event("pin7")))
+	time.Sleep(30 * time.Millisecond)
+	d.Flush(ctx)
+	require.Len(t, r.snapshot(), 1)
+	require.Equal(t, 1, d.Pending())
+
+	r.mu.Lock()
+	r.err = nil
+	r.mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	d.Flush(ctx)
+	require.Equal(t, [][]string{{"pin7"}, {"pin7"}}, r.snapshot())
+	require.Zero(t, d.Pending())
+}
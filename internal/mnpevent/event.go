@@ -0,0 +1,23 @@
+package mnpevent
+
+// Типы событий portin-service, по которым обновляется витрина.
+const (
+	PortInProcessType       = "portin"
+	CreatedEventType        = "created"
+	DueDateChangedEventType = "duedate-changed"
+)
+
+// PortIn - событие portin-service в топике MNP event. Витрине нужен только ключ заявки,
+// остальные данные перечитываются из portin-orders-db.
+type PortIn struct {
+	ID          string     `json:"id"`
+	EventType   string     `json:"eventType"`
+	Date        string     `json:"date"`
+	ProcessType string     `json:"processType"`
+	Source      string     `json:"source"`
+	Data        PortInData `json:"data"`
+}
+
+type PortInData struct {
+	OrderID string `json:"orderId,omitempty"`
+}
@@ -0,0 +1,175 @@
+package model
+
+// Данные абонента, регион и лицевой счет маппинг в витрину пока не читает. Их типы разбираются нестрого
+// (decodeLenient): поле, значение которого не подходит по типу, остается пустым и попадает в Mismatched,
+// а заявка загружается без карантина.
+
+type PortationNumber struct {
+	Msisdn       string          `json:"msisdn"`
+	RN           string          `json:"rn,omitempty"`
+	TelcoAccount TelcoAccountRef `json:"telcoAccount"`
+	Status       *OrderState     `json:"status,omitempty"`
+}
+
+type TelcoAccountRef struct {
+	ID         *string  `json:"id,omitempty"`
+	Msisdn     *string  `json:"msisdn,omitempty"`
+	Mismatched []string `json:"-"`
+}
+
+func (v *TelcoAccountRef) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+type MnpDocumentRef struct {
+	ID           *string `json:"id,omitempty"`
+	DocumentDate string  `json:"documentDate,omitempty"`
+	ContractDate string  `json:"contractDate,omitempty"`
+	DocumentURL  string  `json:"documentUrl,omitempty"`
+}
+
+// Date возвращает дату договора независимо от версии схемы.
+func (r *MnpDocumentRef) Date() string {
+	if r.ContractDate != "" {
+		return r.ContractDate
+	}
+
+	return r.DocumentDate
+}
+
+type Person struct {
+	FirstName     string       `json:"firstName"`
+	LastName      string       `json:"lastName"`
+	MiddleName    *string      `json:"middleName,omitempty"`
+	LegalCategory *string      `json:"legalCategory,omitempty"`
+	Customer      *PartyRef    `json:"customer,omitempty"`
+	IDDocuments   []IDDocument `json:"idDocuments,omitempty"`
+	Numbers       []string     `json:"numbers,omitempty"`
+	Mismatched    []string     `json:"-"`
+}
+
+func (v *Person) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+type AuthorizedPerson struct {
+	FirstName  string  `json:"firstName"`
+	LastName   string  `json:"lastName"`
+	MiddleName *string `json:"middleName,omitempty"`
+	Position   *string `json:"position,omitempty"`
+}
+
+type Company struct {
+	Name             string            `json:"name"`
+	Inn              string            `json:"inn"`
+	Customer         *PartyRef         `json:"customer,omitempty"`
+	IDDocuments      []IDDocument      `json:"idDocuments,omitempty"`
+	Numbers          []string          `json:"numbers,omitempty"`
+	AuthorizedPerson *AuthorizedPerson `json:"authorizedPerson,omitempty"`
+	Mismatched       []string          `json:"-"`
+}
+
+func (v *Company) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+type Government struct {
+	Name             string            `json:"name"`
+	Inn              string            `json:"inn"`
+	Customer         *PartyRef         `json:"customer,omitempty"`
+	IDDocuments      []IDDocument      `json:"idDocuments,omitempty"`
+	TenderID         *string           `json:"tenderId,omitempty"`
+	TradingFloor     *string           `json:"tradingFloor,omitempty"`
+	ContractDueDate  *string           `json:"contractDueDate,omitempty"`
+	Numbers          []string          `json:"numbers,omitempty"`
+	AuthorizedPerson *AuthorizedPerson `json:"authorizedPerson,omitempty"`
+	Mismatched       []string          `json:"-"`
+}
+
+func (v *Government) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+type Individual struct {
+	FirstName     *string      `json:"firstName,omitempty"`
+	LastName      *string      `json:"lastName,omitempty"`
+	MiddleName    *string      `json:"middleName,omitempty"`
+	Inn           *string      `json:"inn,omitempty"`
+	LegalCategory *string      `json:"legalCategory,omitempty"`
+	Customer      *PartyRef    `json:"customer,omitempty"`
+	IDDocuments   []IDDocument `json:"idDocuments,omitempty"`
+	Numbers       []string     `json:"numbers,omitempty"`
+	Mismatched    []string     `json:"-"`
+}
+
+func (v *Individual) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+type PartyRef struct {
+	ID string `json:"id"`
+}
+
+type IDDocument struct {
+	DocName     *string `json:"docName,omitempty"`
+	DocSeries   *string `json:"docSeries,omitempty"`
+	DocNumber   string  `json:"docNumber"`
+	DocumentURL *string `json:"documentUrl,omitempty"`
+	// Для совместимости с текущим API
+	DocType *string `json:"docType,omitempty"`
+}
+
+type Operator struct {
+	Rn              string           `json:"rn"`
+	Mnc             *string          `json:"mnc,omitempty"`
+	Name            *string          `json:"name,omitempty"`
+	Region          *Region          `json:"region,omitempty"`
+	NetworkOperator *NetworkOperator `json:"networkOperator,omitempty"`
+	CdbCode         *string          `json:"cdbCode,omitempty"`
+}
+
+// CDBCode возвращает код оператора в БДПН, безопасно для nil.
+func (o *Operator) CDBCode() string {
+	if o == nil {
+		return ""
+	}
+
+	return ptrToStr(o.CdbCode)
+}
+
+type Region struct {
+	Code       string   `json:"code"`
+	Kladr      *string  `json:"kladr,omitempty"`
+	Name       *string  `json:"name,omitempty"`
+	Mismatched []string `json:"-"`
+}
+
+func (v *Region) UnmarshalJSON(data []byte) error {
+	v.Mismatched = decodeLenient(data, v)
+
+	return nil
+}
+
+// Ссылка на Network Operator в Telco.ROI.
+type NetworkOperator = string
+
+type OrderState struct {
+	Code       string  `json:"code"`
+	Message    *string `json:"message,omitempty"`
+	StatusDate *string `json:"statusDate,omitempty"`
+	Name       *string `json:"name,omitempty"`
+}
+
+func (s OrderState) MessageText() string {
+	return ptrToStr(s.Message)
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// decodeLenient разбирает JSON-объект data в структуру по указателю v по одному полю, как encoding/json:
+// сначала точное совпадение имени, затем без учета регистра. Возвращает JSON-имена полей, значение которых
+// не подошло по типу, такие поля остаются пустыми. Если data - не объект, возвращается [""].
+// Поля с тегом "-" не заполняются.
+func decodeLenient(data []byte, v any) []string {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return []string{""}
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	var mismatched []string
+	for i := range rt.NumField() {
+		f := rt.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		raw, ok := lookupRaw(object, name)
+		if !ok {
+			continue
+		}
+		field := rv.Field(i)
+		if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+			field.SetZero()
+			mismatched = append(mismatched, name)
+		}
+	}
+
+	return mismatched
+}
+
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+
+	return f.Name
+}
+
+func lookupRaw(object map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if raw, ok := object[name]; ok {
+		return raw, true
+	}
+	for k, raw := range object {
+		if strings.EqualFold(k, name) {
+			return raw, true
+		}
+	}
+
+	return nil, false
+}
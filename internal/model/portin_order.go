@@ -0,0 +1,52 @@
+package model
+
+// SchemaVersion - версия формата order_data, определяется по набору полей.
+type SchemaVersion int
+
+const (
+	SchemaVersionUnknown SchemaVersion = iota
+	// SchemaVersionV1 - дата договора в contract.documentDate.
+	SchemaVersionV1
+	// SchemaVersionV2 - дата договора в contract.contractDate.
+	SchemaVersionV2
+)
+
+// PortInOrderData - содержимое orders.order_data / orders_log.order_data_log в формате portin-service.
+// Даты хранятся строками: формат в источнике не гарантирован, разбор выполняется в transform.
+type PortInOrderData struct {
+	Source           *string           `json:"source,omitempty"`
+	DueDate          *string           `json:"dueDate,omitempty"`
+	Comment          *string           `json:"comment,omitempty"`
+	Donor            *Operator         `json:"donor,omitempty"`
+	Recipient        *Operator         `json:"recipient,omitempty"`
+	Person           *Person           `json:"person,omitempty"`
+	Company          *Company          `json:"company,omitempty"`
+	Government       *Government       `json:"government,omitempty"`
+	Individual       *Individual       `json:"individual,omitempty"`
+	Contract         MnpDocumentRef    `json:"contract"`
+	PortationNumbers []PortationNumber `json:"portationNumbers,omitempty"`
+	State            *OrderState       `json:"state,omitempty"`
+	Status           *OrderState       `json:"status,omitempty"`
+	ProcessType      *string           `json:"processType,omitempty"`
+}
+
+// OrderStatus возвращает статус заявки из status. state - отдельное поле источника и статус не подменяет.
+func (d *PortInOrderData) OrderStatus() OrderState {
+	if d.Status == nil {
+		return OrderState{}
+	}
+
+	return *d.Status
+}
+
+func (d *PortInOrderData) ProcessTypeName() string {
+	return ptrToStr(d.ProcessType)
+}
+
+func ptrToStr(val *string) string {
+	if val == nil {
+		return ""
+	}
+
+	return *val
+}
@@ -0,0 +1,56 @@
+package datamart
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Обертка над openapi ErrorResponse,
+// чтобы не пользоваться генеренными XXX400JSONResponse объектами
+// с фиксированным статус-кодом и убрать ветвление под каждый из таких типов.
+type errorResponseWrapper struct {
+	StatusCode int
+	Body       ErrorResponse
+}
+
+func (e *errorResponseWrapper) VisitSearchRequestsResponse(w http.ResponseWriter) error {
+	return e.write(w)
+}
+
+func (e *errorResponseWrapper) VisitGetRequestResponse(w http.ResponseWriter) error {
+	return e.write(w)
+}
+
+func (e *errorResponseWrapper) VisitGetRequestTimelineResponse(w http.ResponseWriter) error {
+	return e.write(w)
+}
+
+func (e *errorResponseWrapper) write(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.StatusCode)
+
+	return json.NewEncoder(w).Encode(e.Body)
+}
+
+func errorResponse(status int, code, message string) *errorResponseWrapper {
+	return &errorResponseWrapper{
+		StatusCode: status,
+		Body:       ErrorResponse{Code: &code, Message: &message},
+	}
+}
+
+func badRequest(message string) *errorResponseWrapper {
+	return errorResponse(http.StatusBadRequest, "BAD_REQUEST", message)
+}
+
+func notFound(message string) *errorResponseWrapper {
+	return errorResponse(http.StatusNotFound, "NOT_FOUND", message)
+}
+
+func (s *Server) internalError(err error) *errorResponseWrapper {
+	s.logger.Error("unexpected error occurred", zap.Error(err))
+
+	return errorResponse(http.StatusInternalServerError, "INTERNAL", "internal error")
+}
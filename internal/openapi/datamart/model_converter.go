@@ -0,0 +1,69 @@
+package datamart
+
+import "gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+
+func mapRequest(r target.Request) Request {
+	return Request{
+		OrderNumber:     r.OrderNumber,
+		OrderId:         r.OrderID,
+		RequestStatusId: r.RequestStatusID,
+		MessageCode:     strPtr(r.MessageCode),
+		RejectReason:    r.RejectReason,
+		RequestDate:     r.RequestDate,
+		ContractDate:    r.ContractDate,
+		PortDate:        r.PortDate,
+		FromDate:        r.FromDate,
+		ToDate:          r.ToDate,
+		CdbId:           strPtr(r.CDBID),
+		ProcessType:     strPtr(r.ProcessType),
+		PortType:        r.PortType,
+		SubscriberType:  strPtr(r.SubscriberType),
+	}
+}
+
+func mapRequests(rs []target.Request) []Request {
+	res := make([]Request, 0, len(rs))
+	for _, r := range rs {
+		res = append(res, mapRequest(r))
+	}
+
+	return res
+}
+
+func mapNumbers(ns []target.RequestNumber) []RequestNumber {
+	res := make([]RequestNumber, 0, len(ns))
+	for _, n := range ns {
+		res = append(res, RequestNumber{
+			Msisdn:      n.MSISDN,
+			RecipientId: strPtr(n.RecipientID),
+			Rn:          strPtr(n.RN),
+		})
+	}
+
+	return res
+}
+
+func mapMessages(rrs []target.RawRequest) []RawMessage {
+	res := make([]RawMessage, 0, len(rrs))
+	for _, rr := range rrs {
+		res = append(res, RawMessage{
+			Id:            rr.ID,
+			RequestTime:   rr.RequestTime,
+			OperationInfo: strPtr(rr.OperationInfo),
+			SystemSource:  strPtr(rr.SystemSource),
+			SystemDest:    strPtr(rr.SystemDest),
+			XmlMessage:    strPtr(rr.XMLMessage),
+		})
+	}
+
+	return res
+}
+
+// strPtr возвращает nil для пустой строки: в витрине пустое значение и NULL не различаются.
+func strPtr(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
@@ -0,0 +1,159 @@
+package datamart
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.5.0 -config spec/config.yaml spec/mnp-datamart.yaml
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// Store - чтение витрины. Источники API не использует.
+type Store interface {
+	RequestByOrderNumber(ctx context.Context, orderNumber string) (target.Request, error)
+	RequestVersions(ctx context.Context, orderNumber string) ([]target.Request, error)
+	RequestNumbers(ctx context.Context, reqIDs []string) (map[string][]target.RequestNumber, error)
+	RawRequestsByReqID(ctx context.Context, reqID string) ([]target.RawRequest, error)
+	SearchRequests(ctx context.Context, f target.RequestFilter) ([]target.Request, bool, error)
+}
+
+type Server struct {
+	store  Store
+	logger *zap.Logger
+}
+
+func NewServer(store Store, logger *zap.Logger) *Server {
+	return &Server{store: store, logger: logger.Named("http.datamart-api")}
+}
+
+// NewStrictHandler возвращает обработчик маршрутов спецификации. Ошибки параметров и ответов пишутся в виде ErrorResponse.
+func (s *Server) NewStrictHandler() http.Handler {
+	strict := NewStrictHandlerWithOptions(s, nil, StrictHTTPServerOptions{
+		RequestErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			_ = badRequest(err.Error()).write(w)
+		},
+		ResponseErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			_ = s.internalError(err).write(w)
+		},
+	})
+
+	return HandlerWithOptions(strict, ChiServerOptions{
+		ErrorHandlerFunc: func(w http.ResponseWriter, r *http.Request, err error) {
+			_ = badRequest(err.Error()).write(w)
+		},
+	})
+}
+
+func (s *Server) SearchRequests(ctx context.Context, req SearchRequestsRequestObject) (SearchRequestsResponseObject, error) {
+	f := target.RequestFilter{
+		StatusID: req.Params.Status,
+		From:     req.Params.From,
+		To:       req.Params.To,
+		Limit:    defaultLimit,
+	}
+	if req.Params.Msisdn != nil {
+		f.MSISDN = *req.Params.Msisdn
+	}
+	if req.Params.Limit != nil {
+		if *req.Params.Limit < 1 || *req.Params.Limit > maxLimit {
+			return badRequest("limit must be between 1 and 1000"), nil
+		}
+		f.Limit = *req.Params.Limit
+	}
+	if req.Params.Cursor != nil {
+		after, err := base64.RawURLEncoding.DecodeString(*req.Params.Cursor)
+		if err != nil {
+			return badRequest("invalid cursor"), nil
+		}
+		f.After = string(after)
+	}
+
+	rs, more, err := s.store.SearchRequests(ctx, f)
+	if err != nil {
+		return s.internalError(err), nil
+	}
+
+	page := RequestPage{Items: mapRequests(rs)}
+	if more {
+		cursor := base64.RawURLEncoding.EncodeToString([]byte(rs[len(rs)-1].OrderNumber))
+		page.NextCursor = &cursor
+	}
+
+	return SearchRequests200JSONResponse(page), nil
+}
+
+func (s *Server) GetRequest(ctx context.Context, req GetRequestRequestObject) (GetRequestResponseObject, error) {
+	r, err := s.store.RequestByOrderNumber(ctx, req.OrderNumber)
+	if errors.Is(err, target.ErrRequestNotFound) {
+		return notFound(err.Error()), nil
+	}
+	if err != nil {
+		return s.internalError(err), nil
+	}
+
+	versions, err := s.store.RequestVersions(ctx, r.OrderNumber)
+	if err != nil {
+		return s.internalError(err), nil
+	}
+	numbers, err := s.store.RequestNumbers(ctx, []string{r.OrderNumber})
+	if err != nil {
+		return s.internalError(err), nil
+	}
+	messages, err := s.store.RawRequestsByReqID(ctx, r.OrderNumber)
+	if err != nil {
+		return s.internalError(err), nil
+	}
+
+	return GetRequest200JSONResponse(RequestDetails{
+		Request:  mapRequest(r),
+		History:  mapRequests(versions),
+		Numbers:  mapNumbers(numbers[r.OrderNumber]),
+		Messages: mapMessages(messages),
+	}), nil
+}
+
+func (s *Server) GetRequestTimeline(ctx context.Context, req GetRequestTimelineRequestObject) (GetRequestTimelineResponseObject, error) {
+	r, err := s.store.RequestByOrderNumber(ctx, req.OrderNumber)
+	if errors.Is(err, target.ErrRequestNotFound) {
+		return notFound(err.Error()), nil
+	}
+	if err != nil {
+		return s.internalError(err), nil
+	}
+
+	versions, err := s.store.RequestVersions(ctx, r.OrderNumber)
+	if err != nil {
+		return s.internalError(err), nil
+	}
+	if len(versions) == 0 {
+		versions = []target.Request{r}
+	}
+	messages, err := s.store.RawRequestsByReqID(ctx, r.OrderNumber)
+	if err != nil {
+		return s.internalError(err), nil
+	}
+
+	return GetRequestTimeline200JSONResponse(buildTimeline(r.OrderNumber, versions, messages, time.Now())), nil
+}
+
+// SpecHandler отдает встроенную спецификацию API в JSON.
+func SpecHandler(w http.ResponseWriter, _ *http.Request) {
+	data, err := rawSpec()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,175 @@
+package datamart_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/openapi/datamart"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+type fakeStore struct {
+	requests []target.Request
+	versions []target.Request
+	messages []target.RawRequest
+	filters  []target.RequestFilter
+}
+
+func (f *fakeStore) RequestByOrderNumber(_ context.Context, orderNumber string) (target.Request, error) {
+	for _, r := range f.requests {
+		if r.OrderNumber == orderNumber {
+			return r, nil
+		}
+	}
+
+	return target.Request{}, target.ErrRequestNotFound
+}
+
+func (f *fakeStore) RequestVersions(context.Context, string) ([]target.Request, error) {
+	if f.versions != nil {
+		return f.versions, nil
+	}
+
+	return f.requests[:1], nil
+}
+
+func (f *fakeStore) RequestNumbers(_ context.Context, reqIDs []string) (map[string][]target.RequestNumber, error) {
+	return map[string][]target.RequestNumber{reqIDs[0]: {{ReqID: reqIDs[0], MSISDN: "79161234567", RN: "D2501"}}}, nil
+}
+
+func (f *fakeStore) RawRequestsByReqID(_ context.Context, reqID string) ([]target.RawRequest, error) {
+	if f.messages != nil {
+		return f.messages, nil
+	}
+
+	return []target.RawRequest{{ID: 5, ReqID: reqID, OperationInfo: "NPRequest"}}, nil
+}
+
+func (f *fakeStore) SearchRequests(_ context.Context, filter target.RequestFilter) ([]target.Request, bool, error) {
+	f.filters = append(f.filters, filter)
+	var res []target.Request
+	for _, r := range f.requests {
+		if r.OrderNumber > filter.After && len(res) < filter.Limit {
+			res = append(res, r)
+		}
+	}
+
+	return res, len(res) == filter.Limit, nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeStore) {
+	from := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	store := &fakeStore{requests: []target.Request{
+		{OrderNumber: "pin1", OrderID: "1", RequestStatusID: 3, FromDate: from, PortType: "portin", MessageCode: "NPRequest"},
+		{OrderNumber: "pin2", OrderID: "2", RequestStatusID: 11, FromDate: from, PortType: "portin"},
+		{OrderNumber: "pin3", OrderID: "3", RequestStatusID: 3, FromDate: from, PortType: "portin"},
+	}}
+	srv := httptest.NewServer(datamart.NewServer(store, zap.NewNop()).NewStrictHandler())
+	t.Cleanup(srv.Close)
+
+	return srv, store
+}
+
+func getJSON(t *testing.T, url string, wantStatus int, v any) {
+	resp, err := http.Get(url) //nolint:gosec,noctx
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, wantStatus, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+}
+
+func TestGetRequest(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	var details datamart.RequestDetails
+	getJSON(t, srv.URL+"/requests/pin1", http.StatusOK, &details)
+	require.Equal(t, "pin1", details.Request.OrderNumber)
+	require.Equal(t, "NPRequest", *details.Request.MessageCode)
+	require.Nil(t, details.Request.CdbId)
+	require.Len(t, details.History, 1)
+	require.Equal(t, "79161234567", details.Numbers[0].Msisdn)
+	require.EqualValues(t, 5, details.Messages[0].Id)
+
+	var errResp datamart.ErrorResponse
+	getJSON(t, srv.URL+"/requests/pin404", http.StatusNotFound, &errResp)
+	require.Equal(t, "NOT_FOUND", *errResp.Code)
+}
+
+func TestGetRequestTimeline(t *testing.T) {
+	srv, store := newTestServer(t)
+	t0 := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	reason := 7009
+	store.versions = []target.Request{
+		{OrderNumber: "pin1", RequestStatusID: 1, MessageCode: "NPRequest", FromDate: t0, ToDate: ptr(t0.Add(time.Minute))},
+		{OrderNumber: "pin1", RequestStatusID: 1, MessageCode: "NPRequest", FromDate: t0.Add(time.Minute), ToDate: ptr(t0.Add(time.Hour))},
+		{OrderNumber: "pin1", RequestStatusID: 3, MessageCode: "cdb-rejected", RejectReason: &reason, FromDate: t0.Add(time.Hour)},
+	}
+	store.messages = []target.RawRequest{
+		{ID: 1, RequestTime: t0.Add(time.Second), OperationInfo: "NP Request", SystemSource: "MNPHUB", SystemDest: "CDB"},
+		{
+			ID: 2, RequestTime: t0.Add(59 * time.Minute), OperationInfo: "NP Donor Reject", SystemSource: "CDB", SystemDest: "MNPHUB",
+			XMLMessage: `<NPMessages><PortMessages><PortMessage><StatusList><Status><Code>7009</Code></Status></StatusList></PortMessage></PortMessages></NPMessages>`,
+		},
+	}
+
+	var tl datamart.Timeline
+	getJSON(t, srv.URL+"/requests/pin1/timeline", http.StatusOK, &tl)
+	require.Equal(t, "pin1", tl.OrderNumber)
+	require.Len(t, tl.Events, 4)
+	require.Equal(t, datamart.Status, tl.Events[0].Kind)
+	require.Equal(t, datamart.Outgoing, tl.Events[1].Message.Direction)
+	require.Equal(t, datamart.Incoming, tl.Events[2].Message.Direction)
+	require.Equal(t, []int{7009}, tl.Events[2].Message.RejectCodes)
+	require.Equal(t, 7009, *tl.Events[3].Status.RejectReason)
+
+	require.Len(t, tl.Statuses, 2)
+	require.EqualValues(t, 3600, tl.Statuses[0].DurationSeconds)
+	require.Nil(t, tl.Statuses[1].To)
+	require.Positive(t, tl.Statuses[1].DurationSeconds)
+
+	var errResp datamart.ErrorResponse
+	getJSON(t, srv.URL+"/requests/pin404/timeline", http.StatusNotFound, &errResp)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSearchRequestsPagination(t *testing.T) {
+	srv, store := newTestServer(t)
+
+	var page datamart.RequestPage
+	getJSON(t, srv.URL+"/requests?limit=2&status=3&msisdn=79161234567&from=2026-10-01T00:00:00%2B03:00", http.StatusOK, &page)
+	require.Len(t, page.Items, 2)
+	require.NotNil(t, page.NextCursor)
+	require.Equal(t, "79161234567", store.filters[0].MSISDN)
+	require.Equal(t, 3, *store.filters[0].StatusID)
+	require.True(t, store.filters[0].From.Equal(time.Date(2026, 9, 30, 21, 0, 0, 0, time.UTC)))
+
+	var next datamart.RequestPage
+	getJSON(t, srv.URL+"/requests?limit=2&cursor="+*page.NextCursor, http.StatusOK, &next)
+	require.Equal(t, "pin2", store.filters[1].After)
+	require.Len(t, next.Items, 1)
+	require.Equal(t, "pin3", next.Items[0].OrderNumber)
+	require.Nil(t, next.NextCursor)
+
+	var errResp datamart.ErrorResponse
+	getJSON(t, srv.URL+"/requests?limit=0", http.StatusBadRequest, &errResp)
+	getJSON(t, srv.URL+"/requests?status=abc", http.StatusBadRequest, &errResp)
+	getJSON(t, srv.URL+"/requests?cursor=!!", http.StatusBadRequest, &errResp)
+}
+
+func TestEmbeddedSpec(t *testing.T) {
+	spec, err := datamart.GetSwagger()
+	require.NoError(t, err)
+	require.NoError(t, spec.Validate(context.Background()))
+	require.NotNil(t, spec.Paths.Find("/requests/{orderNumber}"))
+	require.NotNil(t, spec.Paths.Find("/requests/{orderNumber}/timeline"))
+}
@@ -0,0 +1,90 @@
+package datamart
+
+import (
+	"sort"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+// cdbSystem - значение system_source у входящих от БДПН сообщений mnp_raw_request.
+const cdbSystem = "CDB"
+
+// buildTimeline объединяет версии mnp_request_h и сообщения mnp_raw_request.
+// Версии без смены request_status_id и message_code не дают события статуса.
+// Текущий статус длится до now.
+func buildTimeline(orderNumber string, versions []target.Request, messages []target.RawRequest, now time.Time) Timeline {
+	tl := Timeline{
+		OrderNumber: orderNumber,
+		Events:      make([]TimelineEvent, 0, len(versions)+len(messages)),
+		Statuses:    make([]StatusInterval, 0, len(versions)),
+	}
+
+	for i, v := range versions {
+		if i > 0 && v.RequestStatusID == versions[i-1].RequestStatusID && v.MessageCode == versions[i-1].MessageCode {
+			continue
+		}
+		tl.Events = append(tl.Events, TimelineEvent{
+			Time: v.FromDate,
+			Kind: Status,
+			Status: &StatusTransition{
+				RequestStatusId: v.RequestStatusID,
+				MessageCode:     strPtr(v.MessageCode),
+				RejectReason:    v.RejectReason,
+			},
+		})
+		tl.Statuses = append(tl.Statuses, StatusInterval{
+			RequestStatusId: v.RequestStatusID,
+			MessageCode:     strPtr(v.MessageCode),
+			From:            v.FromDate,
+		})
+	}
+
+	for i := range tl.Statuses {
+		st := &tl.Statuses[i]
+		switch {
+		case i+1 < len(tl.Statuses):
+			to := tl.Statuses[i+1].From
+			st.To = &to
+		case len(versions) > 0 && versions[len(versions)-1].ToDate != nil:
+			st.To = versions[len(versions)-1].ToDate
+		}
+
+		end := now
+		if st.To != nil {
+			end = *st.To
+		}
+		st.DurationSeconds = int64(end.Sub(st.From) / time.Second)
+	}
+
+	for _, m := range messages {
+		direction := Outgoing
+		if m.SystemSource == cdbSystem {
+			direction = Incoming
+		}
+		status := transform.ParseCDBStatus(m.XMLMessage)
+		codes := status.Codes
+		if codes == nil {
+			codes = []int{}
+		}
+		tl.Events = append(tl.Events, TimelineEvent{
+			Time: m.RequestTime,
+			Kind: Message,
+			Message: &CdbMessage{
+				Id:            m.ID,
+				Direction:     direction,
+				MessageType:   strPtr(m.OperationInfo),
+				RejectCodes:   codes,
+				RejectComment: strPtr(status.RejectComment),
+			},
+		})
+	}
+
+	// При равном времени смена статуса идет раньше сообщения.
+	sort.SliceStable(tl.Events, func(i, j int) bool {
+		return tl.Events[i].Time.Before(tl.Events[j].Time)
+	})
+
+	return tl
+}
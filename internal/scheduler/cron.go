@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron - расписание в формате crontab из пяти полей: минута, час, день месяца, месяц, день недели.
+// Поддерживаются "*", списки "1,15", диапазоны "1-5" и шаги "*/10", "0-30/5".
+type Cron struct {
+	minute bits
+	hour   bits
+	dom    bits
+	month  bits
+	dow    bits
+	// domAny/dowAny - поле задано "*". По правилам cron при ограничении
+	// обоих полей дня достаточно совпадения любого из них.
+	domAny bool
+	dowAny bool
+	loc    *time.Location
+}
+
+type bits uint64
+
+func (b bits) has(v int) bool { return b&(1<<uint(v)) != 0 }
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseCron разбирает выражение cron. Время расписания вычисляется в зоне loc.
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron %q: expected %d fields, got %d", expr, len(cronFields), len(parts))
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var parsed [5]bits
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		parsed[i] = b
+	}
+
+	// Воскресенье допускается как 0 и как 7.
+	dow := parsed[4]
+	if dow.has(7) {
+		dow |= 1
+	}
+
+	return &Cron{
+		minute: parsed[0],
+		hour:   parsed[1],
+		dom:    parsed[2],
+		month:  parsed[3],
+		dow:    dow,
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+		loc:    loc,
+	}, nil
+}
+
+func parseCronField(expr string, f cronField) (bits, error) {
+	var res bits
+	for item := range strings.SplitSeq(expr, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			s, err := strconv.Atoi(stepExpr)
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepExpr)
+			}
+			step = s
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rangeExpr == "*":
+		case strings.Contains(rangeExpr, "-"):
+			loExpr, hiExpr, _ := strings.Cut(rangeExpr, "-")
+			var err error
+			if lo, err = parseCronValue(loExpr, f); err != nil {
+				return 0, err
+			}
+			if hi, err = parseCronValue(hiExpr, f); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", f.name, rangeExpr)
+			}
+		default:
+			v, err := parseCronValue(rangeExpr, f)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			res |= 1 << uint(v)
+		}
+	}
+
+	return res, nil
+}
+
+func parseCronValue(v string, f cronField) (int, error) {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s: value %q out of range %d-%d", f.name, v, f.min, f.max)
+	}
+
+	return n, nil
+}
+
+// Next возвращает ближайшее время срабатывания строго после after.
+// Если за пять лет совпадений нет (например, "0 0 31 2 *"), возвращается нулевое время.
+func (c *Cron) Next(after time.Time) time.Time {
+	t := after.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + 5
+
+	for t.Year() <= limit {
+		switch {
+		case !c.month.has(int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case !c.hour.has(t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+		case !c.minute.has(t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom.has(t.Day())
+	dowMatch := c.dow.has(int(t.Weekday()))
+
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Run выполняет run по расписанию spec до отмены ctx. Запуски не перекрываются:
+// следующий планируется только после завершения предыдущего.
+func Run(ctx context.Context, name string, spec Spec, logger *zap.Logger, run func(context.Context) error) {
+	logger = logger.With(zap.String("job", name))
+
+	if spec.RunOnStart {
+		execute(ctx, spec, logger, run)
+	}
+
+	planned := spec.Schedule.Next(time.Now())
+	for !planned.IsZero() {
+		timer := time.NewTimer(time.Until(planned) + spec.jitter())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		execute(ctx, spec, logger, run)
+
+		now := time.Now()
+		next := spec.NextRun(planned, now)
+		if missed := spec.Schedule.Next(planned); !missed.IsZero() && missed.Before(now) {
+			logger.Warn("scheduled run missed", zap.Time("missed_at", missed), zap.Time("next_run", next),
+				zap.String("policy", string(spec.MissedRunPolicy)))
+		}
+		planned = next
+	}
+
+	logger.Warn("schedule has no further runs")
+}
+
+func execute(ctx context.Context, spec Spec, logger *zap.Logger, run func(context.Context) error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	runCtx, cancel := ctx, context.CancelFunc(func() {})
+	if spec.MaxRunDuration > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, spec.MaxRunDuration)
+	}
+	defer cancel()
+
+	started := time.Now()
+	if err := run(runCtx); err != nil {
+		logger.Error("job execution failed", zap.Duration("duration", time.Since(started)), zap.Error(err))
+	}
+}
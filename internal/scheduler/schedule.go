@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+type Schedule interface {
+	// Next возвращает время следующего запуска после t или нулевое время, если запусков больше нет.
+	Next(t time.Time) time.Time
+}
+
+// Every - запуск с фиксированным интервалом от предыдущего планового запуска.
+type Every time.Duration
+
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+type MissedRunPolicy string
+
+const (
+	// MissedRunSkip - пропущенные запуски не выполняются, следующий запуск по расписанию.
+	MissedRunSkip MissedRunPolicy = "skip"
+	// MissedRunOnce - пропущенные запуски схлопываются в один немедленный запуск.
+	MissedRunOnce MissedRunPolicy = "run-once"
+)
+
+func ParseMissedRunPolicy(v string) (MissedRunPolicy, error) {
+	switch p := MissedRunPolicy(v); p {
+	case MissedRunSkip, MissedRunOnce:
+		return p, nil
+	case "":
+		return MissedRunSkip, nil
+	default:
+		return "", fmt.Errorf("unknown missed run policy: %q", v)
+	}
+}
+
+type Spec struct {
+	Schedule        Schedule
+	RunOnStart      bool
+	Jitter          time.Duration
+	MaxRunDuration  time.Duration
+	MissedRunPolicy MissedRunPolicy
+}
+
+// NextRun возвращает время запуска после завершения запуска, запланированного на planned.
+// Если следующий плановый запуск уже в прошлом, применяется MissedRunPolicy.
+func (s Spec) NextRun(planned, now time.Time) time.Time {
+	next := s.Schedule.Next(planned)
+	if next.IsZero() || !next.Before(now) {
+		return next
+	}
+	if s.MissedRunPolicy == MissedRunOnce {
+		return now
+	}
+
+	return s.Schedule.Next(now)
+}
+
+func (s Spec) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+
+	return rand.N(s.Jitter) //nolint:gosec // джиттер расписания не требует криптостойкости
+}
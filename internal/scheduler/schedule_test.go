@@ -0,0 +1,69 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/scheduler"
+)
+
+func TestCronNext(t *testing.T) {
+	msk, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	at := func(s string) time.Time {
+		ts, err := time.ParseInLocation(time.DateTime, s, msk)
+		require.NoError(t, err)
+		return ts
+	}
+
+	tests := []struct {
+		expr  string
+		after string
+		want  string
+	}{
+		{expr: "5 * * * *", after: "2026-10-19 10:04:59", want: "2026-10-19 10:05:00"},
+		{expr: "5 * * * *", after: "2026-10-19 10:05:00", want: "2026-10-19 11:05:00"},
+		{expr: "*/15 9-10 * * *", after: "2026-10-19 10:50:00", want: "2026-10-20 09:00:00"},
+		{expr: "0 3 * * 1-5", after: "2026-10-23 04:00:00", want: "2026-10-26 03:00:00"},
+		{expr: "0 0 1 * 0", after: "2026-10-19 00:00:00", want: "2026-10-25 00:00:00"},
+		{expr: "0 0 29 2 *", after: "2026-03-01 00:00:00", want: "2028-02-29 00:00:00"},
+		{expr: "30 23 31 12 7", after: "2026-12-30 00:00:00", want: "2026-12-31 23:30:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr+" after "+tt.after, func(t *testing.T) {
+			cron, err := scheduler.ParseCron(tt.expr, msk)
+			require.NoError(t, err)
+			require.Equal(t, at(tt.want), cron.Next(at(tt.after)))
+		})
+	}
+
+	impossible, err := scheduler.ParseCron("0 0 31 2 *", msk)
+	require.NoError(t, err)
+	require.True(t, impossible.Next(at("2026-01-01 00:00:00")).IsZero())
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := scheduler.ParseCron(expr, time.UTC)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestSpecNextRun(t *testing.T) {
+	base := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	spec := scheduler.Spec{Schedule: scheduler.Every(time.Hour)}
+
+	require.Equal(t, base.Add(time.Hour), spec.NextRun(base, base.Add(10*time.Minute)))
+
+	late := base.Add(150 * time.Minute)
+	require.Equal(t, late.Add(time.Hour), spec.NextRun(base, late))
+
+	spec.MissedRunPolicy = scheduler.MissedRunOnce
+	require.Equal(t, late, spec.NextRun(base, late))
+
+	_, err := scheduler.ParseMissedRunPolicy("catch-up")
+	require.Error(t, err)
+}
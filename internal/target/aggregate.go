@@ -0,0 +1,107 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// dayLayout - формат дня агрегатов (DATE).
+const dayLayout = "2006-01-02"
+
+// Общие выражения агрегатов: день заявки и неудаленные заявки этих дней с числом номеров и реципиентом.
+const (
+	aggDayExpr    = `COALESCE(r.request_date, r.from_date)::date`
+	aggNumbersCTE = `
+WITH req AS (
+  SELECT r.*, ` + aggDayExpr + ` AS business_day,
+    (SELECT min(n.recipient_id) FROM req_number n WHERE n.req_id = r.order_number) AS recipient_id,
+    (SELECT count(*) FROM req_number n WHERE n.req_id = r.order_number) AS phone_num_cnt
+  FROM mnp_request r
+  WHERE r.deleted = 0 AND ` + aggDayExpr + ` = ANY($1::date[])
+)`
+)
+
+// TouchedAggregateDays возвращает дни заявок, строки которых изменены в [from, to). from = nil - все дни.
+// Закрытие версии mnp_request_h меняет ее change_date, поэтому при смене request_date
+// в результат попадает и старый день заявки.
+func (s *Store) TouchedAggregateDays(ctx context.Context, from *time.Time, to time.Time) ([]string, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "touched_aggregate_days")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT DISTINCT business_day FROM (
+  SELECT `+aggDayExpr+` AS business_day FROM mnp_request r
+  WHERE ($1::timestamp IS NULL OR r.change_date >= $1) AND r.change_date < $2
+  UNION
+  SELECT `+aggDayExpr+` FROM mnp_request_h r
+  WHERE ($1::timestamp IS NULL OR r.change_date >= $1) AND r.change_date < $2
+  UNION
+  SELECT `+aggDayExpr+` FROM req_number n JOIN mnp_request r ON r.order_number = n.req_id
+  WHERE ($3::timestamptz IS NULL OR n.change_date >= $3) AND n.change_date < $4
+) d
+WHERE business_day IS NOT NULL
+ORDER BY business_day`, s.wallPtr(from), s.wall(to), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []string
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day.Format(dayLayout))
+	}
+
+	return days, rows.Err()
+}
+
+// RebuildAggregates пересчитывает агрегаты за дни days целиком по текущему состоянию mnp_request и req_number.
+func (s *Store) RebuildAggregates(ctx context.Context, tx *sql.Tx, days []string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "rebuild_aggregates")()
+
+	if len(days) == 0 {
+		return nil
+	}
+
+	for _, table := range []string{"agg_mnp_request", "agg_mnp_request_error", "agg_mnp_request_phone_num"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE business_day = ANY($1::date[])`, pq.Array(days)); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, aggNumbersCTE+`
+INSERT INTO agg_mnp_request (
+  business_day, request_status_id, process_type, subscriber_type, recipient_id, port_type, request_cnt, phone_num_cnt
+)
+SELECT business_day, request_status_id, process_type, subscriber_type, recipient_id, port_type, count(*), sum(phone_num_cnt)
+FROM req
+GROUP BY business_day, request_status_id, process_type, subscriber_type, recipient_id, port_type`, pq.Array(days))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, aggNumbersCTE+`
+INSERT INTO agg_mnp_request_error (business_day, reject_reason, request_status_id, message_code, process_type, request_cnt)
+SELECT business_day, reject_reason, request_status_id, message_code, process_type, count(*)
+FROM req
+WHERE reject_reason IS NOT NULL
+GROUP BY business_day, reject_reason, request_status_id, message_code, process_type`, pq.Array(days))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, aggNumbersCTE+`
+INSERT INTO agg_mnp_request_phone_num (business_day, request_status_id, phone_num_cnt, request_cnt)
+SELECT business_day, request_status_id, phone_num_cnt, count(*)
+FROM req
+GROUP BY business_day, request_status_id, phone_num_cnt`, pq.Array(days))
+
+	return err
+}
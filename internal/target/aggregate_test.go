@@ -0,0 +1,108 @@
+package target_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+func TestTouchedAggregateDays(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+	ctx := context.Background()
+
+	_, err := db.Exec(`
+INSERT INTO mnp_request (order_number, order_id, request_date, from_date, change_date) VALUES
+  ('pin1', '1', '2026-10-01 10:00', '2026-10-01 10:00', '2026-10-10 12:00'),
+  ('pin2', '2', NULL, '2026-10-02 09:00', '2026-10-10 13:00'),
+  ('pin3', '3', '2026-10-03 10:00', '2026-10-03 10:00', '2026-10-09 12:00'),
+  ('pin5', '5', '2026-10-05 10:00', '2026-10-05 10:00', '2026-10-10 14:00');
+INSERT INTO mnp_request_h (order_number, order_id, request_date, from_date, change_date) VALUES
+  ('pin4', '4', '2026-09-30 10:00', '2026-09-30 10:00', '2026-10-10 12:30');
+INSERT INTO req_number (req_id, msisdn, change_date) VALUES ('pin3', '79990000003', '2026-10-10 12:30+00');`)
+	require.NoError(t, err)
+
+	from := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 10, 14, 0, 0, 0, time.UTC)
+
+	// Окно [from, to): заявки, закрытые версии истории и номера; день без request_date берется из from_date.
+	days, err := store.TouchedAggregateDays(ctx, &from, to)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2026-09-30", "2026-10-01", "2026-10-02", "2026-10-03"}, days)
+
+	days, err = store.TouchedAggregateDays(ctx, nil, to.Add(time.Second))
+	require.NoError(t, err)
+	require.Equal(t, []string{"2026-09-30", "2026-10-01", "2026-10-02", "2026-10-03", "2026-10-05"}, days)
+}
+
+func TestRebuildAggregates(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+	ctx := context.Background()
+
+	_, err := db.Exec(`
+INSERT INTO mnp_request (order_number, order_id, request_status_id, request_date, process_type, subscriber_type,
+  message_code, reject_reason, deleted) VALUES
+  ('pin1', '1', 3, '2026-10-01 10:00', 'port', 'person', NULL, NULL, 0),
+  ('pin2', '2', 3, '2026-10-01 11:00', 'port', 'person', 'E1', 5, 0),
+  ('pin3', '3', 3, '2026-10-01 12:00', 'port', 'person', NULL, NULL, 1),
+  ('pin4', '4', 3, '2026-10-02 12:00', 'port', 'person', NULL, NULL, 0);
+INSERT INTO req_number (req_id, recipient_id, msisdn) VALUES
+  ('pin1', 'R1', '79990000001'), ('pin1', 'R1', '79990000011'), ('pin2', 'R1', '79990000002');
+INSERT INTO agg_mnp_request (business_day, request_status_id, port_type, request_cnt, phone_num_cnt) VALUES
+  ('2026-10-01', 1, 'portin', 100, 100),
+  ('2026-09-01', 1, 'portin', 7, 7);`)
+	require.NoError(t, err)
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, store.RebuildAggregates(ctx, tx, []string{"2026-10-01"}))
+	require.NoError(t, tx.Commit())
+
+	type aggRow struct {
+		day                     string
+		status                  int
+		recipient               string
+		requestCnt, phoneNumCnt int
+	}
+	rows, err := db.Query(`
+SELECT business_day::text, request_status_id, coalesce(recipient_id, ''), request_cnt, phone_num_cnt
+FROM agg_mnp_request ORDER BY business_day, request_status_id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var got []aggRow
+	for rows.Next() {
+		var r aggRow
+		require.NoError(t, rows.Scan(&r.day, &r.status, &r.recipient, &r.requestCnt, &r.phoneNumCnt))
+		got = append(got, r)
+	}
+	require.NoError(t, rows.Err())
+	// День пересчитан целиком без удаленной заявки, другие дни не тронуты, 2026-10-02 не запрашивался.
+	require.Equal(t, []aggRow{
+		{day: "2026-09-01", status: 1, requestCnt: 7, phoneNumCnt: 7},
+		{day: "2026-10-01", status: 3, recipient: "R1", requestCnt: 2, phoneNumCnt: 3},
+	}, got)
+
+	var reason, errCnt int
+	var code string
+	require.NoError(t, db.QueryRow(`
+SELECT reject_reason, message_code, request_cnt FROM agg_mnp_request_error WHERE business_day = '2026-10-01'`).
+		Scan(&reason, &code, &errCnt))
+	require.Equal(t, 5, reason)
+	require.Equal(t, "E1", code)
+	require.Equal(t, 1, errCnt)
+
+	phoneNums := map[int]int{}
+	pnRows, err := db.Query(`SELECT phone_num_cnt, request_cnt FROM agg_mnp_request_phone_num WHERE business_day = '2026-10-01'`)
+	require.NoError(t, err)
+	defer pnRows.Close()
+	for pnRows.Next() {
+		var phones, cnt int
+		require.NoError(t, pnRows.Scan(&phones, &cnt))
+		phoneNums[phones] = cnt
+	}
+	require.NoError(t, pnRows.Err())
+	require.Equal(t, map[int]int{1: 1, 2: 1}, phoneNums)
+}
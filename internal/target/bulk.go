@@ -0,0 +1,296 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// BulkMinRows - с этого размера пачки строки пишутся через COPY во временную staging-таблицу
+// и сливаются одним INSERT ... ON CONFLICT. Пачки меньше пишутся построчно.
+const BulkMinRows = 100
+
+var (
+	requestCopyColumns = []string{
+		"order_number", "request_status_id", "request_date", "contract_date", "port_date", "from_date", "to_date",
+		"cdb_id", "process_type", "port_type", "subscriber_type", "message_code", "reject_reason", "order_id",
+	}
+	// portInCopyColumns - колонки заявок MNPHUB: requestCopyColumns и источник.
+	portInCopyColumns     = append(append([]string{}, requestCopyColumns...), "source")
+	reqNumberCopyColumns  = []string{"req_id", "recipient_id", "msisdn", "rn"}
+	rawRequestCopyColumns = []string{"id", "req_id", "request_time", "xml_message", "operation_info", "system_source", "system_dest"}
+	// messageCopyColumns - колонки сообщений MNPHUB: rawRequestCopyColumns и источник.
+	messageCopyColumns = append(append([]string{}, rawRequestCopyColumns...), "source")
+)
+
+// UpsertRequests - пакетный UpsertRequest. При повторе order_number в пачке побеждает последняя строка.
+func (s *Store) UpsertRequests(ctx context.Context, tx *sql.Tx, rs []Request) error {
+	if len(rs) < BulkMinRows {
+		for _, r := range rs {
+			if err := s.UpsertRequest(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	rs = lastByKey(rs, func(r Request) string { return r.OrderNumber })
+
+	defer metrics.ObserveQuery(metrics.DBTarget, "bulk_upsert_request")()
+
+	if err := s.stageRequests(ctx, tx, "stage_mnp_request", "mnp_request", rs); err != nil {
+		return err
+	}
+
+	return s.mergeRequests(ctx, tx)
+}
+
+func (s *Store) mergeRequests(ctx context.Context, tx *sql.Tx) error {
+	cols := strings.Join(portInCopyColumns, ", ")
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO mnp_request (`+cols+`, change_date, deleted)
+SELECT `+cols+`, timezone($1, now()), 0 FROM stage_mnp_request
+ON CONFLICT (order_number)
+DO UPDATE SET`+requestUpsertSet, s.loc.String())
+
+	return err
+}
+
+// InsertRequestHistories - пакетный InsertRequestHistory.
+func (s *Store) InsertRequestHistories(ctx context.Context, tx *sql.Tx, rs []Request) error {
+	if len(rs) < BulkMinRows {
+		for _, r := range rs {
+			if err := s.InsertRequestHistory(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	defer metrics.ObserveQuery(metrics.DBTarget, "bulk_insert_request_history")()
+
+	if err := s.stageRequests(ctx, tx, "stage_mnp_request_h", "mnp_request_h", lastByKey(rs, versionKey)); err != nil {
+		return err
+	}
+
+	return s.mergeRequestHistory(ctx, tx)
+}
+
+// UpsertOpenRequestVersions - пакетный UpsertOpenRequestVersion. На заявку пишется одна текущая версия.
+func (s *Store) UpsertOpenRequestVersions(ctx context.Context, tx *sql.Tx, rs []Request) error {
+	if len(rs) < BulkMinRows {
+		for _, r := range rs {
+			if err := s.UpsertOpenRequestVersion(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	open := make([]Request, 0, len(rs))
+	for _, r := range lastByKey(rs, func(r Request) string { return r.Source + "/" + r.OrderID }) {
+		r.ToDate = nil
+		open = append(open, r)
+	}
+
+	defer metrics.ObserveQuery(metrics.DBTarget, "bulk_upsert_open_request_version")()
+
+	if err := s.stageRequests(ctx, tx, "stage_mnp_request_h", "mnp_request_h", open); err != nil {
+		return err
+	}
+
+	return s.mergeOpenRequestVersions(ctx, tx)
+}
+
+// mergeOpenRequestVersions закрывает текущие версии заявок stage_mnp_request_h секундой до новой версии
+// и записывает новые версии.
+func (s *Store) mergeOpenRequestVersions(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+UPDATE mnp_request_h h
+SET to_date = st.from_date - interval '1 second', change_date = timezone($1, now())
+FROM stage_mnp_request_h st
+WHERE h.source = st.source AND h.order_id = st.order_id AND h.to_date is null AND h.from_date < st.from_date`, s.loc.String())
+	if err != nil {
+		return err
+	}
+
+	return s.mergeRequestHistory(ctx, tx)
+}
+
+func (s *Store) mergeRequestHistory(ctx context.Context, tx *sql.Tx) error {
+	cols := strings.Join(portInCopyColumns, ", ")
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO mnp_request_h (`+cols+`, change_date, deleted)
+SELECT `+cols+`, timezone($1, now()), 0 FROM stage_mnp_request_h
+ON CONFLICT (source, order_id, from_date)
+DO UPDATE SET`+requestHistoryUpsertSet, s.loc.String())
+
+	return err
+}
+
+// UpsertReqNumbers - пакетный UpsertReqNumber.
+func (s *Store) UpsertReqNumbers(ctx context.Context, tx *sql.Tx, ns []RequestNumber) error {
+	if len(ns) < BulkMinRows {
+		for _, n := range ns {
+			if err := s.UpsertReqNumber(ctx, tx, n); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	ns = lastByKey(ns, func(n RequestNumber) string { return n.ReqID + "/" + n.MSISDN })
+
+	defer metrics.ObserveQuery(metrics.DBTarget, "bulk_upsert_req_number")()
+
+	rows := make([][]any, 0, len(ns))
+	for _, n := range ns {
+		rows = append(rows, reqNumberRow(n))
+	}
+	if err := copyToStage(ctx, tx, "stage_req_number", "req_number", reqNumberCopyColumns, rows); err != nil {
+		return err
+	}
+
+	return mergeReqNumbers(ctx, tx)
+}
+
+func mergeReqNumbers(ctx context.Context, tx *sql.Tx) error {
+	cols := strings.Join(reqNumberCopyColumns, ", ")
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO req_number(`+cols+`, change_date)
+SELECT `+cols+`, now() FROM stage_req_number
+ON CONFLICT (req_id, msisdn)
+DO UPDATE SET`+reqNumberUpsertSet)
+
+	return err
+}
+
+// UpsertRawRequests - пакетный UpsertRawRequest.
+func (s *Store) UpsertRawRequests(ctx context.Context, tx *sql.Tx, rrs []RawRequest) error {
+	if len(rrs) < BulkMinRows {
+		for _, rr := range rrs {
+			if err := s.UpsertRawRequest(ctx, tx, rr); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	type messageKey struct {
+		source string
+		id     int64
+	}
+	rrs = lastByKey(rrs, func(rr RawRequest) messageKey { return messageKey{rr.Source, rr.ID} })
+
+	defer metrics.ObserveQuery(metrics.DBTarget, "bulk_upsert_raw_request")()
+
+	rows := make([][]any, 0, len(rrs))
+	for _, rr := range rrs {
+		rows = append(rows, []any{rr.ID, rr.ReqID, s.wall(rr.RequestTime), rr.XMLMessage, rr.OperationInfo, rr.SystemSource, rr.SystemDest, rr.Source})
+	}
+	if err := copyToStage(ctx, tx, "stage_mnp_raw_request", "mnp_raw_request", messageCopyColumns, rows); err != nil {
+		return err
+	}
+	cols := strings.Join(messageCopyColumns, ", ")
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO mnp_raw_request(`+cols+`, change_date)
+SELECT `+cols+`, now() FROM stage_mnp_raw_request
+ON CONFLICT (source, id)
+DO UPDATE SET`+rawRequestUpsertSet)
+
+	return err
+}
+
+func (s *Store) stageRequests(ctx context.Context, tx *sql.Tx, stage, like string, rs []Request) error {
+	rows := make([][]any, 0, len(rs))
+	for _, r := range rs {
+		rows = append(rows, s.requestRow(r))
+	}
+
+	return copyToStage(ctx, tx, stage, like, portInCopyColumns, rows)
+}
+
+// requestRow - значения portInCopyColumns заявки.
+func (s *Store) requestRow(r Request) []any {
+	return []any{
+		r.OrderNumber, r.RequestStatusID, s.wallPtr(r.RequestDate), s.wallPtr(r.ContractDate), s.wallPtr(r.PortDate),
+		s.wall(r.FromDate), s.wallPtr(r.ToDate), r.CDBID, r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode,
+		r.RejectReason, r.OrderID, r.Source,
+	}
+}
+
+// copyToStage загружает rows через COPY во временную таблицу stage с колонками columns таблицы like.
+// Таблица живет до конца транзакции и очищается перед каждой пачкой.
+func copyToStage(ctx context.Context, tx *sql.Tx, stage, like string, columns []string, rows [][]any) error {
+	if err := createStage(ctx, tx, stage, like, columns); err != nil {
+		return err
+	}
+
+	return copyRows(ctx, tx, stage, columns, rows)
+}
+
+// createStage создает пустую временную таблицу stage с колонками columns таблицы like.
+func createStage(ctx context.Context, tx *sql.Tx, stage, like string, columns []string) error {
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TEMP TABLE IF NOT EXISTS %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA`,
+		stage, strings.Join(columns, ", "), like))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `TRUNCATE `+stage)
+
+	return err
+}
+
+func copyRows(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]any) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		_ = stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
+}
+
+// reqNumberRow - значения reqNumberCopyColumns номера заявки.
+func reqNumberRow(n RequestNumber) []any {
+	return []any{n.ReqID, nullIfEmpty(n.RecipientID), n.MSISDN, nullIfEmpty(n.RN)}
+}
+
+func versionKey(r Request) string {
+	return r.Source + "/" + r.OrderID + "@" + r.FromDate.UTC().String()
+}
+
+// lastByKey убирает повторы ключа, оставляя последнюю строку: ON CONFLICT не может обновить строку дважды.
+func lastByKey[T any, K comparable](rows []T, key func(T) K) []T {
+	last := make(map[K]int, len(rows))
+	for i, r := range rows {
+		last[key(r)] = i
+	}
+	if len(last) == len(rows) {
+		return rows
+	}
+
+	res := make([]T, 0, len(last))
+	for i, r := range rows {
+		if last[key(r)] == i {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,118 @@
+package target_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+func TestLastByKey(t *testing.T) {
+	type row struct {
+		key string
+		val int
+	}
+	key := func(r row) string { return r.key }
+
+	for _, tc := range []struct {
+		name string
+		rows []row
+		want []row
+	}{
+		{name: "empty"},
+		{name: "no repeats", rows: []row{{"a", 1}, {"b", 2}}, want: []row{{"a", 1}, {"b", 2}}},
+		{
+			name: "last wins in place of last occurrence",
+			rows: []row{{"a", 1}, {"b", 2}, {"a", 3}, {"c", 4}, {"b", 5}},
+			want: []row{{"a", 3}, {"c", 4}, {"b", 5}},
+		},
+		{name: "single key", rows: []row{{"a", 1}, {"a", 2}, {"a", 3}}, want: []row{{"a", 3}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, target.LastByKey(tc.rows, key))
+		})
+	}
+}
+
+// tableRows возвращает строки таблицы без суррогатного id и времени записи.
+func tableRows(t *testing.T, db *sql.DB, table string) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT (to_jsonb(r) - 'id' - 'change_date')::text FROM ` + table + ` r ORDER BY 1`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var res []string
+	for rows.Next() {
+		var r string
+		require.NoError(t, rows.Scan(&r))
+		res = append(res, r)
+	}
+	require.NoError(t, rows.Err())
+
+	return res
+}
+
+func TestBulkMatchesPerRow(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Hour)
+	closed := t1.Add(-time.Second)
+	reason := 3
+
+	// Заявка 0 повторяется в пачке: побеждать должна последняя строка.
+	var requests, versions []target.Request
+	for i := range target.BulkMinRows {
+		r := portInRequest(fmt.Sprintf("%d", i), 1, t0, nil)
+		r.ProcessType = "ShortTimePort"
+		requests = append(requests, r)
+		versions = append(versions, portInRequest(r.OrderID, 1, t0.Add(-time.Hour), &closed))
+	}
+	repeat := portInRequest("0", 5, t1, nil)
+	repeat.RejectReason = &reason
+	requests = append(requests, repeat)
+	versions = append(versions, portInRequest("0", 2, t0.Add(-time.Hour), &closed))
+
+	write := func(db *sql.DB, fn func(ctx context.Context, tx *sql.Tx) error) {
+		t.Helper()
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, fn(ctx, tx))
+		require.NoError(t, tx.Commit())
+	}
+
+	bulkDB, bulk := targettest.NewStore(t, time.UTC)
+	write(bulkDB, func(ctx context.Context, tx *sql.Tx) error {
+		if err := bulk.UpsertRequests(ctx, tx, requests); err != nil {
+			return err
+		}
+		return bulk.InsertRequestHistories(ctx, tx, versions)
+	})
+
+	rowDB, perRow := targettest.NewStore(t, time.UTC)
+	write(rowDB, func(ctx context.Context, tx *sql.Tx) error {
+		for _, r := range requests {
+			if err := perRow.UpsertRequest(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+		for _, r := range versions {
+			if err := perRow.InsertRequestHistory(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for _, table := range []string{"mnp_request", "mnp_request_h"} {
+		want := tableRows(t, rowDB, table)
+		require.Len(t, want, target.BulkMinRows, table)
+		require.Equal(t, want, tableRows(t, bulkDB, table), table)
+	}
+}
@@ -0,0 +1,35 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// CDCPosition возвращает сохраненную позицию слота в текстовом виде pg_lsn, пусто - слот еще не читался.
+func (s *Store) CDCPosition(ctx context.Context, slot string) (string, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "cdc_position")()
+
+	var lsn string
+	err := s.db.QueryRowContext(ctx, `SELECT lsn::text FROM cdc_position WHERE slot_name = $1`, slot).Scan(&lsn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return lsn, err
+}
+
+// SaveCDCPosition сохраняет позицию слота в транзакции, в которой применены изменения.
+func (s *Store) SaveCDCPosition(ctx context.Context, tx *sql.Tx, job, slot, lsn string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_cdc_position")()
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO cdc_position(slot_name, job, lsn, updated_at)
+VALUES ($1,$2,$3::pg_lsn,now())
+ON CONFLICT (slot_name)
+DO UPDATE SET job = EXCLUDED.job, lsn = EXCLUDED.lsn, updated_at = now()`, slot, job, lsn)
+
+	return err
+}
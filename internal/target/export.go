@@ -0,0 +1,275 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// ColumnKind - тип колонки в файлах выгрузки.
+type ColumnKind int
+
+const (
+	ColumnInt64 ColumnKind = iota
+	ColumnInt32
+	ColumnString
+	// ColumnTimestamp - TIMESTAMP в бизнес-зоне, выгружается как есть, без смещения.
+	ColumnTimestamp
+	// ColumnTimestampTZ - TIMESTAMPTZ, выгружается в UTC.
+	ColumnTimestampTZ
+)
+
+type ExportColumn struct {
+	Name string
+	Kind ColumnKind
+}
+
+// ExportTable - таблица витрины, выгружаемая в файлы по ключу (change_date, id).
+type ExportTable struct {
+	Name    string
+	Columns []ExportColumn
+}
+
+// changeDateWall - change_date хранится в бизнес-зоне (TIMESTAMP).
+func (t ExportTable) changeDateWall() bool {
+	for _, c := range t.Columns {
+		if c.Name == "change_date" {
+			return c.Kind == ColumnTimestamp
+		}
+	}
+
+	return false
+}
+
+var requestExportColumns = []ExportColumn{
+	{"id", ColumnInt64},
+	{"order_number", ColumnString},
+	{"request_status_id", ColumnInt32},
+	{"request_date", ColumnTimestamp},
+	{"contract_date", ColumnTimestamp},
+	{"port_date", ColumnTimestamp},
+	{"from_date", ColumnTimestamp},
+	{"to_date", ColumnTimestamp},
+	{"change_date", ColumnTimestamp},
+	{"deleted", ColumnInt32},
+	{"cdb_id", ColumnString},
+	{"process_type", ColumnString},
+	{"port_type", ColumnString},
+	{"subscriber_type", ColumnString},
+	{"message_code", ColumnString},
+	{"reject_reason", ColumnInt32},
+	{"order_id", ColumnString},
+	{"source", ColumnString},
+}
+
+// ExportTables - таблицы витрины, доступные для выгрузки в файлы.
+var ExportTables = []ExportTable{
+	{Name: "mnp_request", Columns: requestExportColumns},
+	{Name: "mnp_request_h", Columns: requestExportColumns},
+	{Name: "req_number", Columns: []ExportColumn{
+		{"id", ColumnInt64},
+		{"req_id", ColumnString},
+		{"recipient_id", ColumnString},
+		{"msisdn", ColumnString},
+		{"rn", ColumnString},
+		{"change_date", ColumnTimestampTZ},
+	}},
+	{Name: "mnp_raw_request", Columns: []ExportColumn{
+		{"id", ColumnInt64},
+		{"req_id", ColumnString},
+		{"request_time", ColumnTimestamp},
+		{"xml_message", ColumnString},
+		{"operation_info", ColumnString},
+		{"system_source", ColumnString},
+		{"system_dest", ColumnString},
+		{"change_date", ColumnTimestampTZ},
+		{"source", ColumnString},
+	}},
+}
+
+// ExportTableByName ищет таблицу среди ExportTables.
+func ExportTableByName(name string) (ExportTable, bool) {
+	for _, t := range ExportTables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return ExportTable{}, false
+}
+
+// ExportOffset - ключ последней выгруженной потребителю строки таблицы.
+// ChangeDate - момент времени независимо от типа колонки change_date.
+type ExportOffset struct {
+	Table      string
+	ChangeDate time.Time
+	ID         int64
+	// ExportID - имя выгрузки, в которую попала строка.
+	ExportID string
+}
+
+// ExportOffsets возвращает ключи последних выгруженных потребителю строк по таблицам.
+func (s *Store) ExportOffsets(ctx context.Context, consumer string) (map[string]ExportOffset, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "export_offsets")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT table_name, change_date, id, export_id FROM export_offset WHERE consumer = $1`, consumer)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]ExportOffset)
+	for rows.Next() {
+		var o ExportOffset
+		if err := rows.Scan(&o.Table, &o.ChangeDate, &o.ID, &o.ExportID); err != nil {
+			return nil, err
+		}
+		res[o.Table] = o
+	}
+
+	return res, rows.Err()
+}
+
+// SaveExportOffsets сохраняет ключи после того, как файлы выгрузки записаны.
+func (s *Store) SaveExportOffsets(ctx context.Context, consumer string, offsets []ExportOffset) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_export_offsets")()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	for _, o := range offsets {
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO export_offset(consumer, table_name, change_date, id, export_id, updated_at)
+VALUES ($1,$2,$3,$4,$5,now())
+ON CONFLICT (consumer, table_name)
+DO UPDATE SET change_date = EXCLUDED.change_date, id = EXCLUDED.id, export_id = EXCLUDED.export_id, updated_at = now()`,
+			consumer, o.Table, o.ChangeDate, o.ID, o.ExportID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ExportRows читает строки таблицы с ключом (change_date, id) больше after (nil - с начала)
+// и change_date раньше until в порядке ключа. Значения строки передаются в fn в порядке t.Columns:
+// nil, int64, int32, string или time.Time. Колонки ColumnTimestamp читаются без перевода из бизнес-зоны.
+// Возвращает ключ последней строки или nil, если строк не было.
+func (s *Store) ExportRows(
+	ctx context.Context,
+	t ExportTable,
+	after *ExportOffset,
+	until time.Time,
+	fn func(row []any) error,
+) (*ExportOffset, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "export_"+t.Name)()
+
+	bound := func(ts time.Time) time.Time {
+		if t.changeDateWall() {
+			return s.wall(ts)
+		}
+
+		return ts
+	}
+
+	names := make([]string, 0, len(t.Columns))
+	changeIdx, idIdx := -1, -1
+	for i, c := range t.Columns {
+		names = append(names, c.Name)
+		switch c.Name {
+		case "change_date":
+			changeIdx = i
+		case "id":
+			idIdx = i
+		}
+	}
+	if changeIdx < 0 || idIdx < 0 {
+		return nil, fmt.Errorf("export table %s has no change_date or id column", t.Name)
+	}
+
+	query := `SELECT ` + strings.Join(names, ", ") + ` FROM ` + t.Name + ` WHERE change_date < $1`
+	args := []any{bound(until)}
+	if after != nil {
+		query += ` AND (change_date, id) > ($2, $3)`
+		args = append(args, bound(after.ChangeDate), after.ID)
+	}
+	query += ` ORDER BY change_date, id`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dest := make([]any, len(t.Columns))
+	for i, c := range t.Columns {
+		switch c.Kind {
+		case ColumnInt64:
+			dest[i] = new(sql.NullInt64)
+		case ColumnInt32:
+			dest[i] = new(sql.NullInt32)
+		case ColumnString:
+			dest[i] = new(sql.NullString)
+		case ColumnTimestamp, ColumnTimestampTZ:
+			dest[i] = new(sql.NullTime)
+		}
+	}
+
+	var last *ExportOffset
+	row := make([]any, len(t.Columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i, d := range dest {
+			row[i] = exportValue(d)
+		}
+
+		changeDate := row[changeIdx].(time.Time)
+		if t.changeDateWall() {
+			changeDate = FromWallClock(changeDate, s.loc)
+		}
+		last = &ExportOffset{Table: t.Name, ChangeDate: changeDate, ID: row[idIdx].(int64)}
+
+		if err := fn(row); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
+
+func exportValue(d any) any {
+	switch v := d.(type) {
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+	case *sql.NullInt32:
+		if v.Valid {
+			return v.Int32
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	case *sql.NullTime:
+		if v.Valid {
+			return v.Time
+		}
+	}
+
+	return nil
+}
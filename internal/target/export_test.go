@@ -0,0 +1,6 @@
+package target
+
+// LastByKey открывает lastByKey для тестов.
+func LastByKey[T any, K comparable](rows []T, key func(T) K) []T {
+	return lastByKey(rows, key)
+}
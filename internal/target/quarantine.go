@@ -0,0 +1,195 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+var ErrQuarantineNotFound = errors.New("quarantine record not found")
+
+type QuarantineRecord struct {
+	ID          int64      `json:"id"`
+	Job         string     `json:"job"`
+	SourceTable string     `json:"sourceTable"`
+	SourceKey   string     `json:"sourceKey"`
+	VersionDate time.Time  `json:"versionDate"`
+	Error       string     `json:"error"`
+	Payload     string     `json:"payload,omitempty"`
+	FirstSeen   time.Time  `json:"firstSeen"`
+	LastSeen    time.Time  `json:"lastSeen"`
+	Attempts    int        `json:"attempts"`
+	DismissedAt *time.Time `json:"dismissedAt,omitempty"`
+}
+
+// QuarantineKey - версия строки источника, как она записывается в etl_quarantine.
+type QuarantineKey struct {
+	SourceKey   string
+	VersionDate time.Time
+}
+
+type QuarantineFilter struct {
+	Job              string
+	IncludeDismissed bool
+	Limit            int
+}
+
+func (s *Store) Quarantine(ctx context.Context, tx *sql.Tx, r QuarantineRecord) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "quarantine")()
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO etl_quarantine(job, source_table, source_key, version_date, error, payload, first_seen, last_seen, attempts)
+VALUES ($1,$2,$3,$4,$5,$6,now(),now(),1)
+ON CONFLICT (job, source_table, source_key, version_date)
+DO UPDATE SET
+  error = EXCLUDED.error,
+  payload = EXCLUDED.payload,
+  last_seen = now(),
+  attempts = etl_quarantine.attempts + 1
+`, r.Job, r.SourceTable, r.SourceKey, r.VersionDate, r.Error, nullIfEmpty(r.Payload))
+
+	return err
+}
+
+func (s *Store) ListQuarantine(ctx context.Context, f QuarantineFilter) ([]QuarantineRecord, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "list_quarantine")()
+
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, job, source_table, source_key, version_date, error, coalesce(payload, ''), first_seen, last_seen, attempts, dismissed_at
+FROM etl_quarantine
+WHERE ($1 = '' or job = $1)
+  AND ($2 or dismissed_at is null)
+ORDER BY last_seen DESC, id DESC
+LIMIT $3`, f.Job, f.IncludeDismissed, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]QuarantineRecord, 0)
+	for rows.Next() {
+		r, err := scanQuarantine(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Store) GetQuarantine(ctx context.Context, id int64) (QuarantineRecord, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "get_quarantine")()
+
+	row := s.db.QueryRowContext(ctx, `
+SELECT id, job, source_table, source_key, version_date, error, coalesce(payload, ''), first_seen, last_seen, attempts, dismissed_at
+FROM etl_quarantine
+WHERE id = $1`, id)
+
+	r, err := scanQuarantine(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrQuarantineNotFound
+	}
+
+	return r, err
+}
+
+func (s *Store) DismissQuarantine(ctx context.Context, id int64) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "dismiss_quarantine")()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE etl_quarantine SET dismissed_at = now() WHERE id = $1 AND dismissed_at is null`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+func (s *Store) ResolveQuarantine(ctx context.Context, tx *sql.Tx, id int64) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "resolve_quarantine")()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM etl_quarantine WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(res)
+}
+
+// ResolveLoadedQuarantine удаляет открытые записи карантина для строк keys, которые загрузились без ошибок.
+// При superseded удаляются и более ранние версии ключа: таблица источника хранит только последнюю версию строки.
+func (s *Store) ResolveLoadedQuarantine(ctx context.Context, tx *sql.Tx, job, table string, keys []QuarantineKey, superseded bool) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	defer metrics.ObserveQuery(metrics.DBTarget, "resolve_loaded_quarantine")()
+
+	sourceKeys := make([]string, 0, len(keys))
+	versions := make([]time.Time, 0, len(keys))
+	for _, k := range keys {
+		sourceKeys = append(sourceKeys, k.SourceKey)
+		versions = append(versions, k.VersionDate)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+DELETE FROM etl_quarantine q
+USING unnest($3::text[], $4::timestamptz[]) AS l(source_key, version_date)
+WHERE q.job = $1
+  AND q.source_table = $2
+  AND q.dismissed_at is null
+  AND q.source_key = l.source_key
+  AND (q.version_date = l.version_date or ($5 and q.version_date < l.version_date))`,
+		job, table, pq.Array(sourceKeys), pq.Array(versions), superseded)
+
+	return err
+}
+
+func (s *Store) TouchQuarantine(ctx context.Context, id int64, errMsg string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "touch_quarantine")()
+
+	_, err := s.db.ExecContext(ctx, `
+UPDATE etl_quarantine
+SET error = $2, last_seen = now(), attempts = attempts + 1
+WHERE id = $1`, id, errMsg)
+
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanQuarantine(row rowScanner) (QuarantineRecord, error) {
+	var (
+		r         QuarantineRecord
+		dismissed sql.NullTime
+	)
+	err := row.Scan(&r.ID, &r.Job, &r.SourceTable, &r.SourceKey, &r.VersionDate, &r.Error, &r.Payload,
+		&r.FirstSeen, &r.LastSeen, &r.Attempts, &dismissed)
+	if dismissed.Valid {
+		r.DismissedAt = &dismissed.Time
+	}
+
+	return r, err
+}
+
+func requireAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrQuarantineNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package target_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+func TestQuarantine(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+	ctx := context.Background()
+
+	v1 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	v2 := v1.Add(time.Hour)
+	v3 := v2.Add(time.Hour)
+
+	quarantine := func(job, table, key string, version time.Time) {
+		t.Helper()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, store.Quarantine(ctx, tx, target.QuarantineRecord{
+			Job: job, SourceTable: table, SourceKey: key, VersionDate: version, Error: "bad payload", Payload: "{}",
+		}))
+		require.NoError(t, tx.Commit())
+	}
+	resolve := func(table string, keys []target.QuarantineKey, superseded bool) {
+		t.Helper()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, store.ResolveLoadedQuarantine(ctx, tx, "portin-dag", table, keys, superseded))
+		require.NoError(t, tx.Commit())
+	}
+	open := func() []string {
+		t.Helper()
+		records, err := store.ListQuarantine(ctx, target.QuarantineFilter{})
+		require.NoError(t, err)
+		res := make([]string, 0, len(records))
+		for _, r := range records {
+			res = append(res, r.Job+"/"+r.SourceTable+"/"+r.SourceKey+"/"+r.VersionDate.UTC().Format(time.TimeOnly))
+		}
+
+		return res
+	}
+
+	quarantine("portin-dag", "orders", "1", v1)
+	quarantine("portin-dag", "orders", "1", v1)
+	quarantine("portin-dag", "orders", "2", v3)
+	quarantine("portin-dag", "orders_log", "1", v1)
+	quarantine("portin-dag", "orders_log", "1", v2)
+	quarantine("portin-other-dag", "orders", "1", v1)
+	quarantine("portin-dag", "orders", "3", v1)
+
+	records, err := store.ListQuarantine(ctx, target.QuarantineFilter{Job: "portin-dag"})
+	require.NoError(t, err)
+	for _, r := range records {
+		if r.SourceTable == "orders" && r.SourceKey == "3" {
+			require.NoError(t, store.DismissQuarantine(ctx, r.ID))
+		}
+		if r.SourceTable == "orders" && r.SourceKey == "1" {
+			require.Equal(t, 2, r.Attempts)
+		}
+	}
+
+	t.Run("later orders version closes earlier records of the key", func(t *testing.T) {
+		resolve("orders", []target.QuarantineKey{{SourceKey: "1", VersionDate: v2}, {SourceKey: "2", VersionDate: v2}, {SourceKey: "3", VersionDate: v2}}, true)
+		require.ElementsMatch(t, []string{
+			"portin-dag/orders/2/14:00:00",
+			"portin-dag/orders_log/1/12:00:00",
+			"portin-dag/orders_log/1/13:00:00",
+			"portin-other-dag/orders/1/12:00:00",
+		}, open())
+	})
+
+	t.Run("orders_log version closes only the same version", func(t *testing.T) {
+		resolve("orders_log", []target.QuarantineKey{{SourceKey: "1", VersionDate: v2}}, false)
+		require.ElementsMatch(t, []string{
+			"portin-dag/orders/2/14:00:00",
+			"portin-dag/orders_log/1/12:00:00",
+			"portin-other-dag/orders/1/12:00:00",
+		}, open())
+	})
+
+	t.Run("dismissed record is kept", func(t *testing.T) {
+		records, err := store.ListQuarantine(ctx, target.QuarantineFilter{Job: "portin-dag", IncludeDismissed: true})
+		require.NoError(t, err)
+		var dismissed int
+		for _, r := range records {
+			if r.DismissedAt != nil {
+				dismissed++
+				require.Equal(t, "3", r.SourceKey)
+			}
+		}
+		require.Equal(t, 1, dismissed)
+	})
+
+	t.Run("empty keys", func(t *testing.T) {
+		resolve("orders", nil, true)
+		require.Len(t, open(), 3)
+	})
+}
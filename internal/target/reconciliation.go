@@ -0,0 +1,293 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// maxStoredKeys ограничивает mismatched_keys одной строки reconciliation_result.
+const maxStoredKeys = 100
+
+type ReconciliationResult struct {
+	ID    int64     `json:"id"`
+	RunAt time.Time `json:"runAt"`
+	// Job - ключ блокировки джобы, которая загружает данные и выполняет backfill.
+	Job   string `json:"job"`
+	Check string `json:"check"`
+	// Day - день в бизнес-зоне, YYYY-MM-DD.
+	Day                string `json:"day"`
+	Status             string `json:"status,omitempty"`
+	SourceCount        int    `json:"sourceCount"`
+	TargetCount        int    `json:"targetCount"`
+	MissingCount       int    `json:"missingCount"`
+	ExtraCount         int    `json:"extraCount"`
+	ChecksumSampled    int    `json:"checksumSampled"`
+	ChecksumMismatched int    `json:"checksumMismatched"`
+	// MismatchedKeys - ключи источника с расхождениями. При сохранении обрезаются до maxStoredKeys.
+	MismatchedKeys   []string `json:"mismatchedKeys,omitempty"`
+	BackfillEnqueued bool     `json:"backfillEnqueued"`
+}
+
+func (r ReconciliationResult) Mismatched() bool {
+	return r.SourceCount != r.TargetCount || r.MissingCount > 0 || r.ExtraCount > 0 || r.ChecksumMismatched > 0
+}
+
+type ReconciliationFilter struct {
+	Check          string
+	MismatchedOnly bool
+}
+
+func (s *Store) SaveReconciliation(ctx context.Context, tx *sql.Tx, results []ReconciliationResult) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_reconciliation")()
+
+	for _, r := range results {
+		keys := r.MismatchedKeys
+		if len(keys) > maxStoredKeys {
+			keys = keys[:maxStoredKeys]
+		}
+		if keys == nil {
+			keys = []string{}
+		}
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO reconciliation_result(run_at, job, check_name, business_day, status, source_count, target_count,
+  missing_count, extra_count, checksum_sampled, checksum_mismatched, mismatched_keys, backfill_enqueued)
+VALUES ($1,$2,$3,$4::date,$5,$6,$7,$8,$9,$10,$11,$12,$13)`,
+			r.RunAt, r.Job, r.Check, r.Day, r.Status, r.SourceCount, r.TargetCount,
+			r.MissingCount, r.ExtraCount, r.ChecksumSampled, r.ChecksumMismatched, pq.Array(keys), r.BackfillEnqueued)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LatestReconciliation возвращает результаты последнего запуска сверки.
+func (s *Store) LatestReconciliation(ctx context.Context, f ReconciliationFilter) ([]ReconciliationResult, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "latest_reconciliation")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, run_at, job, check_name, to_char(business_day, 'YYYY-MM-DD'), status, source_count, target_count,
+  missing_count, extra_count, checksum_sampled, checksum_mismatched, mismatched_keys, backfill_enqueued
+FROM reconciliation_result
+WHERE run_at = (SELECT max(run_at) FROM reconciliation_result)
+  AND ($1 = '' or check_name = $1)
+  AND (not $2 or source_count <> target_count or missing_count > 0 or extra_count > 0 or checksum_mismatched > 0)
+ORDER BY check_name, business_day, status`, f.Check, f.MismatchedOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]ReconciliationResult, 0)
+	for rows.Next() {
+		var r ReconciliationResult
+		err := rows.Scan(&r.ID, &r.RunAt, &r.Job, &r.Check, &r.Day, &r.Status, &r.SourceCount, &r.TargetCount,
+			&r.MissingCount, &r.ExtraCount, &r.ChecksumSampled, &r.ChecksumMismatched, pq.Array(&r.MismatchedKeys), &r.BackfillEnqueued)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+// EnqueueBackfill ставит ключи источника в очередь повторной загрузки джобы job (ключ блокировки).
+func (s *Store) EnqueueBackfill(ctx context.Context, tx *sql.Tx, job, reason string, keys []string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "enqueue_backfill")()
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO etl_backfill(job, source_key, reason, enqueued_at)
+SELECT $1, key, $2, now() FROM unnest($3::text[]) AS key
+ON CONFLICT (job, source_key)
+DO UPDATE SET reason = EXCLUDED.reason, enqueued_at = now(), processed_at = null`, job, reason, pq.Array(keys))
+
+	return err
+}
+
+func (s *Store) PendingBackfill(ctx context.Context, job string, limit int) ([]string, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "pending_backfill")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT source_key FROM etl_backfill
+WHERE job = $1 AND processed_at is null
+ORDER BY enqueued_at, source_key
+LIMIT $2`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (s *Store) CompleteBackfill(ctx context.Context, tx *sql.Tx, job string, keys []string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "complete_backfill")()
+
+	_, err := tx.ExecContext(ctx, `
+UPDATE etl_backfill SET processed_at = now()
+WHERE job = $1 AND source_key = ANY($2) AND processed_at is null`, job, pq.Array(keys))
+
+	return err
+}
+
+// RequestsBetween возвращает заявки источника source из mnp_request с from_date в [from, to).
+func (s *Store) RequestsBetween(ctx context.Context, source string, from, to time.Time) ([]Request, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "requests_between")()
+
+	return s.queryRequests(ctx, `
+SELECT `+requestColumns+` FROM mnp_request
+WHERE port_type = 'portin' AND source = $1 AND from_date >= $2 AND from_date < $3`,
+		source, s.wall(from), s.wall(to))
+}
+
+// RequestVersionsBetween возвращает версии источника source из mnp_request_h с from_date в [from, to).
+func (s *Store) RequestVersionsBetween(ctx context.Context, source string, from, to time.Time) ([]Request, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "request_versions_between")()
+
+	return s.queryRequests(ctx, `
+SELECT `+requestColumns+` FROM mnp_request_h
+WHERE port_type = 'portin' AND source = $1 AND from_date >= $2 AND from_date < $3`,
+		source, s.wall(from), s.wall(to))
+}
+
+// RequestNumbers возвращает номера req_number по заявкам.
+func (s *Store) RequestNumbers(ctx context.Context, reqIDs []string) (map[string][]RequestNumber, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "request_numbers")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT req_id, coalesce(recipient_id, ''), msisdn, coalesce(trim(rn), '') FROM req_number WHERE req_id = ANY($1)`,
+		pq.Array(reqIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string][]RequestNumber)
+	for rows.Next() {
+		var n RequestNumber
+		if err := rows.Scan(&n.ReqID, &n.RecipientID, &n.MSISDN, &n.RN); err != nil {
+			return nil, err
+		}
+		res[n.ReqID] = append(res[n.ReqID], n)
+	}
+
+	return res, rows.Err()
+}
+
+// RawRequestTimesBetween возвращает id и request_time записей источника source из mnp_raw_request с request_time в [from, to).
+func (s *Store) RawRequestTimesBetween(ctx context.Context, source string, from, to time.Time) (map[int64]time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "raw_request_times_between")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, request_time FROM mnp_raw_request WHERE source = $1 AND request_time >= $2 AND request_time < $3`,
+		source, s.wall(from), s.wall(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			id int64
+			ts time.Time
+		)
+		if err := rows.Scan(&id, &ts); err != nil {
+			return nil, err
+		}
+		res[id] = FromWallClock(ts, s.loc)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Store) RawRequests(ctx context.Context, source string, ids []int64) ([]RawRequest, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "raw_requests")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, coalesce(req_id, ''), request_time, coalesce(xml_message, ''), coalesce(operation_info, ''),
+  coalesce(system_source, ''), coalesce(system_dest, ''), source
+FROM mnp_raw_request WHERE source = $1 AND id = ANY($2)`, source, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []RawRequest
+	for rows.Next() {
+		var rr RawRequest
+		err := rows.Scan(&rr.ID, &rr.ReqID, &rr.RequestTime, &rr.XMLMessage, &rr.OperationInfo, &rr.SystemSource, &rr.SystemDest, &rr.Source)
+		if err != nil {
+			return nil, err
+		}
+		rr.RequestTime = FromWallClock(rr.RequestTime, s.loc)
+		res = append(res, rr)
+	}
+
+	return res, rows.Err()
+}
+
+const requestColumns = `order_number, coalesce(request_status_id, 0), request_date, contract_date, port_date, from_date, to_date,
+  coalesce(cdb_id, ''), coalesce(process_type, ''), port_type, coalesce(subscriber_type, ''), coalesce(message_code, ''),
+  reject_reason, order_id, source`
+
+func (s *Store) queryRequests(ctx context.Context, query string, args ...any) ([]Request, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []Request
+	for rows.Next() {
+		var (
+			r                                                     Request
+			requestDate, contractDate, portDate, fromDate, toDate sql.NullTime
+			rejectReason                                          sql.NullInt64
+		)
+		err := rows.Scan(&r.OrderNumber, &r.RequestStatusID, &requestDate, &contractDate, &portDate, &fromDate, &toDate,
+			&r.CDBID, &r.ProcessType, &r.PortType, &r.SubscriberType, &r.MessageCode, &rejectReason, &r.OrderID, &r.Source)
+		if err != nil {
+			return nil, err
+		}
+		r.RequestDate = s.fromWallNull(requestDate)
+		r.ContractDate = s.fromWallNull(contractDate)
+		r.PortDate = s.fromWallNull(portDate)
+		r.ToDate = s.fromWallNull(toDate)
+		if fromDate.Valid {
+			r.FromDate = FromWallClock(fromDate.Time, s.loc)
+		}
+		if rejectReason.Valid {
+			v := int(rejectReason.Int64)
+			r.RejectReason = &v
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Store) fromWallNull(ts sql.NullTime) *time.Time {
+	if !ts.Valid {
+		return nil
+	}
+	t := FromWallClock(ts.Time, s.loc)
+
+	return &t
+}
@@ -0,0 +1,123 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// ReplicaRequest - заявка или версия заявки Replica. OrderID - ID заявки в Replica.
+type ReplicaRequest struct {
+	Request
+	Deleted int
+}
+
+// ReplicaBatch - заявки Replica с OrderIDs вместе со всеми их версиями, номерами и сообщениями.
+type ReplicaBatch struct {
+	OrderIDs []int64
+	Requests []ReplicaRequest
+	Versions []ReplicaRequest
+	Numbers  []RequestNumber
+	Messages []RawRequest
+}
+
+var replicaRequestCopyColumns = append(append([]string{}, requestCopyColumns...), "deleted")
+
+// ReplaceReplicaBatch заменяет в replica_* заявки пачки и все их строки: Replica не сообщает об удалении
+// версий и номеров, поэтому строки заявки перезаписываются целиком.
+func (s *Store) ReplaceReplicaBatch(ctx context.Context, tx *sql.Tx, b ReplicaBatch) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "replace_replica_batch")()
+
+	if len(b.OrderIDs) == 0 {
+		return nil
+	}
+
+	// Номера и сообщения привязаны к order_number, который мог измениться, поэтому удаляются и по старому, и по новому.
+	orderNumbers := make([]string, 0, len(b.Requests))
+	for _, r := range b.Requests {
+		orderNumbers = append(orderNumbers, r.OrderNumber)
+	}
+	for _, table := range []string{"replica_req_number", "replica_mnp_raw_request"} {
+		_, err := tx.ExecContext(ctx, `
+DELETE FROM `+table+`
+WHERE req_id = ANY($2) OR req_id IN (SELECT order_number FROM replica_mnp_request WHERE order_id = ANY($1))`,
+			pq.Array(b.OrderIDs), pq.Array(orderNumbers))
+		if err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"replica_mnp_request_h", "replica_mnp_request"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE order_id = ANY($1)`, pq.Array(b.OrderIDs)); err != nil {
+			return err
+		}
+	}
+
+	cols := strings.Join(replicaRequestCopyColumns, ", ")
+	if err := s.stageReplicaRequests(ctx, tx, "stage_replica_mnp_request", "replica_mnp_request", b.Requests); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO replica_mnp_request (`+cols+`, change_date)
+SELECT `+cols+`, timezone($1, now()) FROM stage_replica_mnp_request`, s.loc.String())
+	if err != nil {
+		return err
+	}
+
+	if err := s.stageReplicaRequests(ctx, tx, "stage_replica_mnp_request_h", "replica_mnp_request_h", b.Versions); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO replica_mnp_request_h (`+cols+`, change_date)
+SELECT `+cols+`, timezone($1, now()) FROM stage_replica_mnp_request_h`, s.loc.String())
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]any, 0, len(b.Numbers))
+	for _, n := range b.Numbers {
+		rows = append(rows, []any{n.ReqID, nullIfEmpty(n.RecipientID), n.MSISDN, nullIfEmpty(n.RN)})
+	}
+	if err := copyToStage(ctx, tx, "stage_replica_req_number", "replica_req_number", reqNumberCopyColumns, rows); err != nil {
+		return err
+	}
+	cols = strings.Join(reqNumberCopyColumns, ", ")
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO replica_req_number (`+cols+`, change_date)
+SELECT `+cols+`, now() FROM stage_replica_req_number`)
+	if err != nil {
+		return err
+	}
+
+	rows = make([][]any, 0, len(b.Messages))
+	for _, rr := range lastByKey(b.Messages, func(rr RawRequest) int64 { return rr.ID }) {
+		rows = append(rows, []any{rr.ID, rr.ReqID, s.wall(rr.RequestTime), rr.XMLMessage, rr.OperationInfo, rr.SystemSource, rr.SystemDest})
+	}
+	if err := copyToStage(ctx, tx, "stage_replica_mnp_raw_request", "replica_mnp_raw_request", rawRequestCopyColumns, rows); err != nil {
+		return err
+	}
+	cols = strings.Join(rawRequestCopyColumns, ", ")
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO replica_mnp_raw_request (`+cols+`, change_date)
+SELECT `+cols+`, now() FROM stage_replica_mnp_raw_request
+ON CONFLICT (id)
+DO UPDATE SET`+rawRequestUpsertSet)
+
+	return err
+}
+
+func (s *Store) stageReplicaRequests(ctx context.Context, tx *sql.Tx, stage, like string, rs []ReplicaRequest) error {
+	rows := make([][]any, 0, len(rs))
+	for _, r := range rs {
+		rows = append(rows, []any{
+			r.OrderNumber, r.RequestStatusID, s.wallPtr(r.RequestDate), s.wallPtr(r.ContractDate), s.wallPtr(r.PortDate),
+			s.wall(r.FromDate), s.wallPtr(r.ToDate), r.CDBID, r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode,
+			r.RejectReason, r.OrderID, r.Deleted,
+		})
+	}
+
+	return copyToStage(ctx, tx, stage, like, replicaRequestCopyColumns, rows)
+}
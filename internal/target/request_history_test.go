@@ -0,0 +1,141 @@
+package target_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+type historyRow struct {
+	orderID string
+	from    string
+	to      string // "" - открытая версия.
+	status  int
+}
+
+func requestHistory(t *testing.T, db *sql.DB, orderIDs ...string) []historyRow {
+	t.Helper()
+
+	rows, err := db.Query(`
+SELECT order_id, from_date, to_date, request_status_id FROM mnp_request_h
+WHERE order_id = ANY($1)
+ORDER BY order_id, from_date`, pq.Array(orderIDs))
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var res []historyRow
+	for rows.Next() {
+		var (
+			r    historyRow
+			from time.Time
+			to   sql.NullTime
+		)
+		require.NoError(t, rows.Scan(&r.orderID, &from, &to, &r.status))
+		r.from = from.Format(time.DateTime)
+		if to.Valid {
+			r.to = to.Time.Format(time.DateTime)
+		}
+		res = append(res, r)
+	}
+	require.NoError(t, rows.Err())
+
+	return res
+}
+
+func portInRequest(orderID string, status int, from time.Time, to *time.Time) target.Request {
+	return target.Request{
+		OrderNumber:     "pin" + orderID,
+		RequestStatusID: status,
+		FromDate:        from,
+		ToDate:          to,
+		PortType:        "portin",
+		OrderID:         orderID,
+		Source:          target.DefaultSource,
+	}
+}
+
+// fillers дополняет пачку до target.BulkMinRows заявками, которые тест не проверяет, чтобы включить пакетную запись.
+func fillers(from time.Time, to *time.Time) []target.Request {
+	res := make([]target.Request, 0, target.BulkMinRows)
+	for i := range target.BulkMinRows {
+		res = append(res, portInRequest(fmt.Sprintf("9%03d", i), 1, from, to))
+	}
+
+	return res
+}
+
+func TestUpsertOpenRequestVersion(t *testing.T) {
+	t0 := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	t1 := t0.Add(2 * time.Hour)
+	closed := t1.Add(-time.Second)
+	fillerFrom := t0.Add(-time.Hour)
+	fillerTo := t0.Add(-time.Second)
+
+	// Заявка 1 не менялась и загружается повторно, 2 обновилась между запусками,
+	// у 3 первая версия пришла только позже, из orders_log.
+	runs := []struct {
+		open     []target.Request
+		versions []target.Request
+	}{
+		{
+			open: []target.Request{
+				portInRequest("1", 1, t0, nil),
+				portInRequest("2", 1, t0, nil),
+				portInRequest("3", 2, t1, nil),
+			},
+		},
+		{
+			open: []target.Request{
+				portInRequest("1", 1, t0, nil),
+				portInRequest("2", 2, t1, nil),
+			},
+			versions: []target.Request{
+				portInRequest("3", 1, t0, &closed),
+			},
+		},
+	}
+	want := []historyRow{
+		{orderID: "1", from: "2026-10-01 10:00:00", status: 1},
+		{orderID: "2", from: "2026-10-01 10:00:00", to: "2026-10-01 11:59:59", status: 1},
+		{orderID: "2", from: "2026-10-01 12:00:00", status: 2},
+		{orderID: "3", from: "2026-10-01 10:00:00", to: "2026-10-01 11:59:59", status: 1},
+		{orderID: "3", from: "2026-10-01 12:00:00", status: 2},
+	}
+
+	for _, bulk := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bulk=%t", bulk), func(t *testing.T) {
+			db, store := targettest.NewStore(t, time.UTC)
+			ctx := context.Background()
+
+			for _, run := range runs {
+				open, versions := run.open, run.versions
+				if bulk {
+					open = append(fillers(t0, nil), open...)
+					versions = append(fillers(fillerFrom, &fillerTo), versions...)
+				}
+				tx, err := db.BeginTx(ctx, nil)
+				require.NoError(t, err)
+				require.NoError(t, store.UpsertOpenRequestVersions(ctx, tx, open))
+				require.NoError(t, store.InsertRequestHistories(ctx, tx, versions))
+				require.NoError(t, tx.Commit())
+			}
+
+			require.Equal(t, want, requestHistory(t, db, "1", "2", "3"))
+
+			var openVersions int
+			require.NoError(t, db.QueryRow(`
+SELECT count(*) FROM (
+  SELECT order_id FROM mnp_request_h WHERE to_date is null GROUP BY source, order_id HAVING count(*) > 1
+) d`).Scan(&openVersions))
+			require.Zero(t, openVersions, "orders with more than one open version")
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package target
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+var ErrRequestNotFound = errors.New("request not found")
+
+// RequestFilter - поиск заявок mnp_request. Пустые поля не фильтруют.
+// From и To ограничивают request_date полуинтервалом [From, To).
+// After - order_number последней заявки предыдущей страницы.
+type RequestFilter struct {
+	MSISDN   string
+	StatusID *int
+	From     *time.Time
+	To       *time.Time
+	After    string
+	Limit    int
+}
+
+// RequestByOrderNumber возвращает текущее состояние заявки из mnp_request.
+func (s *Store) RequestByOrderNumber(ctx context.Context, orderNumber string) (Request, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "request_by_order_number")()
+
+	rs, err := s.queryRequests(ctx, `
+SELECT `+requestColumns+` FROM mnp_request WHERE order_number = $1`, orderNumber)
+	if err != nil {
+		return Request{}, err
+	}
+	if len(rs) == 0 {
+		return Request{}, ErrRequestNotFound
+	}
+
+	return rs[0], nil
+}
+
+// RequestVersions возвращает версии заявки из mnp_request_h по возрастанию from_date.
+func (s *Store) RequestVersions(ctx context.Context, orderNumber string) ([]Request, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "request_versions")()
+
+	return s.queryRequests(ctx, `
+SELECT `+requestColumns+` FROM mnp_request_h WHERE order_number = $1 ORDER BY from_date`, orderNumber)
+}
+
+// RawRequestsByReqID возвращает сообщения БДПН заявки по возрастанию request_time.
+func (s *Store) RawRequestsByReqID(ctx context.Context, reqID string) ([]RawRequest, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "raw_requests_by_req_id")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, coalesce(req_id, ''), request_time, coalesce(xml_message, ''), coalesce(operation_info, ''),
+  coalesce(system_source, ''), coalesce(system_dest, ''), source
+FROM mnp_raw_request WHERE req_id = $1
+ORDER BY request_time, id`, reqID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]RawRequest, 0)
+	for rows.Next() {
+		var rr RawRequest
+		err := rows.Scan(&rr.ID, &rr.ReqID, &rr.RequestTime, &rr.XMLMessage, &rr.OperationInfo, &rr.SystemSource, &rr.SystemDest, &rr.Source)
+		if err != nil {
+			return nil, err
+		}
+		rr.RequestTime = FromWallClock(rr.RequestTime, s.loc)
+		res = append(res, rr)
+	}
+
+	return res, rows.Err()
+}
+
+// SearchRequests возвращает страницу заявок по возрастанию order_number и признак следующей страницы.
+func (s *Store) SearchRequests(ctx context.Context, f RequestFilter) ([]Request, bool, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "search_requests")()
+
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+
+	rs, err := s.queryRequests(ctx, `
+SELECT `+requestColumns+` FROM mnp_request r
+WHERE ($1 = '' or EXISTS (SELECT 1 FROM req_number n WHERE n.req_id = r.order_number AND n.msisdn = $1))
+  AND ($2::integer is null or request_status_id = $2)
+  AND ($3::timestamp is null or request_date >= $3)
+  AND ($4::timestamp is null or request_date < $4)
+  AND order_number > $5
+ORDER BY order_number
+LIMIT $6`, f.MSISDN, f.StatusID, s.wallPtr(f.From), s.wallPtr(f.To), f.After, f.Limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(rs) > f.Limit {
+		return rs[:f.Limit], true, nil
+	}
+
+	return rs, false, nil
+}
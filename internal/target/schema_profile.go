@@ -0,0 +1,53 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+type SchemaPath struct {
+	Path     string
+	JSONType string
+}
+
+func (s *Store) KnownSchemaPaths(ctx context.Context, job, table string) (map[string]struct{}, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "known_schema_paths")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT DISTINCT path FROM payload_schema_profile WHERE job = $1 AND source_table = $2`, job, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]struct{})
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		res[path] = struct{}{}
+	}
+
+	return res, rows.Err()
+}
+
+func (s *Store) UpsertSchemaProfile(ctx context.Context, tx *sql.Tx, job, table string, counts map[SchemaPath]int) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "upsert_schema_profile")()
+
+	for p, count := range counts {
+		_, err := tx.ExecContext(ctx, `
+INSERT INTO payload_schema_profile(job, source_table, path, json_type, first_seen, last_seen, seen_count)
+VALUES ($1,$2,$3,$4,now(),now(),$5)
+ON CONFLICT (job, source_table, path, json_type)
+DO UPDATE SET last_seen = now(), seen_count = payload_schema_profile.seen_count + EXCLUDED.seen_count
+`, job, table, p.Path, p.JSONType, count)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
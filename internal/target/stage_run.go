@@ -0,0 +1,131 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+var (
+	stageRunRequestColumns   = append([]string{"run_id"}, portInCopyColumns...)
+	stageRunReqNumberColumns = append([]string{"run_id"}, reqNumberCopyColumns...)
+)
+
+// BeginStageRun регистрирует запуск job, пачку которого воркеры готовят параллельно в stage_run_*.
+// Незавершенные запуски job удаляются: вызывать под блокировкой джобы.
+func (s *Store) BeginStageRun(ctx context.Context, job string) (int64, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "begin_stage_run")()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM stage_run WHERE job = $1`, job); err != nil {
+		return 0, err
+	}
+	var runID int64
+	err := s.db.QueryRowContext(ctx, `INSERT INTO stage_run (job) VALUES ($1) RETURNING id`, job).Scan(&runID)
+
+	return runID, err
+}
+
+// StageRunRequests пишет заявки и номера одного воркера в staging запуска runID в собственной транзакции.
+// В витрину строки попадают только в MergeStageRun.
+func (s *Store) StageRunRequests(ctx context.Context, runID int64, rs []Request, ns []RequestNumber) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "stage_run_requests")()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	rows := make([][]any, 0, len(rs))
+	for _, r := range rs {
+		rows = append(rows, append([]any{runID}, s.requestRow(r)...))
+	}
+	if err := copyRows(ctx, tx, "stage_run_request", stageRunRequestColumns, rows); err != nil {
+		return err
+	}
+	rows = make([][]any, 0, len(ns))
+	for _, n := range ns {
+		rows = append(rows, append([]any{runID}, reqNumberRow(n)...))
+	}
+	if err := copyRows(ctx, tx, "stage_run_req_number", stageRunReqNumberColumns, rows); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MergeStageRun сливает staging запуска runID в mnp_request, текущие версии mnp_request_h и req_number
+// так же, как UpsertRequests, UpsertOpenRequestVersions и UpsertReqNumbers, и удаляет запуск.
+// При повторе ключа побеждает строка, записанная последней.
+func (s *Store) MergeStageRun(ctx context.Context, tx *sql.Tx, runID int64) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "merge_stage_run")()
+
+	cols := strings.Join(portInCopyColumns, ", ")
+	if err := createStage(ctx, tx, "stage_mnp_request", "mnp_request", portInCopyColumns); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO stage_mnp_request (`+cols+`)
+SELECT DISTINCT ON (order_number) `+cols+` FROM stage_run_request
+WHERE run_id = $1
+ORDER BY order_number, id DESC`, runID)
+	if err != nil {
+		return err
+	}
+	if err := s.mergeRequests(ctx, tx); err != nil {
+		return err
+	}
+
+	openCols := make([]string, 0, len(portInCopyColumns))
+	for _, c := range portInCopyColumns {
+		if c == "to_date" {
+			c = "NULL::timestamp"
+		}
+		openCols = append(openCols, c)
+	}
+	if err := createStage(ctx, tx, "stage_mnp_request_h", "mnp_request_h", portInCopyColumns); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO stage_mnp_request_h (`+cols+`)
+SELECT DISTINCT ON (source, order_id) `+strings.Join(openCols, ", ")+` FROM stage_run_request
+WHERE run_id = $1
+ORDER BY source, order_id, id DESC`, runID)
+	if err != nil {
+		return err
+	}
+	if err := s.mergeOpenRequestVersions(ctx, tx); err != nil {
+		return err
+	}
+
+	numberCols := strings.Join(reqNumberCopyColumns, ", ")
+	if err := createStage(ctx, tx, "stage_req_number", "req_number", reqNumberCopyColumns); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+INSERT INTO stage_req_number (`+numberCols+`)
+SELECT DISTINCT ON (req_id, msisdn) `+numberCols+` FROM stage_run_req_number
+WHERE run_id = $1
+ORDER BY req_id, msisdn, id DESC`, runID)
+	if err != nil {
+		return err
+	}
+	if err := mergeReqNumbers(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM stage_run WHERE id = $1`, runID)
+
+	return err
+}
+
+// DropStageRun удаляет запуск и его staging. После фиксации MergeStageRun запуска уже нет.
+func (s *Store) DropStageRun(ctx context.Context, runID int64) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "drop_stage_run")()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM stage_run WHERE id = $1`, runID)
+
+	return err
+}
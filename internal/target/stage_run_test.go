@@ -0,0 +1,105 @@
+package target_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+func TestMergeStageRun(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+
+	requests := make([]target.Request, 0, 2*target.BulkMinRows)
+	numbers := make([]target.RequestNumber, 0, 2*target.BulkMinRows)
+	for i := range 2 * target.BulkMinRows {
+		r := portInRequest(fmt.Sprintf("%d", i), 1, t0, nil)
+		requests = append(requests, r)
+		numbers = append(numbers, target.RequestNumber{ReqID: r.OrderNumber, MSISDN: fmt.Sprintf("79%09d", i)})
+	}
+	// Первая версия заявки 0 уже в витрине и должна закрыться.
+	existing := []target.Request{portInRequest("0", 1, t0.Add(-time.Hour), nil)}
+	half := len(requests) / 2
+
+	count := func(db *sql.DB, table string) int {
+		t.Helper()
+		var n int
+		require.NoError(t, db.QueryRow(`SELECT count(*) FROM `+table).Scan(&n))
+
+		return n
+	}
+	write := func(db *sql.DB, fn func(tx *sql.Tx) error) {
+		t.Helper()
+		tx, err := db.BeginTx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, fn(tx))
+		require.NoError(t, tx.Commit())
+	}
+
+	bulkDB, bulk := targettest.NewStore(t, time.UTC)
+	write(bulkDB, func(tx *sql.Tx) error {
+		if err := bulk.UpsertRequests(ctx, tx, existing); err != nil {
+			return err
+		}
+		return bulk.UpsertOpenRequestVersions(ctx, tx, existing)
+	})
+	write(bulkDB, func(tx *sql.Tx) error {
+		if err := bulk.UpsertRequests(ctx, tx, requests); err != nil {
+			return err
+		}
+		if err := bulk.UpsertOpenRequestVersions(ctx, tx, requests); err != nil {
+			return err
+		}
+		return bulk.UpsertReqNumbers(ctx, tx, numbers)
+	})
+
+	stageDB, stage := targettest.NewStore(t, time.UTC)
+	write(stageDB, func(tx *sql.Tx) error {
+		if err := stage.UpsertRequests(ctx, tx, existing); err != nil {
+			return err
+		}
+		return stage.UpsertOpenRequestVersions(ctx, tx, existing)
+	})
+
+	t.Run("dropped run leaves no rows", func(t *testing.T) {
+		runID, err := stage.BeginStageRun(ctx, "portin-dag")
+		require.NoError(t, err)
+		require.NoError(t, stage.StageRunRequests(ctx, runID, requests, numbers))
+		require.NoError(t, stage.DropStageRun(ctx, runID))
+		require.Zero(t, count(stageDB, "stage_run_request"))
+		require.Zero(t, count(stageDB, "stage_run_req_number"))
+	})
+
+	t.Run("next run removes unfinished runs of the job", func(t *testing.T) {
+		runID, err := stage.BeginStageRun(ctx, "portin-dag")
+		require.NoError(t, err)
+		require.NoError(t, stage.StageRunRequests(ctx, runID, requests[:1], nil))
+		_, err = stage.BeginStageRun(ctx, "portin-dag")
+		require.NoError(t, err)
+		require.Zero(t, count(stageDB, "stage_run_request"))
+	})
+
+	t.Run("merged run matches bulk upsert", func(t *testing.T) {
+		runID, err := stage.BeginStageRun(ctx, "portin-dag")
+		require.NoError(t, err)
+		require.NoError(t, stage.StageRunRequests(ctx, runID, requests[:half], numbers[:half]))
+		require.NoError(t, stage.StageRunRequests(ctx, runID, requests[half:], numbers[half:]))
+		write(stageDB, func(tx *sql.Tx) error { return stage.MergeStageRun(ctx, tx, runID) })
+
+		for _, table := range []string{"mnp_request", "mnp_request_h", "req_number"} {
+			want := tableRows(t, bulkDB, table)
+			require.NotEmpty(t, want, table)
+			require.Equal(t, want, tableRows(t, stageDB, table), table)
+		}
+		require.Equal(t, 2*target.BulkMinRows+1, count(stageDB, "mnp_request_h"))
+		require.Zero(t, count(stageDB, "stage_run"))
+		require.Zero(t, count(stageDB, "stage_run_request"))
+	})
+}
@@ -3,14 +3,71 @@ package target
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
 )
 
+// DefaultSource - значение колонки source для единственной установки MNPHUB, заданной без PORTIN_SOURCES.
+const DefaultSource = "default"
+
+// Выражения DO UPDATE SET общие для построчной и пакетной загрузки.
+const requestUpsertSet = `
+  request_status_id = EXCLUDED.request_status_id,
+  request_date = EXCLUDED.request_date,
+  contract_date = EXCLUDED.contract_date,
+  port_date = EXCLUDED.port_date,
+  from_date = EXCLUDED.from_date,
+  to_date = EXCLUDED.to_date,
+  change_date = EXCLUDED.change_date,
+  deleted = 0,
+  cdb_id = EXCLUDED.cdb_id,
+  process_type = EXCLUDED.process_type,
+  port_type = EXCLUDED.port_type,
+  subscriber_type = EXCLUDED.subscriber_type,
+  message_code = EXCLUDED.message_code,
+  reject_reason = EXCLUDED.reject_reason,
+  order_id = EXCLUDED.order_id,
+  source = EXCLUDED.source
+`
+
+const requestHistoryUpsertSet = `
+  request_status_id = EXCLUDED.request_status_id,
+  request_date = EXCLUDED.request_date,
+  contract_date = EXCLUDED.contract_date,
+  port_date = EXCLUDED.port_date,
+  to_date = EXCLUDED.to_date,
+  change_date = EXCLUDED.change_date,
+  cdb_id = EXCLUDED.cdb_id,
+  process_type = EXCLUDED.process_type,
+  port_type = EXCLUDED.port_type,
+  subscriber_type = EXCLUDED.subscriber_type,
+  message_code = EXCLUDED.message_code,
+  reject_reason = EXCLUDED.reject_reason
+`
+
+const reqNumberUpsertSet = `
+  recipient_id = EXCLUDED.recipient_id, rn = EXCLUDED.rn, change_date = now()
+`
+
+const rawRequestUpsertSet = `
+  req_id=EXCLUDED.req_id,
+  request_time=EXCLUDED.request_time,
+  xml_message=EXCLUDED.xml_message,
+  operation_info=EXCLUDED.operation_info,
+  system_source=EXCLUDED.system_source,
+  system_dest=EXCLUDED.system_dest,
+  change_date=now()
+`
+
 type Store struct {
-	db *sql.DB
+	db  *sql.DB
+	loc *time.Location
 }
 
-func NewStore(db *sql.DB) *Store { return &Store{db: db} }
+// NewStore создает хранилище витрины. loc - бизнес-зона, в которой пишутся и читаются колонки TIMESTAMP.
+func NewStore(db *sql.DB, loc *time.Location) *Store { return &Store{db: db, loc: loc} }
 
 type Request struct {
 	OrderNumber     string
@@ -26,7 +83,10 @@ type Request struct {
 	SubscriberType  string
 	MessageCode     string
 	RejectReason    *int
-	OrderID         int64
+	// OrderID - идентификатор заявки в источнике: целое число или GUID в текстовом виде.
+	OrderID string
+	// Source - установка MNPHUB, из которой загружена заявка. order_id уникален только в пределах источника.
+	Source string
 }
 
 type RequestNumber struct {
@@ -44,123 +104,250 @@ type RawRequest struct {
 	OperationInfo string
 	SystemSource  string
 	SystemDest    string
+	// Source - установка MNPHUB, из cdb-messaging-db которой загружено сообщение. ID уникален только в пределах источника.
+	Source string
 }
 
-func (s *Store) TryLockJob(ctx context.Context, name string) (bool, error) {
+// TryLockJob берет advisory lock джобы. Блокировка сессионная, поэтому удерживается
+// на выделенном соединении и снимается возвращаемой функцией на нем же.
+func (s *Store) TryLockJob(ctx context.Context, name string) (func(), bool, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "try_lock_job")()
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key := "mnp-datamart:" + name
 	var ok bool
-	err := s.db.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, "mnp-datamart:"+name).Scan(&ok)
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, key).Scan(&ok); err != nil {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, false, nil
+	}
 
-	return ok, err
+	unlock := func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, key)
+		_ = conn.Close()
+	}
+
+	return unlock, true, nil
+}
+
+func (s *Store) MaxFromDate(ctx context.Context, source string) (*time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "max_from_date")()
+
+	return s.maxTimestamp(ctx, `SELECT max(from_date) FROM mnp_request WHERE source = $1`, source)
 }
 
-func (s *Store) UnlockJob(ctx context.Context, name string) {
-	_, _ = s.db.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, "mnp-datamart:"+name)
+func (s *Store) MaxRawRequestTime(ctx context.Context, source string) (*time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "max_raw_request_time")()
+
+	return s.maxTimestamp(ctx, `SELECT max(request_time) FROM mnp_raw_request WHERE source = $1`, source)
 }
 
-func (s *Store) MaxFromDate(ctx context.Context) (*time.Time, error) {
+func (s *Store) maxTimestamp(ctx context.Context, query string, args ...any) (*time.Time, error) {
 	var ts sql.NullTime
-	if err := s.db.QueryRowContext(ctx, `SELECT max(from_date) FROM mnp_request`).Scan(&ts); err != nil {
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&ts); err != nil {
 		return nil, err
 	}
 	if !ts.Valid {
 		return nil, nil
 	}
+	t := FromWallClock(ts.Time, s.loc)
 
-	return &ts.Time, nil
+	return &t, nil
 }
 
-func (s *Store) MaxRawRequestTime(ctx context.Context) (*time.Time, error) {
-	var ts sql.NullTime
-	if err := s.db.QueryRowContext(ctx, `SELECT max(request_time) FROM mnp_raw_request`).Scan(&ts); err != nil {
+// SaveRunSnapshot записывает в etl_state время снимка источника, на котором выполнен запуск джобы.
+func (s *Store) SaveRunSnapshot(ctx context.Context, tx *sql.Tx, job string, snapshotAt time.Time) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_run_snapshot")()
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO etl_state(job_name, snapshot_at, updated_at)
+VALUES ($1,$2,now())
+ON CONFLICT (job_name)
+DO UPDATE SET snapshot_at = EXCLUDED.snapshot_at, updated_at = now()`, job, snapshotAt)
+
+	return err
+}
+
+// JobWatermark возвращает watermark джобы из etl_state, до которого учтены изменения витрины. nil - джоба еще не выполнялась.
+func (s *Store) JobWatermark(ctx context.Context, job string) (*time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "job_watermark")()
+
+	var wm sql.NullTime
+	err := s.db.QueryRowContext(ctx, `SELECT watermark FROM etl_state WHERE job_name = $1`, job).Scan(&wm)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !wm.Valid) {
+		return nil, nil
+	}
+	if err != nil {
 		return nil, err
 	}
-	if !ts.Valid {
-		return nil, nil
+
+	return &wm.Time, nil
+}
+
+// BeginWrite открывает транзакцию записи в витрину и сразу получает xid, чтобы OldestTransactionStart
+// учитывал ее еще до первой записи: джобы читают источник уже после начала транзакции.
+func (s *Store) BeginWrite(ctx context.Context) (*sql.Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT txid_current()`); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// OldestTransactionStart возвращает начало самой старой открытой пишущей транзакции других сессий витрины,
+// nil - таких нет. change_date = now() - время начала транзакции, поэтому строки с change_date после этого
+// момента могут еще стать видимыми. Читающие сессии (BI, idle in transaction без записи) не учитываются.
+func (s *Store) OldestTransactionStart(ctx context.Context) (*time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "oldest_transaction_start")()
+
+	var ts sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+SELECT min(xact_start) FROM pg_stat_activity
+WHERE datname = current_database() AND backend_type = 'client backend' AND pid <> pg_backend_pid()
+  AND backend_xid is not null`).Scan(&ts)
+	if err != nil || !ts.Valid {
+		return nil, err
 	}
 
 	return &ts.Time, nil
 }
 
+// SaveJobWatermark записывает в etl_state watermark джобы.
+func (s *Store) SaveJobWatermark(ctx context.Context, tx *sql.Tx, job string, wm time.Time) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_job_watermark")()
+
+	_, err := tx.ExecContext(ctx, `
+INSERT INTO etl_state(job_name, watermark, updated_at)
+VALUES ($1,$2,now())
+ON CONFLICT (job_name)
+DO UPDATE SET watermark = EXCLUDED.watermark, updated_at = now()`, job, wm)
+
+	return err
+}
+
+// SaveJobSuccess записывает в etl_state время успешного запуска джобы.
+func (s *Store) SaveJobSuccess(ctx context.Context, job string, at time.Time) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "save_job_success")()
+
+	_, err := s.db.ExecContext(ctx, `
+INSERT INTO etl_state(job_name, last_success_at, updated_at)
+VALUES ($1,$2,now())
+ON CONFLICT (job_name)
+DO UPDATE SET last_success_at = EXCLUDED.last_success_at, updated_at = now()`, job, at)
+
+	return err
+}
+
+// JobSuccesses возвращает из etl_state время последнего успешного запуска по именам джоб.
+func (s *Store) JobSuccesses(ctx context.Context) (map[string]time.Time, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "job_successes")()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT job_name, last_success_at FROM etl_state WHERE last_success_at IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]time.Time)
+	for rows.Next() {
+		var (
+			job string
+			at  time.Time
+		)
+		if err := rows.Scan(&job, &at); err != nil {
+			return nil, err
+		}
+		res[job] = at
+	}
+
+	return res, rows.Err()
+}
+
 func (s *Store) UpsertRequest(ctx context.Context, tx *sql.Tx, r Request) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "upsert_request")()
+
 	_, err := tx.ExecContext(ctx, `
 INSERT INTO mnp_request (
   order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date,
-  change_date, deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id
-) VALUES ($1,$2,$3,$4,$5,$6,$7,now(),0,$8,$9,$10,$11,$12,$13,$14)
+  change_date, deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source
+) VALUES ($1,$2,$3,$4,$5,$6,$7,timezone($15, now()),0,$8,$9,$10,$11,$12,$13,$14,$16)
 ON CONFLICT (order_number)
-DO UPDATE SET
-  request_status_id = EXCLUDED.request_status_id,
-  request_date = EXCLUDED.request_date,
-  contract_date = EXCLUDED.contract_date,
-  port_date = EXCLUDED.port_date,
-  from_date = EXCLUDED.from_date,
-  to_date = EXCLUDED.to_date,
-  change_date = now(),
-  deleted = 0,
-  cdb_id = EXCLUDED.cdb_id,
-  process_type = EXCLUDED.process_type,
-  port_type = EXCLUDED.port_type,
-  subscriber_type = EXCLUDED.subscriber_type,
-  message_code = EXCLUDED.message_code,
-  reject_reason = EXCLUDED.reject_reason,
-  order_id = EXCLUDED.order_id
-`, r.OrderNumber, r.RequestStatusID, r.RequestDate, r.ContractDate, r.PortDate, r.FromDate, r.ToDate, r.CDBID,
-		r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode, r.RejectReason, r.OrderID)
+DO UPDATE SET`+requestUpsertSet,
+		r.OrderNumber, r.RequestStatusID, s.wallPtr(r.RequestDate), s.wallPtr(r.ContractDate), s.wallPtr(r.PortDate),
+		s.wall(r.FromDate), s.wallPtr(r.ToDate), r.CDBID, r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode,
+		r.RejectReason, r.OrderID, s.loc.String(), r.Source)
 
 	return err
 }
 
 func (s *Store) InsertRequestHistory(ctx context.Context, tx *sql.Tx, r Request) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "insert_request_history")()
+
 	_, err := tx.ExecContext(ctx, `
 INSERT INTO mnp_request_h (
   order_number, request_status_id, request_date, contract_date, port_date, from_date, to_date,
-  change_date, deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id
-) VALUES ($1,$2,$3,$4,$5,$6,$7,now(),0,$8,$9,$10,$11,$12,$13,$14)
-ON CONFLICT (order_id, from_date)
-DO UPDATE SET
-  request_status_id = EXCLUDED.request_status_id,
-  request_date = EXCLUDED.request_date,
-  contract_date = EXCLUDED.contract_date,
-  port_date = EXCLUDED.port_date,
-  to_date = EXCLUDED.to_date,
-  change_date = now(),
-  cdb_id = EXCLUDED.cdb_id,
-  process_type = EXCLUDED.process_type,
-  port_type = EXCLUDED.port_type,
-  subscriber_type = EXCLUDED.subscriber_type,
-  message_code = EXCLUDED.message_code,
-  reject_reason = EXCLUDED.reject_reason
-`, r.OrderNumber, r.RequestStatusID, r.RequestDate, r.ContractDate, r.PortDate, r.FromDate, r.ToDate, r.CDBID,
-		r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode, r.RejectReason, r.OrderID)
+  change_date, deleted, cdb_id, process_type, port_type, subscriber_type, message_code, reject_reason, order_id, source
+) VALUES ($1,$2,$3,$4,$5,$6,$7,timezone($15, now()),0,$8,$9,$10,$11,$12,$13,$14,$16)
+ON CONFLICT (source, order_id, from_date)
+DO UPDATE SET`+requestHistoryUpsertSet,
+		r.OrderNumber, r.RequestStatusID, s.wallPtr(r.RequestDate), s.wallPtr(r.ContractDate), s.wallPtr(r.PortDate),
+		s.wall(r.FromDate), s.wallPtr(r.ToDate), r.CDBID, r.ProcessType, r.PortType, r.SubscriberType, r.MessageCode,
+		r.RejectReason, r.OrderID, s.loc.String(), r.Source)
 
 	return err
 }
 
+// UpsertOpenRequestVersion записывает в mnp_request_h текущую версию заявки с пустым to_date
+// и закрывает предыдущую открытую версию секундой раньше from_date новой.
+func (s *Store) UpsertOpenRequestVersion(ctx context.Context, tx *sql.Tx, r Request) error {
+	observe := metrics.ObserveQuery(metrics.DBTarget, "close_request_version")
+	_, err := tx.ExecContext(ctx, `
+UPDATE mnp_request_h
+SET to_date = $2::timestamp - interval '1 second', change_date = timezone($3, now())
+WHERE source = $4 AND order_id = $1 AND to_date is null AND from_date < $2`, r.OrderID, s.wall(r.FromDate), s.loc.String(), r.Source)
+	observe()
+	if err != nil {
+		return err
+	}
+	r.ToDate = nil
+
+	return s.InsertRequestHistory(ctx, tx, r)
+}
+
 func (s *Store) UpsertReqNumber(ctx context.Context, tx *sql.Tx, n RequestNumber) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "upsert_req_number")()
+
 	_, err := tx.ExecContext(ctx, `
 INSERT INTO req_number(req_id, recipient_id, msisdn, rn, change_date)
 VALUES ($1,$2,$3,$4,now())
 ON CONFLICT (req_id, msisdn)
-DO UPDATE SET recipient_id = EXCLUDED.recipient_id, rn = EXCLUDED.rn, change_date = now()
-`, n.ReqID, nullIfEmpty(n.RecipientID), n.MSISDN, nullIfEmpty(n.RN))
+DO UPDATE SET`+reqNumberUpsertSet,
+		n.ReqID, nullIfEmpty(n.RecipientID), n.MSISDN, nullIfEmpty(n.RN))
 
 	return err
 }
 
 func (s *Store) UpsertRawRequest(ctx context.Context, tx *sql.Tx, rr RawRequest) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "upsert_raw_request")()
+
 	_, err := tx.ExecContext(ctx, `
-INSERT INTO mnp_raw_request(id, req_id, request_time, xml_message, operation_info, system_source, system_dest, change_date)
-VALUES ($1,$2,$3,$4,$5,$6,$7,now())
-ON CONFLICT (id)
-DO UPDATE SET
-  req_id=EXCLUDED.req_id,
-  request_time=EXCLUDED.request_time,
-  xml_message=EXCLUDED.xml_message,
-  operation_info=EXCLUDED.operation_info,
-  system_source=EXCLUDED.system_source,
-  system_dest=EXCLUDED.system_dest,
-  change_date=now()
-`, rr.ID, rr.ReqID, rr.RequestTime, rr.XMLMessage, rr.OperationInfo, rr.SystemSource, rr.SystemDest)
+INSERT INTO mnp_raw_request(id, req_id, request_time, xml_message, operation_info, system_source, system_dest, source, change_date)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,now())
+ON CONFLICT (source, id)
+DO UPDATE SET`+rawRequestUpsertSet,
+		rr.ID, rr.ReqID, s.wall(rr.RequestTime), rr.XMLMessage, rr.OperationInfo, rr.SystemSource, rr.SystemDest, rr.Source)
 
 	return err
 }
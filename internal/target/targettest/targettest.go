@@ -0,0 +1,58 @@
+// Package targettest поднимает витрину для тестов с PostgreSQL.
+package targettest
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+// NewStore применяет миграции db/migrations в отдельной схеме PostgreSQL и удаляет ее после теста.
+// Соединения db используют эту схему, в ней же тест может создать таблицы источников.
+// Тесты запускаются только с TARGET_TEST_PG_DSN, например:
+// TARGET_TEST_PG_DSN="host=localhost user=postgres password=postgres dbname=postgres sslmode=disable".
+func NewStore(t *testing.T, loc *time.Location) (*sql.DB, *target.Store) {
+	t.Helper()
+
+	dsn := os.Getenv("TARGET_TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("TARGET_TEST_PG_DSN is not set")
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() }) //nolint:errcheck
+
+	schema := fmt.Sprintf("datamart_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) }) //nolint:errcheck
+
+	db, err := sql.Open("postgres", dsn+" search_path="+schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() }) //nolint:errcheck
+
+	_, file, _, _ := runtime.Caller(0)
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(file), "../../../db/migrations/*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		require.NoError(t, err)
+		up, _, _ := strings.Cut(string(b), "-- +goose Down")
+		_, err = db.Exec(up)
+		require.NoError(t, err, f)
+	}
+
+	return db, target.NewStore(db, loc)
+}
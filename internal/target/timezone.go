@@ -0,0 +1,27 @@
+package target
+
+import "time"
+
+// WallClock переводит момент времени в показания часов бизнес-зоны для записи в колонки TIMESTAMP.
+// Postgres отбрасывает смещение при приведении к TIMESTAMP, поэтому значение передается уже в зоне loc.
+func WallClock(t time.Time, loc *time.Location) time.Time {
+	return t.In(loc)
+}
+
+// FromWallClock интерпретирует значение колонки TIMESTAMP как показания часов бизнес-зоны.
+func FromWallClock(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+func (s *Store) wall(t time.Time) time.Time {
+	return WallClock(t, s.loc)
+}
+
+func (s *Store) wallPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	w := s.wall(*t)
+
+	return &w
+}
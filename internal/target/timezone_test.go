@@ -0,0 +1,35 @@
+package target_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target"
+)
+
+// processZones - зоны, в которых приходят значения времени (TZ процесса, драйвер). Результат от них не зависит.
+var processZones = []string{"UTC", "Europe/Moscow", "America/New_York", "Asia/Vladivostok"}
+
+func TestWallClock(t *testing.T) {
+	business, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	// 2026-03-01 21:30 UTC = 2026-03-02 00:30 MSK.
+	instant := time.Date(2026, 3, 1, 21, 30, 0, 0, time.UTC)
+
+	for _, zone := range processZones {
+		t.Run(zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(zone)
+			require.NoError(t, err)
+
+			wall := target.WallClock(instant.In(loc), business)
+			require.Equal(t, "2026-03-02 00:30:00", wall.Format(time.DateTime))
+
+			// Так lib/pq возвращает TIMESTAMP: показания часов в UTC.
+			stored := time.Date(2026, 3, 2, 0, 30, 0, 0, time.UTC)
+			require.True(t, instant.Equal(target.FromWallClock(stored, business)))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package target
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/metrics"
+)
+
+// transitionsCTE - переходы заявок $1 по версиям mnp_request_h: первая версия и каждая смена request_status_id.
+// Переход длится до следующего перехода, последний - до to_date последней версии, у открытой версии left_at пуст.
+const transitionsCTE = `
+WITH v AS (
+  SELECT order_number, request_status_id, from_date,
+    lag(request_status_id) OVER w AS prev_status,
+    row_number() OVER w AS rn,
+    last_value(to_date) OVER (w ROWS BETWEEN UNBOUNDED PRECEDING AND UNBOUNDED FOLLOWING) AS last_to_date
+  FROM mnp_request_h
+  WHERE order_number = ANY($1) AND from_date IS NOT NULL
+  WINDOW w AS (PARTITION BY order_number ORDER BY from_date, id)
+), t AS (
+  SELECT order_number, prev_status AS from_status, request_status_id AS to_status, from_date AS entered_at,
+    COALESCE(lead(from_date) OVER (PARTITION BY order_number ORDER BY from_date), last_to_date) AS left_at
+  FROM v
+  WHERE rn = 1 OR request_status_id IS DISTINCT FROM prev_status
+)`
+
+// TouchedTransitionOrders возвращает заявки, версии mnp_request_h которых изменены в [from, to). from = nil - все заявки.
+func (s *Store) TouchedTransitionOrders(ctx context.Context, from *time.Time, to time.Time) ([]string, error) {
+	defer metrics.ObserveQuery(metrics.DBTarget, "touched_transition_orders")()
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT DISTINCT order_number FROM mnp_request_h
+WHERE ($1::timestamp IS NULL OR change_date >= $1) AND change_date < $2
+ORDER BY order_number`, s.wallPtr(from), s.wall(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []string
+	for rows.Next() {
+		var order string
+		if err := rows.Scan(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// RebuildTransitions приводит mnp_request_transition заявок orders к текущим версиям mnp_request_h.
+// Строки, которые не изменились, не перезаписываются и сохраняют change_date.
+func (s *Store) RebuildTransitions(ctx context.Context, tx *sql.Tx, orders []string) error {
+	defer metrics.ObserveQuery(metrics.DBTarget, "rebuild_transitions")()
+
+	if len(orders) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, transitionsCTE+`
+DELETE FROM mnp_request_transition x
+WHERE x.order_number = ANY($1)
+  AND NOT EXISTS (SELECT 1 FROM t WHERE t.order_number = x.order_number AND t.entered_at = x.entered_at)`,
+		pq.Array(orders))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, transitionsCTE+`
+INSERT INTO mnp_request_transition (
+  order_number, from_status, to_status, entered_at, left_at, duration_seconds, change_date
+)
+SELECT order_number, from_status, to_status, entered_at, left_at,
+  EXTRACT(EPOCH FROM left_at - entered_at)::bigint, timezone($2, now())
+FROM t
+ON CONFLICT (order_number, entered_at)
+DO UPDATE SET
+  from_status = EXCLUDED.from_status,
+  to_status = EXCLUDED.to_status,
+  left_at = EXCLUDED.left_at,
+  duration_seconds = EXCLUDED.duration_seconds,
+  change_date = EXCLUDED.change_date
+WHERE (mnp_request_transition.from_status, mnp_request_transition.to_status, mnp_request_transition.left_at)
+  IS DISTINCT FROM (EXCLUDED.from_status, EXCLUDED.to_status, EXCLUDED.left_at)`,
+		pq.Array(orders), s.loc.String())
+
+	return err
+}
@@ -0,0 +1,124 @@
+package target_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/target/targettest"
+)
+
+type transitionRow struct {
+	order      string
+	fromStatus int // 0 - первая версия без предыдущего статуса.
+	toStatus   int
+	enteredAt  string
+	leftAt     string
+	duration   int64
+}
+
+func transitions(t *testing.T, db *sql.DB) []transitionRow {
+	t.Helper()
+
+	rows, err := db.Query(`
+SELECT order_number, coalesce(from_status, 0), to_status, entered_at::text, coalesce(left_at::text, ''),
+  coalesce(duration_seconds, 0)
+FROM mnp_request_transition ORDER BY order_number, entered_at`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var res []transitionRow
+	for rows.Next() {
+		var r transitionRow
+		require.NoError(t, rows.Scan(&r.order, &r.fromStatus, &r.toStatus, &r.enteredAt, &r.leftAt, &r.duration))
+		res = append(res, r)
+	}
+	require.NoError(t, rows.Err())
+
+	return res
+}
+
+func rebuildTransitions(t *testing.T, db *sql.DB, rebuild func(context.Context, *sql.Tx) error) {
+	t.Helper()
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, rebuild(context.Background(), tx))
+	require.NoError(t, tx.Commit())
+}
+
+func TestRebuildTransitions(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+	orders := []string{"pin1", "pin2"}
+	rebuild := func(ctx context.Context, tx *sql.Tx) error { return store.RebuildTransitions(ctx, tx, orders) }
+
+	// pin1: повтор статуса 1 не дает перехода, последняя версия открыта. pin2: единственная закрытая версия.
+	// Строка перехода без версии удаляется.
+	_, err := db.Exec(`
+INSERT INTO mnp_request_h (order_number, order_id, request_status_id, from_date, to_date) VALUES
+  ('pin1', '1', 1, '2026-10-01 10:00', '2026-10-01 10:59:59'),
+  ('pin1', '1', 1, '2026-10-01 11:00', '2026-10-01 11:59:59'),
+  ('pin1', '1', 2, '2026-10-01 12:00', NULL),
+  ('pin2', '2', 5, '2026-10-02 10:00', '2026-10-02 10:59:59');
+INSERT INTO mnp_request_transition (order_number, to_status, entered_at) VALUES ('pin1', 7, '2026-10-01 09:00');`)
+	require.NoError(t, err)
+
+	rebuildTransitions(t, db, rebuild)
+	require.Equal(t, []transitionRow{
+		{order: "pin1", toStatus: 1, enteredAt: "2026-10-01 10:00:00", leftAt: "2026-10-01 12:00:00", duration: 7200},
+		{order: "pin1", fromStatus: 1, toStatus: 2, enteredAt: "2026-10-01 12:00:00"},
+		{order: "pin2", toStatus: 5, enteredAt: "2026-10-02 10:00:00", leftAt: "2026-10-02 10:59:59", duration: 3599},
+	}, transitions(t, db))
+
+	var unchangedAt time.Time
+	require.NoError(t, db.QueryRow(`
+SELECT change_date FROM mnp_request_transition WHERE order_number = 'pin1' AND entered_at = '2026-10-01 12:00'`).
+		Scan(&unchangedAt))
+
+	// Поздняя версия между первыми двумя исправляет соседние переходы.
+	_, err = db.Exec(`
+INSERT INTO mnp_request_h (order_number, order_id, request_status_id, from_date, to_date) VALUES
+  ('pin1', '1', 3, '2026-10-01 10:30', '2026-10-01 10:59:59')`)
+	require.NoError(t, err)
+
+	rebuildTransitions(t, db, rebuild)
+	require.Equal(t, []transitionRow{
+		{order: "pin1", toStatus: 1, enteredAt: "2026-10-01 10:00:00", leftAt: "2026-10-01 10:30:00", duration: 1800},
+		{order: "pin1", fromStatus: 1, toStatus: 3, enteredAt: "2026-10-01 10:30:00", leftAt: "2026-10-01 11:00:00", duration: 1800},
+		{order: "pin1", fromStatus: 3, toStatus: 1, enteredAt: "2026-10-01 11:00:00", leftAt: "2026-10-01 12:00:00", duration: 3600},
+		{order: "pin1", fromStatus: 1, toStatus: 2, enteredAt: "2026-10-01 12:00:00"},
+		{order: "pin2", toStatus: 5, enteredAt: "2026-10-02 10:00:00", leftAt: "2026-10-02 10:59:59", duration: 3599},
+	}, transitions(t, db))
+
+	// Неизменившийся переход не перезаписывается.
+	var changeDate time.Time
+	require.NoError(t, db.QueryRow(`
+SELECT change_date FROM mnp_request_transition WHERE order_number = 'pin1' AND entered_at = '2026-10-01 12:00'`).
+		Scan(&changeDate))
+	require.True(t, unchangedAt.Equal(changeDate))
+}
+
+func TestTouchedTransitionOrders(t *testing.T) {
+	db, store := targettest.NewStore(t, time.UTC)
+
+	_, err := db.Exec(`
+INSERT INTO mnp_request_h (order_number, order_id, from_date, change_date) VALUES
+  ('pin1', '1', '2026-10-01 10:00', '2026-10-10 11:59'),
+  ('pin2', '2', '2026-10-01 10:00', '2026-10-10 12:00'),
+  ('pin2', '2', '2026-10-01 11:00', '2026-10-10 13:00'),
+  ('pin3', '3', '2026-10-01 10:00', '2026-10-10 14:00')`)
+	require.NoError(t, err)
+
+	from := time.Date(2026, 10, 10, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 10, 14, 0, 0, 0, time.UTC)
+	orders, err := store.TouchedTransitionOrders(context.Background(), &from, to)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pin2"}, orders)
+
+	orders, err = store.TouchedTransitionOrders(context.Background(), nil, to)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pin1", "pin2"}, orders)
+}
@@ -0,0 +1,66 @@
+package transform
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// CDBStatus - коды статуса и комментарий отказа из XML сообщения БДПН.
+type CDBStatus struct {
+	// Codes - значения StatusList/Status/Code в порядке следования.
+	Codes         []int
+	RejectComment string
+}
+
+// ParseCDBStatus разбирает XML сообщения БДПН (NPMessages/PortMessages/PortMessage).
+// Разбор нестрогий: при ошибке в XML возвращается то, что удалось прочитать до нее.
+func ParseCDBStatus(xmlMessage string) CDBStatus {
+	var res CDBStatus
+
+	dec := xml.NewDecoder(strings.NewReader(xmlMessage))
+	dec.Strict = false
+	var path []string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return res
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			if text == "" {
+				continue
+			}
+			switch {
+			case hasSuffix(path, "StatusList", "Status", "Code"):
+				if code, err := strconv.Atoi(text); err == nil {
+					res.Codes = append(res.Codes, code)
+				}
+			case hasSuffix(path, "NpList", "RejectComment"):
+				res.RejectComment = text
+			}
+		}
+	}
+}
+
+func hasSuffix(path []string, suffix ...string) bool {
+	if len(path) < len(suffix) {
+		return false
+	}
+	tail := path[len(path)-len(suffix):]
+	for i := range suffix {
+		if tail[i] != suffix[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,32 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+func TestParseCDBStatus(t *testing.T) {
+	msg := `<?xml version="1.0" encoding="UTF-8"?>
+<NPMessages><PortMessages><PortMessage>
+	<NPId>1</NPId>
+	<MessageCode>NP Donor Reject</MessageCode>
+	<NPRequestId>pin488333</NPRequestId>
+	<StatusList>
+		<Status><Code>7009</Code><NrList><Nr>9200899997</Nr></NrList></Status>
+		<Status><Code>7012</Code></Status>
+	</StatusList>
+	<NpList><RejectComment>Задолженность</RejectComment></NpList>
+</PortMessage></PortMessages></NPMessages>`
+
+	st := transform.ParseCDBStatus(msg)
+	require.Equal(t, []int{7009, 7012}, st.Codes)
+	require.Equal(t, "Задолженность", st.RejectComment)
+
+	st = transform.ParseCDBStatus(`<NPMessages><PortMessages><PortMessage><StatusList><Status><Code>7001</Code></Status><Status><Code>`)
+	require.Equal(t, []int{7001}, st.Codes)
+
+	require.Empty(t, transform.ParseCDBStatus("").Codes)
+}
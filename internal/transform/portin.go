@@ -5,35 +5,35 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/model"
 )
 
 type OrderPayload struct {
-	Contract struct {
-		DocumentDate string `json:"documentDate"`
-	} `json:"contract"`
-	ProcessType string `json:"processType"`
-	Status      struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	} `json:"status"`
-	Recipient struct {
-		CDBCode string `json:"cdbCode"`
-	} `json:"recipient"`
-	PortationNumbers []struct {
-		MSISDN string `json:"msisdn"`
-		RN     string `json:"rn"`
-	} `json:"portationNumbers"`
-	Person     any `json:"person"`
-	Individual any `json:"individual"`
-	Company    any `json:"company"`
-	Government any `json:"government"`
+	model.PortInOrderData
+	SchemaVersion model.SchemaVersion
+	// UnknownFields - пути полей order_data, которых нет в модели (например, "contract.signedBy"),
+	// и полей, значение которых не подошло по типу к нестрого разбираемым частям модели (например, "donor.region").
+	UnknownFields []string
+	// Paths - все пути полей order_data с типами значений.
+	Paths []KeyPath
 }
 
 func ParseOrderPayload(raw []byte) (OrderPayload, error) {
 	var p OrderPayload
-	err := json.Unmarshal(raw, &p)
+	if err := json.Unmarshal(raw, &p.PortInOrderData); err != nil {
+		return p, err
+	}
+
+	var tree map[string]any
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		return p, err
+	}
+	p.SchemaVersion = detectSchemaVersion(tree)
+	p.UnknownFields = unknownFields(tree, orderDataType, mismatchedFields(p.PortInOrderData))
+	p.Paths = keyPaths(tree)
 
-	return p, err
+	return p, nil
 }
 
 func SubscriberType(p OrderPayload) string {
@@ -49,14 +49,15 @@ func SubscriberType(p OrderPayload) string {
 	}
 }
 
-func ParseContractDate(v string) *time.Time {
+// ParseContractDate разбирает дату договора. Значения без смещения интерпретируются в зоне loc.
+func ParseContractDate(v string, loc *time.Location) *time.Time {
 	if strings.TrimSpace(v) == "" {
 		return nil
 	}
 
 	layouts := []string{time.RFC3339, "2006-01-02", "2006-01-02T15:04:05"}
 	for _, layout := range layouts {
-		ts, err := time.Parse(layout, v)
+		ts, err := time.ParseInLocation(layout, v, loc)
 		if err == nil {
 			return &ts
 		}
@@ -0,0 +1,141 @@
+package transform_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/model"
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/transform"
+)
+
+func TestParseOrderPayload(t *testing.T) {
+	t.Run("typed payload with unknown fields", func(t *testing.T) {
+		raw := []byte(`{
+			"processType": "ShortTimePort",
+			"contract": {"documentDate": "2026-02-01", "signedBy": "operator"},
+			"status": {"code": "cdb-rejected", "message": "1010. Reject"},
+			"donor": {"rn": "D1234", "region": {"code": "77"}},
+			"recipient": {"rn": "D0001", "cdbCode": "mMTS"},
+			"person": {"firstName": "Ivan", "lastName": "Ivanov", "idDocuments": [{"docNumber": "1", "issuer": "x"}]},
+			"portationNumbers": [{"msisdn": "79000000000", "rn": "D0001", "status": {"code": "transfered"}}],
+			"channel": "web"
+		}`)
+
+		p, err := transform.ParseOrderPayload(raw)
+		require.NoError(t, err)
+		require.Equal(t, model.SchemaVersionV1, p.SchemaVersion)
+		require.Equal(t, "ShortTimePort", p.ProcessTypeName())
+		require.Equal(t, "2026-02-01", p.Contract.Date())
+		require.Equal(t, "cdb-rejected", p.OrderStatus().Code)
+		require.Equal(t, "1010. Reject", p.OrderStatus().MessageText())
+		require.Equal(t, "mMTS", p.Recipient.CDBCode())
+		require.Equal(t, "77", p.Donor.Region.Code)
+		require.Equal(t, "Person", transform.SubscriberType(p))
+		require.Len(t, p.PortationNumbers, 1)
+		require.Equal(t, "transfered", p.PortationNumbers[0].Status.Code) //nolint:misspell // код статуса источника
+		require.Equal(t, []string{"channel", "contract.signedBy", "person.idDocuments[].issuer"}, p.UnknownFields)
+	})
+
+	t.Run("contract date schema", func(t *testing.T) {
+		p, err := transform.ParseOrderPayload([]byte(`{"contract": {"contractDate": "2026-03-01"}, "state": {"code": "created"}}`))
+		require.NoError(t, err)
+		require.Equal(t, model.SchemaVersionV2, p.SchemaVersion)
+		require.Equal(t, "2026-03-01", p.Contract.Date())
+		// state не подменяет отсутствующий status: message_code остается пустым.
+		require.Empty(t, p.OrderStatus().Code)
+		require.Equal(t, "created", p.State.Code)
+		require.Empty(t, p.UnknownFields)
+		require.Empty(t, p.Recipient.CDBCode())
+	})
+
+	t.Run("mismatched unmapped fields are reported", func(t *testing.T) {
+		p, err := transform.ParseOrderPayload([]byte(`{
+			"company": {"name": "ООО", "inn": 7700000000, "numbers": [{"msisdn": "79000000000"}]},
+			"donor": {"rn": "D1234", "region": "77"},
+			"recipient": {"rn": "D0001", "region": {"code": "50", "name": 50}},
+			"portationNumbers": [{"msisdn": "79000000000", "telcoAccount": {"id": "acc-1", "msisdn": 79000000000}}]
+		}`))
+		require.NoError(t, err)
+		require.Equal(t, "Org", transform.SubscriberType(p))
+		require.Equal(t, "ООО", p.Company.Name)
+		require.Empty(t, p.Company.Inn)
+		require.Equal(t, "D1234", p.Donor.Rn)
+		require.Empty(t, p.Donor.Region.Code)
+		require.Equal(t, "50", p.Recipient.Region.Code)
+		require.Nil(t, p.Recipient.Region.Name)
+		require.Equal(t, "79000000000", p.PortationNumbers[0].Msisdn)
+		require.Equal(t, "acc-1", *p.PortationNumbers[0].TelcoAccount.ID)
+		require.Equal(t, []string{
+			"company.inn",
+			"company.numbers",
+			"donor.region",
+			"portationNumbers[].telcoAccount.msisdn",
+			"recipient.region.name",
+		}, p.UnknownFields)
+	})
+
+	t.Run("null unmapped group", func(t *testing.T) {
+		p, err := transform.ParseOrderPayload([]byte(`{"person": null, "portationNumbers": [{"msisdn": "79000000000", "telcoAccount": null}]}`))
+		require.NoError(t, err)
+		require.Nil(t, p.Person)
+		require.Empty(t, transform.SubscriberType(p))
+		require.Empty(t, p.UnknownFields)
+	})
+
+	t.Run("type mismatch", func(t *testing.T) {
+		_, err := transform.ParseOrderPayload([]byte(`{"portationNumbers": {"msisdn": "79000000000"}}`))
+		require.Error(t, err)
+	})
+}
+
+func TestParseOrderPayloadPaths(t *testing.T) {
+	p, err := transform.ParseOrderPayload([]byte(`{
+		"contract": {"contractDate": "2026-03-01"},
+		"portationNumbers": [{"msisdn": "79000000000"}, {"msisdn": null}],
+		"person": null
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []transform.KeyPath{
+		{Path: "contract", JSONType: "object"},
+		{Path: "contract.contractDate", JSONType: "string"},
+		{Path: "person", JSONType: "null"},
+		{Path: "portationNumbers", JSONType: "array"},
+		{Path: "portationNumbers[]", JSONType: "object"},
+		{Path: "portationNumbers[].msisdn", JSONType: "null"},
+		{Path: "portationNumbers[].msisdn", JSONType: "string"},
+	}, p.Paths)
+	require.True(t, transform.IsTopLevelPath("person"))
+	require.False(t, transform.IsTopLevelPath("portationNumbers[]"))
+}
+
+func TestParseContractDate(t *testing.T) {
+	business, err := time.LoadLocation("Europe/Moscow")
+	require.NoError(t, err)
+
+	for _, zone := range []string{"UTC", "Europe/Moscow", "America/New_York", "Asia/Vladivostok"} {
+		t.Run(zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(zone)
+			require.NoError(t, err)
+
+			prev := time.Local
+			time.Local = loc
+			t.Cleanup(func() { time.Local = prev })
+
+			date := transform.ParseContractDate("2026-02-01", business)
+			require.NotNil(t, date)
+			require.True(t, time.Date(2026, 1, 31, 21, 0, 0, 0, time.UTC).Equal(*date))
+
+			local := transform.ParseContractDate("2026-02-01T10:15:00", business)
+			require.NotNil(t, local)
+			require.True(t, time.Date(2026, 2, 1, 7, 15, 0, 0, time.UTC).Equal(*local))
+
+			withOffset := transform.ParseContractDate("2026-02-01T10:15:00+05:00", business)
+			require.NotNil(t, withOffset)
+			require.True(t, time.Date(2026, 2, 1, 5, 15, 0, 0, time.UTC).Equal(*withOffset))
+
+			require.Nil(t, transform.ParseContractDate(" ", business))
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package transform
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"gitlab.services.mts.ru/salsa/mnp-hub/mnp-datamart/internal/model"
+)
+
+var orderDataType = reflect.TypeFor[model.PortInOrderData]()
+
+// MappedPaths - группы путей order_data, из которых читает маппинг в витрину.
+// Группа считается присутствующей, если в данных встречается хотя бы один путь из нее.
+var MappedPaths = [][]string{
+	{"processType"},
+	{"contract.documentDate", "contract.contractDate"},
+	{"status.code"},
+	{"recipient.cdbCode"},
+	{"portationNumbers[].msisdn"},
+	{"person", "individual", "company", "government"},
+}
+
+type KeyPath struct {
+	Path     string
+	JSONType string
+}
+
+// IsTopLevelPath сообщает, что путь относится к полю верхнего уровня order_data.
+func IsTopLevelPath(path string) bool {
+	return !strings.ContainsAny(path, ".[")
+}
+
+func keyPaths(tree map[string]any) []KeyPath {
+	set := make(map[KeyPath]struct{})
+	for key, child := range tree {
+		collectPaths(child, key, set)
+	}
+
+	res := make([]KeyPath, 0, len(set))
+	for p := range set {
+		res = append(res, p)
+	}
+	slices.SortFunc(res, func(a, b KeyPath) int {
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.JSONType, b.JSONType)
+	})
+
+	return res
+}
+
+func collectPaths(v any, path string, set map[KeyPath]struct{}) {
+	set[KeyPath{Path: path, JSONType: jsonType(v)}] = struct{}{}
+
+	switch node := v.(type) {
+	case map[string]any:
+		for key, child := range node {
+			collectPaths(child, joinPath(path, key), set)
+		}
+	case []any:
+		for _, item := range node {
+			collectPaths(item, path+"[]", set)
+		}
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func detectSchemaVersion(tree map[string]any) model.SchemaVersion {
+	contract, ok := lookupKey(tree, "contract").(map[string]any)
+	if !ok {
+		return model.SchemaVersionUnknown
+	}
+
+	switch {
+	case lookupKey(contract, "contractDate") != nil:
+		return model.SchemaVersionV2
+	case lookupKey(contract, "documentDate") != nil:
+		return model.SchemaVersionV1
+	default:
+		return model.SchemaVersionUnknown
+	}
+}
+
+// unknownFields возвращает отсортированные пути полей tree, которые не описаны в типе t, вместе с extra.
+// Элементы массивов обозначаются суффиксом "[]".
+func unknownFields(tree map[string]any, t reflect.Type, extra []string) []string {
+	set := make(map[string]struct{})
+	collectUnknown(tree, t, "", set)
+	for _, path := range extra {
+		set[path] = struct{}{}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	res := make([]string, 0, len(set))
+	for path := range set {
+		res = append(res, path)
+	}
+	slices.Sort(res)
+
+	return res
+}
+
+// mismatchedFields возвращает пути полей нестрого разбираемых частей d, значение которых не подошло по типу.
+func mismatchedFields(d model.PortInOrderData) []string {
+	var res []string
+	add := func(path string, fields []string) {
+		for _, f := range fields {
+			res = append(res, joinPath(path, f))
+		}
+	}
+	if d.Person != nil {
+		add("person", d.Person.Mismatched)
+	}
+	if d.Company != nil {
+		add("company", d.Company.Mismatched)
+	}
+	if d.Government != nil {
+		add("government", d.Government.Mismatched)
+	}
+	if d.Individual != nil {
+		add("individual", d.Individual.Mismatched)
+	}
+	if d.Donor != nil && d.Donor.Region != nil {
+		add("donor.region", d.Donor.Region.Mismatched)
+	}
+	if d.Recipient != nil && d.Recipient.Region != nil {
+		add("recipient.region", d.Recipient.Region.Mismatched)
+	}
+	for _, n := range d.PortationNumbers {
+		add("portationNumbers[].telcoAccount", n.TelcoAccount.Mismatched)
+	}
+
+	return res
+}
+
+func collectUnknown(v any, t reflect.Type, path string, set map[string]struct{}) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node := v.(type) {
+	case map[string]any:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		for key, child := range node {
+			childPath := joinPath(path, key)
+			field, ok := fieldByJSONName(t, key)
+			if !ok {
+				set[childPath] = struct{}{}
+				continue
+			}
+			collectUnknown(child, field.Type, childPath, set)
+		}
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for _, item := range node {
+			collectUnknown(item, t.Elem(), path+"[]", set)
+		}
+	}
+}
+
+// fieldByJSONName ищет поле так же, как encoding/json: сначала точное совпадение имени, затем без учета регистра.
+func fieldByJSONName(t reflect.Type, name string) (reflect.StructField, bool) {
+	var folded *reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		jsonName := jsonFieldName(f)
+		if jsonName == "" {
+			continue
+		}
+		if jsonName == name {
+			return f, true
+		}
+		if folded == nil && strings.EqualFold(jsonName, name) {
+			folded = &f
+		}
+	}
+	if folded != nil {
+		return *folded, true
+	}
+
+	return reflect.StructField{}, false
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name
+	}
+
+	return f.Name
+}
+
+func lookupKey(m map[string]any, key string) any {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if key == "" {
+		return prefix
+	}
+
+	return prefix + "." + key
+}